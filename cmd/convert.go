@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"diagram-gen/internal/dot"
+	"diagram-gen/internal/drawio"
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/markdown"
+	"diagram-gen/internal/mermaid"
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/modelfile"
+	"diagram-gen/internal/plantuml"
+	"diagram-gen/internal/validator"
+)
+
+func buildConvertCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "convert <input> <output>",
+		Short: "Convert a diagram between draw.io, DOT, Mermaid and model files",
+		Long: `Reads a diagram from a .drawio file, a JSON or YAML model file, a
+Graphviz DOT (.dot, .gv), Mermaid (.mmd) or PlantUML (.puml) file, or the
+Mermaid flowcharts and PlantUML blocks of a Markdown document, and writes
+it in the format named by the extension of the output file: .drawio,
+.dot, .gv, .mmd, .md (Mermaid blocks), .json, .yaml or .yml. Converting
+a hand-drawn .drawio file into a model file brings it under code
+control; components keep the position they were drawn at.
+
+Example:
+  diagram-gen convert legacy.drawio legacy.diagram.yaml
+  diagram-gen convert legacy.diagram.yaml legacy.drawio --compress
+  diagram-gen convert docs/design.md design.drawio
+  diagram-gen convert deps.dot deps.drawio
+  diagram-gen convert legacy.drawio docs/legacy.md`,
+		Args: cobra.ExactArgs(2),
+		RunE: convertRunE,
+	}
+
+	cmd.Flags().String("layout", "layered", "Layout of components without a position: grid, layered, isometric")
+	cmd.Flags().Bool("compress", false, "Compress .drawio output with deflate+base64")
+	return cmd
+}
+
+func convertRunE(cmd *cobra.Command, args []string) error {
+	input, output := args[0], args[1]
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	diagram, err := readDiagram(input, data)
+	if err != nil {
+		return fmt.Errorf("failed to parse input: %w", err)
+	}
+	if err := validator.ValidateDiagram(diagram); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	var out []byte
+	switch {
+	case drawio.IsDrawIOFile(output):
+		gen := generator.NewDrawIOGenerator()
+		gen.LayoutType, _ = cmd.Flags().GetString("layout")
+		gen.Compress, _ = cmd.Flags().GetBool("compress")
+		out, err = gen.Generate(diagram)
+	case dot.IsDOTFile(output):
+		out, err = generator.NewDOTGenerator().Generate(diagram)
+	case mermaid.IsMermaidFile(output), markdown.IsMarkdownFile(output):
+		out, err = newMermaidGenerator(output).Generate(diagram)
+	case modelfile.IsModelFile(output):
+		out, err = modelfile.Marshal(output, diagram)
+	default:
+		return fmt.Errorf("unsupported output %s: want a .drawio, .dot, .gv, .mmd, .md, .json, .yaml or .yml file", output)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to convert diagram: %w", err)
+	}
+
+	if err := os.WriteFile(output, out, 0644); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Converted %s to %s with %d components and %d connections\n",
+		input, output, len(diagram.Components), len(diagram.Connections))
+	return nil
+}
+
+// readDiagram reads a diagram file given to convert, by its extension.
+func readDiagram(path string, data []byte) (*model.Diagram, error) {
+	var diagram *model.Diagram
+	var err error
+	switch {
+	case drawio.IsDrawIOFile(path):
+		return drawio.Read(path, data)
+	case modelfile.IsModelFile(path):
+		return modelfile.Load(path, data)
+	case dot.IsDOTFile(path):
+		return dot.Parse(path, data)
+	case mermaid.IsMermaidFile(path):
+		diagram, err = mermaid.Parse(path, data)
+	case plantuml.IsPlantUMLFile(path):
+		diagram, err = plantuml.Parse(path, data)
+	case markdown.IsMarkdownFile(path):
+		diagram, err = markdown.Load(path, data)
+	default:
+		return nil, fmt.Errorf("unsupported input %s: want a .drawio, .json, .yaml, .yml, .dot, .gv, .mmd, .puml or .md file", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Nodes a text diagram only links to are partial; on their own they
+	// are complete services.
+	diagram.Reconcile()
+	return diagram, nil
+}
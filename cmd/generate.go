@@ -4,11 +4,16 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/dot"
 	"diagram-gen/internal/generator"
+	"diagram-gen/internal/markdown"
+	"diagram-gen/internal/mermaid"
 	"diagram-gen/internal/model"
 	"diagram-gen/internal/validator"
 )
@@ -20,6 +25,24 @@ var (
 	flagShape     string
 	flagConfig    string
 	flagPage      string
+	flagInclude   []string
+	flagExclude   []string
+	flagStrict    bool
+	flagDiagFmt   string
+	flagInfer     bool
+	flagExternal  string
+	flagGrammar   string
+	flagWorkers   int
+	flagCache     bool
+	flagCacheDir  string
+	flagGOOS      string
+	flagGOARCH    string
+	flagTags      []string
+	flagSkipGen   bool
+	flagModGroups string
+	flagK8s       bool
+	flagAPITags   bool
+	flagFormat    string
 )
 
 func newGeneratorWithFlags() generator.Formatter {
@@ -35,6 +58,45 @@ func newGeneratorWithFlags() generator.Formatter {
 
 var newGenerator = newGeneratorWithFlags
 
+// outputExtensions holds the output formats and the extension of their files.
+var outputExtensions = map[string]string{
+	"drawio":  ".drawio",
+	"dot":     ".dot",
+	"mermaid": ".mmd",
+}
+
+// newFormatter returns the formatter writing an output format. An empty
+// format is picked by the extension of the output file.
+func newFormatter(format, outputPath string) (generator.Formatter, error) {
+	if format == "" {
+		switch {
+		case dot.IsDOTFile(outputPath):
+			format = "dot"
+		case mermaid.IsMermaidFile(outputPath), markdown.IsMarkdownFile(outputPath):
+			format = "mermaid"
+		default:
+			format = "drawio"
+		}
+	}
+	switch format {
+	case "drawio":
+		return newGenerator(), nil
+	case "dot":
+		return generator.NewDOTGenerator(), nil
+	case "mermaid":
+		return newMermaidGenerator(outputPath), nil
+	}
+	return nil, fmt.Errorf("unknown output format: %s (valid formats: drawio, dot, mermaid)", format)
+}
+
+// newMermaidGenerator returns a Mermaid generator, writing a Markdown
+// document with a block per page for .md outputs.
+func newMermaidGenerator(outputPath string) *generator.MermaidGenerator {
+	gen := generator.NewMermaidGenerator()
+	gen.Markdown = markdown.IsMarkdownFile(outputPath)
+	return gen
+}
+
 // SetGeneratorFactory overrides the generator factory used by the generate command.
 func SetGeneratorFactory(factory func() generator.Formatter) {
 	if factory == nil {
@@ -56,46 +118,141 @@ func RunGenerateForTest(args []string) error {
 
 func buildGenerateCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "generate [file or directory]",
+		Use:   "generate [file, directory or pattern]...",
 		Short: "Generate a diagram from Go source code",
-		Long: `Parses Go source files and generates a draw.io diagram based on 
-diagram struct tags. 
+		Long: `Parses Go source files and generates a draw.io diagram based on
+diagram struct tags. Inputs may be files, directories or Go-style
+patterns such as ./... that include every subdirectory. JSON and YAML
+model files are merged with the annotations; directories pick up files
+named *.diagram.json, *.diagram.yaml or *.diagram.yml. Naming a
+docker-compose file imports its services, naming an OpenAPI 3
+document adds its API, naming a .proto file adds its gRPC services,
+naming a .drawio or Graphviz .dot file adds what it draws and naming a
+Mermaid, PlantUML or Markdown file adds the diagrams it holds, while
+--kubernetes reads the Kubernetes manifests found in directories. The
+diagram is written as draw.io XML, as Graphviz DOT when the output
+file ends in .dot or .gv, or as Mermaid flowcharts when it ends in
+.mmd or .md; --format picks the format whatever the extension.
 
 Example:
   diagram-gen generate ./internal/services/
+  diagram-gen generate ./... --exclude 'internal/legacy'
+  diagram-gen generate ./... --type imports --external expand
+  diagram-gen generate ./internal/handlers/ --type flowchart
   diagram-gen generate main.go -o diagram.drawio
-  diagram-gen generate main.go --layout isometric --compress`,
-		Args: cobra.ExactArgs(1),
+  diagram-gen generate main.go --layout isometric --compress
+  diagram-gen generate ./... saas.diagram.yaml
+  diagram-gen generate docker-compose.yml --layout grid
+  diagram-gen generate ./deploy/k8s/... --kubernetes --layout isometric
+  diagram-gen generate ./... api/openapi.yaml --openapi-tags
+  diagram-gen generate ./... api/orders.proto --type network
+  diagram-gen generate ./... docs/design.md
+  diagram-gen generate ./... deps.dot -o architecture.dot
+  diagram-gen generate ./... -o docs/architecture.md
+  diagram-gen generate ./... --cache
+  diagram-gen generate go.work --module-groups page
+  diagram-gen generate ./... --goos linux --tags integration --skip-generated`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: generateRunE,
 	}
 
 	cmd.Flags().StringP("output", "o", "diagram.drawio", "Output file path")
-	cmd.Flags().StringP("type", "t", "architecture", "Diagram type (architecture, flowchart, network)")
+	cmd.Flags().StringVar(&flagFormat, "format", "", "Output format: drawio, dot, mermaid (default from the output file extension)")
+	cmd.Flags().StringP("type", "t", "architecture", "Diagram type (architecture, flowchart, network, imports)")
 	cmd.Flags().StringVar(&flagLayout, "layout", "layered", "Layout type: grid, layered, isometric")
 	cmd.Flags().BoolVar(&flagIsometric, "isometric", false, "Use isometric layout (shortcut for --layout isometric)")
 	cmd.Flags().BoolVar(&flagCompress, "compress", false, "Compress output with deflate+base64")
 	cmd.Flags().StringVar(&flagShape, "shape", "", "Default shape for components (e.g., iso:server, rounded, cylinder)")
 	cmd.Flags().StringVar(&flagConfig, "config", "", "Path to config file (.diagram-gen.yaml or .diagram-gen.json)")
 	cmd.Flags().StringVar(&flagPage, "page", "", "Page name to generate (for multi-page diagrams)")
+	cmd.Flags().StringSliceVar(&flagInclude, "include", nil, "Only parse files matching these globs")
+	cmd.Flags().StringSliceVar(&flagExclude, "exclude", nil, "Skip files and directories matching these globs")
+	cmd.Flags().StringVar(&flagExternal, "external", "collapse", "Imports outside the module in import graphs: collapse, expand, hide")
+	cmd.Flags().StringVar(&flagGOOS, "goos", "", "Only parse files whose build constraints match this GOOS")
+	cmd.Flags().StringVar(&flagGOARCH, "goarch", "", "Only parse files whose build constraints match this GOARCH")
+	cmd.Flags().StringSliceVar(&flagTags, "tags", nil, "Build tags satisfied when evaluating build constraints")
+	cmd.Flags().BoolVar(&flagSkipGen, "skip-generated", false, "Skip files with a \"Code generated ... DO NOT EDIT.\" header")
+	cmd.Flags().StringVar(&flagModGroups, "module-groups", string(archparser.ModuleGroupingNone), "Group components by Go module: none, swimlane, page")
+	cmd.Flags().BoolVar(&flagK8s, "kubernetes", false, "Also read Kubernetes manifests found in directories")
+	cmd.Flags().BoolVar(&flagAPITags, "openapi-tags", false, "Add a component per OpenAPI tag holding its operations")
+	cmd.Flags().BoolVar(&flagInfer, "infer", false, "Infer connections from struct field types and NewX constructor parameters")
+	cmd.Flags().StringVar(&flagGrammar, "grammar", string(archparser.GrammarAuto), "Annotation grammar: auto, 1 (legacy), 2")
+	cmd.Flags().IntVar(&flagWorkers, "workers", 0, "Files to parse concurrently (0 uses every CPU)")
+	cmd.Flags().BoolVar(&flagCache, "cache", false, "Reuse results for unchanged files from the parse cache")
+	cmd.Flags().StringVar(&flagCacheDir, "cache-dir", archparser.DefaultCacheDir, "Parse cache directory (implies --cache)")
+	cmd.Flags().BoolVar(&flagStrict, "strict", false, "Fail when any annotation diagnostic is reported")
+	cmd.Flags().StringVar(&flagDiagFmt, "diagnostics-format", "text", "Diagnostics output format: text, json")
 	return cmd
 }
 
 func generateRunE(cmd *cobra.Command, args []string) error {
-	inputPath := args[0]
+	inputPath := strings.Join(args, " ")
 	outputPath, _ := cmd.Flags().GetString("output")
 	diagramType, _ := cmd.Flags().GetString("type")
 
 	if outputPath == "" {
 		outputPath = "diagram.drawio"
 	}
+	if ext := outputExtensions[flagFormat]; ext != "" && !cmd.Flags().Changed("output") {
+		outputPath = "diagram" + ext
+	}
+	gen, err := newFormatter(flagFormat, outputPath)
+	if err != nil {
+		return err
+	}
 
 	p := archparser.New()
-	diagram, err := p.Parse(inputPath)
+	p.Include = flagInclude
+	p.Exclude = flagExclude
+	p.InferConnections = flagInfer
+	p.ExternalImports = archparser.ExternalImports(flagExternal)
+	p.Grammar = archparser.Grammar(flagGrammar)
+	p.Workers = flagWorkers
+	p.GOOS = flagGOOS
+	p.GOARCH = flagGOARCH
+	p.BuildTags = flagTags
+	p.SkipGenerated = flagSkipGen
+	p.ModuleGroups = archparser.ModuleGrouping(flagModGroups)
+	p.Kubernetes = flagK8s
+	p.OpenAPITags = flagAPITags
+	if flagCache || cmd.Flags().Changed("cache-dir") {
+		p.CacheDir = flagCacheDir
+	}
+	if err := archparser.ValidateGrammar(p.Grammar); err != nil {
+		return err
+	}
+	if err := archparser.ValidateModuleGrouping(p.ModuleGroups); err != nil {
+		return err
+	}
+
+	// An interrupt stops parsing large trees instead of waiting for every file.
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	var diagram *model.Diagram
+	switch model.DiagramType(diagramType) {
+	case model.DiagramTypeImports:
+		diagram, err = p.ParseImportGraphContext(ctx, args...)
+	case model.DiagramTypeFlowchart:
+		diagram, err = p.ParseFlowchartsContext(ctx, args...)
+	default:
+		diagram, err = p.ParseContext(ctx, args...)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to parse input: %w", err)
 	}
 
+	if err := reportDiagnostics(cmd, p.Diagnostics()); err != nil {
+		return err
+	}
+
 	if len(diagram.Components) == 0 {
+		switch diagram.Type {
+		case model.DiagramTypeImports:
+			return fmt.Errorf("no Go packages found in %s", inputPath)
+		case model.DiagramTypeFlowchart:
+			return fmt.Errorf("no //diagram:flowchart functions found in %s", inputPath)
+		}
 		return fmt.Errorf("no diagram annotations found in %s", inputPath)
 	}
 
@@ -128,9 +285,18 @@ func generateRunE(cmd *cobra.Command, args []string) error {
 		}
 		diagram.Components = filteredComps
 		diagram.Connections = filteredConns
+
+		filteredPages := []model.Page{}
+		for _, page := range diagram.Pages {
+			if page.Name == flagPage {
+				filteredPages = append(filteredPages, page)
+			}
+		}
+		if len(diagram.Pages) > 0 {
+			diagram.Pages = filteredPages
+		}
 	}
 
-	gen := newGenerator()
 	data, err := gen.Generate(diagram)
 	if err != nil {
 		return fmt.Errorf("failed to generate diagram: %w", err)
@@ -157,4 +323,24 @@ func generateRunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-var generateCmd = buildGenerateCmd()
+func reportDiagnostics(cmd *cobra.Command, diags archparser.Diagnostics) error {
+	w := cmd.ErrOrStderr()
+
+	switch flagDiagFmt {
+	case "json":
+		if err := diags.WriteJSON(w); err != nil {
+			return fmt.Errorf("failed to report diagnostics: %w", err)
+		}
+	case "", "text":
+		if err := diags.WriteText(w); err != nil {
+			return fmt.Errorf("failed to report diagnostics: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown diagnostics format: %s (valid formats: text, json)", flagDiagFmt)
+	}
+
+	if flagStrict && len(diags) > 0 {
+		return fmt.Errorf("strict mode: %d diagnostic(s) reported", len(diags))
+	}
+	return nil
+}
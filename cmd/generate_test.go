@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"diagram-gen/cmd"
@@ -250,3 +251,597 @@ func (e errorGenerator) Generate(_ *model.Diagram) ([]byte, error) {
 func (e errorGenerator) Format() string {
 	return "drawio"
 }
+
+func TestGenerateCommandRecursivePattern(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "internal", "orders"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "internal", "legacy"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	writeInputFile(t, dir, "main.go", "package main\n\n"+
+		"type ServiceA struct {\n"+
+		"\tField string `diagram:\"type=service,name=ServiceA,connectsTo=Orders\"`\n"+
+		"}\n")
+	writeInputFile(t, filepath.Join(dir, "internal", "orders"), "orders.go", "package orders\n\n"+
+		"type Orders struct {\n"+
+		"\tField string `diagram:\"type=service,name=Orders\"`\n"+
+		"}\n")
+	writeInputFile(t, filepath.Join(dir, "internal", "legacy"), "legacy.go", "package legacy\n\n"+
+		"type Legacy struct {\n"+
+		"\tField string `diagram:\"type=service,name=Orders\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	err := runCmd(t, dir, "generate", "./...", "--exclude", "internal/legacy", "--output", output)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+}
+
+func TestGenerateCommandStrictDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "input.go", "package main\n\n"+
+		"type ServiceA struct {\n"+
+		"\tField string `diagram:\"type=service,name=ServiceA\"`\n"+
+		"}\n\n"+
+		"type ServiceB struct {\n"+
+		"\tField string `diagram:\"type=service\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{input, "--output", output}); err != nil {
+		t.Fatalf("expected non-strict run to succeed: %v", err)
+	}
+
+	err := cmd.RunGenerateForTest([]string{input, "--output", output, "--strict", "--diagnostics-format", "json"})
+	if err == nil {
+		t.Fatal("expected strict mode to fail")
+	}
+
+	err = cmd.RunGenerateForTest([]string{input, "--output", output, "--diagnostics-format", "xml"})
+	if err == nil {
+		t.Fatal("expected error for unknown diagnostics format")
+	}
+}
+
+func TestGenerateCommandImportGraph(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "go.mod", "module example.com/app\n\ngo 1.22\n")
+	writeInputFile(t, dir, "main.go", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n")
+	output := filepath.Join(dir, "imports.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	err := cmd.RunGenerateForTest([]string{dir + "/...", "--type", "imports", "--output", output})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+
+	err = cmd.RunGenerateForTest([]string{dir + "/...", "--type", "imports", "--external", "sideways", "--output", output})
+	if err == nil {
+		t.Fatal("expected error for unknown external imports mode")
+	}
+}
+
+func TestGenerateCommandFlowchart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "handler.go", "package main\n\n"+
+		"//diagram:flowchart\n"+
+		"func Handle(ok bool) error {\n"+
+		"\tif !ok {\n"+
+		"\t\treturn fail()\n"+
+		"\t}\n"+
+		"\treturn nil\n"+
+		"}\n")
+	noFlowchart := writeInputFile(t, dir, "service.go", "package main\n\n"+
+		"type ServiceA struct {\n"+
+		"\tField string `diagram:\"type=service,name=ServiceA\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "flow.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	err := cmd.RunGenerateForTest([]string{input, "--type", "flowchart", "--page", "main.Handle", "--output", output})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("expected output file: %v", err)
+	}
+	if !strings.Contains(string(data), `<diagram name="main.Handle">`) {
+		t.Errorf("expected a main.Handle page, got:\n%s", data)
+	}
+
+	err = cmd.RunGenerateForTest([]string{noFlowchart, "--type", "flowchart", "--output", output})
+	if err == nil {
+		t.Fatal("expected error without flowchart directives")
+	}
+}
+
+func TestGenerateCommandGrammar(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "input.go", "package main\n\n"+
+		"type Payments struct {\n"+
+		"\tField string `diagram:\"name=Payments,description=one=two\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{input, "--output", output, "--grammar", "1", "--strict"}); err != nil {
+		t.Fatalf("expected the legacy grammar to accept the tag: %v", err)
+	}
+
+	err := cmd.RunGenerateForTest([]string{input, "--output", output, "--grammar", "2", "--strict"})
+	if err == nil {
+		t.Fatal("expected grammar 2 to reject the tag")
+	}
+
+	err = cmd.RunGenerateForTest([]string{input, "--output", output, "--grammar", "3"})
+	if err == nil {
+		t.Fatal("expected error for unknown grammar")
+	}
+}
+
+func TestGenerateCommandCache(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "input.go", "package main\n\n"+
+		"type Payments struct {\n"+
+		"\tField string `diagram:\"name=Payments,type=service\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+	cacheDir := filepath.Join(dir, "cache")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	for range 2 {
+		if err := cmd.RunGenerateForTest([]string{input, "--output", output, "--cache-dir", cacheDir}); err != nil {
+			t.Fatalf("expected cached run to succeed: %v", err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if !strings.Contains(string(data), "Payments") {
+			t.Errorf("expected Payments in output")
+		}
+	}
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*", "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v (%v)", entries, err)
+	}
+}
+
+func TestGenerateCommandMergesPartialDeclarations(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "orders.go", "package main\n\n"+
+		"type OrderService struct {\n"+
+		"\tField string `diagram:\"name=OrderService,type=service\"`\n"+
+		"}\n\n"+
+		"type OrderDB struct {\n"+
+		"\tField string `diagram:\"name=OrderDB,type=database\"`\n"+
+		"}\n")
+	writeInputFile(t, dir, "wiring.go", "package main\n\n"+
+		"type wiring struct {\n"+
+		"\tField string `diagram:\"extends=OrderService,connectsTo=OrderDB\"`\n"+
+		"\tOther string `diagram:\"name=OrderDB,type=database\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output}); err != nil {
+		t.Fatalf("expected declarations of one component to merge: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if got := strings.Count(string(data), `value="OrderService"`); got != 1 {
+		t.Errorf("expected OrderService drawn once, got %d", got)
+	}
+
+	// An unmarked duplicate is merged but reported, so strict mode fails.
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output, "--strict"}); err == nil {
+		t.Fatal("expected strict mode to reject the unmarked duplicate")
+	}
+}
+
+func TestGenerateCommandBuildConstraints(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "core.go", "package main\n\n"+
+		"type Core struct {\n"+
+		"\tField string `diagram:\"name=Core,type=service\"`\n"+
+		"}\n")
+	writeInputFile(t, dir, "epoll_linux.go", "package main\n\n"+
+		"type Epoll struct {\n"+
+		"\tField string `diagram:\"name=Epoll,type=service\"`\n"+
+		"}\n")
+	writeInputFile(t, dir, "mock.go", "// Code generated by mockgen. DO NOT EDIT.\n\npackage main\n\n"+
+		"type Mock struct {\n"+
+		"\tField string `diagram:\"name=Mock,type=service\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output, "--goos", "windows", "--tags", "a,b", "--skip-generated"}); err != nil {
+		t.Fatalf("expected platform run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), `value="Core"`) ||
+		strings.Contains(string(data), `value="Epoll"`) || strings.Contains(string(data), `value="Mock"`) {
+		t.Errorf("expected only Core on windows without generated files")
+	}
+
+	// Without the flags, every file is parsed.
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output}); err != nil {
+		t.Fatalf("expected default run to succeed: %v", err)
+	}
+	data, err = os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), `value="Epoll"`) || !strings.Contains(string(data), `value="Mock"`) {
+		t.Errorf("expected every file to be parsed by default")
+	}
+}
+
+func TestGenerateCommandWorkspace(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, module := range []string{"api", "store"} {
+		if err := os.Mkdir(filepath.Join(dir, module), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	writeInputFile(t, dir, "go.work", "go 1.22\n\nuse (\n\t./api\n\t./store\n)\n")
+	writeInputFile(t, dir, filepath.Join("api", "go.mod"), "module example.com/api\n")
+	writeInputFile(t, dir, filepath.Join("api", "api.go"), "package api\n\n"+
+		"type API struct {\n"+
+		"\tField string `diagram:\"name=API,connectsTo=Store\"`\n"+
+		"}\n")
+	writeInputFile(t, dir, filepath.Join("store", "go.mod"), "module example.com/store\n")
+	writeInputFile(t, dir, filepath.Join("store", "store.go"), "package store\n\n"+
+		"type Store struct {\n"+
+		"\tField string `diagram:\"name=Store,type=database\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{filepath.Join(dir, "go.work"), "--output", output, "--module-groups", "page"}); err != nil {
+		t.Fatalf("expected workspace run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, page := range []string{`name="example.com/api"`, `name="example.com/store"`} {
+		if !strings.Contains(string(data), page) {
+			t.Errorf("expected a page %s", page)
+		}
+	}
+
+	if err := cmd.RunGenerateForTest([]string{filepath.Join(dir, "go.work"), "--output", output, "--module-groups", "package"}); err == nil {
+		t.Fatal("expected error for unknown module grouping")
+	}
+}
+
+func TestGenerateCommandModelFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "api.go", "package api\n\n"+
+		"type API struct {\n"+
+		"\tField string `diagram:\"name=API\"`\n"+
+		"}\n")
+	writeInputFile(t, dir, "saas.diagram.yaml", "components:\n"+
+		"  - name: Stripe\n"+
+		"    type: external\n"+
+		"connections:\n"+
+		"  - source: API\n"+
+		"    target: Stripe\n")
+	invalid := writeInputFile(t, dir, "invalid.json", `{"components": [{"type": "service"}]}`)
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output}); err != nil {
+		t.Fatalf("expected model file run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), `value="Stripe"`) {
+		t.Error("expected the Stripe component of the model file")
+	}
+
+	if err := cmd.RunGenerateForTest([]string{dir, invalid, "--output", output, "--strict"}); err == nil {
+		t.Fatal("expected strict mode to fail on an invalid model file")
+	}
+}
+
+func TestSchemaCommand(t *testing.T) {
+	t.Parallel()
+
+	if err := runCmd(t, "", "schema"); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+}
+
+func TestGenerateCommandKubernetes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "app.yaml", "apiVersion: v1\n"+
+		"kind: Service\n"+
+		"metadata:\n  name: web\n  namespace: shop\n"+
+		"spec:\n  selector:\n    app: web\n"+
+		"---\n"+
+		"apiVersion: apps/v1\n"+
+		"kind: Deployment\n"+
+		"metadata:\n  name: web\n  namespace: shop\n"+
+		"spec:\n  template:\n    metadata:\n      labels:\n        app: web\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output}); err == nil {
+		t.Fatal("expected manifests to be ignored without --kubernetes")
+	}
+
+	if err := cmd.RunGenerateForTest([]string{dir, "--output", output, "--kubernetes", "--layout", "isometric"}); err != nil {
+		t.Fatalf("expected kubernetes run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if !strings.Contains(string(data), `value="shop"`) {
+		t.Error("expected a swimlane for the shop namespace")
+	}
+}
+
+func TestGenerateCommandOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "users.go", "package users\n\n"+
+		"type UserService struct {\n"+
+		"\tField string `diagram:\"name=UserService\"`\n"+
+		"}\n")
+	spec := writeInputFile(t, dir, "openapi.json", `{"openapi": "3.0.3", "info": {"title": "Users API"},
+"tags": [{"name": "profiles"}],
+"paths": {"/users": {"get": {"tags": ["profiles"], "x-backend": "UserService"}}}}`)
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, spec, "--output", output, "--openapi-tags"}); err != nil {
+		t.Fatalf("expected OpenAPI run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{`value="Users API"`, `value="profiles"`, `value="GET /users"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in the output", want)
+		}
+	}
+}
+
+func TestGenerateCommandProto(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeInputFile(t, dir, "web.go", "package web\n\n"+
+		"//diagram:component\n"+
+		"type Storefront struct{}\n\n"+
+		"func (s *Storefront) dial(conn any) { _ = NewOrderServiceClient(conn) }\n")
+	proto := writeInputFile(t, dir, "orders.proto", "syntax = \"proto3\";\n"+
+		"service OrderService {\n  rpc PlaceOrder(Req) returns (Resp);\n}\n")
+	output := filepath.Join(dir, "out.drawio")
+
+	testutil.LockCLI()
+	defer testutil.UnlockCLI()
+
+	if err := cmd.RunGenerateForTest([]string{dir, proto, "--output", output, "--type", "network"}); err != nil {
+		t.Fatalf("expected proto run to succeed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{`value="OrderService"`, `value="PlaceOrder (grpc)"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in the output", want)
+		}
+	}
+}
+
+func TestConvertCommand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "legacy.drawio", `<mxfile><diagram name="Architecture Diagram"><mxGraphModel><root>
+<mxCell id="0" /><mxCell id="1" parent="0" />
+<mxCell id="2" value="Web" style="rounded=1;html=1;" vertex="1" parent="1"><mxGeometry x="40" y="60" width="120" height="60" as="geometry" /></mxCell>
+<mxCell id="3" value="Users" style="shape=cylinder3;html=1;" vertex="1" parent="1"><mxGeometry x="300" y="60" width="60" height="80" as="geometry" /></mxCell>
+<mxCell id="4" value="queries" edge="1" parent="1" source="2" target="3"><mxGeometry relative="1" as="geometry" /></mxCell>
+</root></mxGraphModel></diagram></mxfile>`)
+	model := filepath.Join(dir, "legacy.diagram.yaml")
+	output := filepath.Join(dir, "out.drawio")
+
+	if err := runCmd(t, "", "convert", input, model); err != nil {
+		t.Fatalf("convert to a model file failed: %v", err)
+	}
+	data, err := os.ReadFile(model)
+	if err != nil {
+		t.Fatalf("read model file: %v", err)
+	}
+	for _, want := range []string{"name: Web", "type: database", "style: shape=cylinder3", "x: 40", "label: queries"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in the model file:\n%s", want, data)
+		}
+	}
+
+	if err := runCmd(t, "", "convert", model, output, "--compress"); err != nil {
+		t.Fatalf("convert to .drawio failed: %v", err)
+	}
+	if err := runCmd(t, "", "convert", input, filepath.Join(dir, "out.png")); err == nil ||
+		!strings.Contains(err.Error(), "unsupported output") {
+		t.Errorf("expected an unsupported output error, got %v", err)
+	}
+}
+
+func TestConvertCommandMarkdown(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "design.md", "# Design\n\n"+
+		"```mermaid\n"+
+		"flowchart LR\n"+
+		"  subgraph backend [Backend]\n"+
+		"    api[Order API] -->|SQL| db[(Orders DB)]\n"+
+		"  end\n"+
+		"```\n\n"+
+		"```plantuml\n"+
+		"@startuml\n"+
+		"actor Customer\n"+
+		"Customer --> api : orders\n"+
+		"@enduml\n"+
+		"```\n")
+	output := filepath.Join(dir, "design.drawio")
+
+	if err := runCmd(t, "", "convert", input, output); err != nil {
+		t.Fatalf("convert failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{`value="Order API"`, `value="Orders DB"`, `value="Customer"`, `value="Backend"`, `value="SQL"`, `value="orders"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in the output", want)
+		}
+	}
+}
+
+func TestGenerateCommandDOT(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "svc.go", "package svc\n\n"+
+		"type Orders struct {\n"+
+		"\tField string `diagram:\"type=service,name=Orders\"`\n"+
+		"}\n")
+	deps := writeInputFile(t, dir, "deps.dot", `digraph deps {
+	Ledger [shape=cylinder, label="Ledger DB"]
+	Orders -> Ledger [label="writes"]
+}`)
+	output := filepath.Join(dir, "arch.gv")
+
+	if err := runCmd(t, "", "generate", input, deps, "-o", output); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{
+		`digraph "Architecture Diagram" {`,
+		`"Ledger" [label="Ledger DB", shape=cylinder`,
+		`"Orders" -> "Ledger" [label="writes"];`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in the output:\n%s", want, data)
+		}
+	}
+
+	if err := runCmd(t, "", "convert", output, filepath.Join(dir, "arch.drawio")); err != nil {
+		t.Fatalf("convert from DOT failed: %v", err)
+	}
+}
+
+func TestGenerateCommandMermaid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := writeInputFile(t, dir, "svc.go", "package svc\n\n"+
+		"type Orders struct {\n"+
+		"\tField string `diagram:\"type=service,name=Orders,connectsTo=Ledger\"`\n"+
+		"}\n\n"+
+		"type Ledger struct {\n"+
+		"\tField string `diagram:\"type=database,name=Ledger,page=Storage\"`\n"+
+		"}\n")
+	output := filepath.Join(dir, "architecture.md")
+
+	// --page is reset, since flags keep their values between runs.
+	if err := runCmd(t, "", "generate", input, "-o", output, "--page", ""); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	for _, want := range []string{
+		"```mermaid\n---\ntitle: \"Architecture Diagram\"\n---\nflowchart TB\n",
+		"    Orders([\"Orders\"]):::service\n",
+		"```mermaid\n---\ntitle: \"Storage\"\n---\nflowchart TB\n    Ledger[(\"Ledger\")]\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in the output:\n%s", want, data)
+		}
+	}
+
+	if err := runCmd(t, "", "convert", output, filepath.Join(dir, "architecture.mmd")); err != nil {
+		t.Fatalf("convert to Mermaid failed: %v", err)
+	}
+}
@@ -6,23 +6,29 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var rootCmd = &cobra.Command{
-	Use:   "diagram-gen",
-	Short: "Generate software diagrams from code annotations",
-	Long: `A CLI tool that parses Go source code annotations and generates 
-draw.io compatible diagrams. Supports architecture, flowchart, and 
-network diagram types.`,
+// newRootCmd builds the command tree. Each build binds the flag variables
+// to their defaults, so every execution starts from fresh flag state.
+func newRootCmd() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:   "diagram-gen",
+		Short: "Generate software diagrams from code annotations",
+		Long: `A CLI tool that parses Go source code annotations and JSON or YAML
+model files and generates draw.io compatible diagrams, Graphviz DOT
+or Mermaid flowcharts.
+Supports architecture, flowchart, and network diagram types, and converts
+existing .drawio and .dot files into model files.`,
+	}
+	rootCmd.AddCommand(buildGenerateCmd())
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(buildConvertCmd())
+	return rootCmd
 }
 
 // Execute runs the root command.
 func Execute() error {
-	if err := rootCmd.Execute(); err != nil {
+	if err := newRootCmd().Execute(); err != nil {
 		return fmt.Errorf("failed to execute command: %w", err)
 	}
 	return nil
 }
-
-func init() {
-	rootCmd.AddCommand(generateCmd)
-	rootCmd.AddCommand(versionCmd)
-}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"diagram-gen/internal/modelfile"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of model files",
+	Long: `Prints the JSON Schema that .json and .yaml model files given to
+generate are validated against. Point an editor at it for completion:
+
+  diagram-gen schema > diagram.schema.json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if _, err := cmd.OutOrStdout().Write(modelfile.Schema()); err != nil {
+			return fmt.Errorf("failed to write schema: %w", err)
+		}
+		return nil
+	},
+}
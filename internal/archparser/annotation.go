@@ -3,9 +3,12 @@ package archparser
 
 import (
 	"fmt"
+	"net/netip"
+	"strconv"
 	"strings"
 
 	"diagram-gen/internal/model"
+	"diagram-gen/internal/validator"
 )
 
 // Annotation represents a parsed diagram annotation.
@@ -14,102 +17,326 @@ type Annotation struct {
 	ComponentType model.ComponentType
 	Name          string
 	ConnectsTo    []string
-	Description   string
-	Direction     model.ConnectionDirection
-	Shape         model.ShapeType
-	Page          string
-	Swimlane      string
-	Style         string
-	EdgeStyle     string
-	StartArrow    string
-	EndArrow      string
+	// TargetAttrs holds the bracketed attributes of each ConnectsTo entry,
+	// by index, as in connectsTo=OrderDB[label=SQL,dashed].
+	TargetAttrs []ConnectionAttrs
+	Description string
+	Direction   model.ConnectionDirection
+	Shape       model.ShapeType
+	Page        string
+	Swimlane    string
+	Style       string
+	EdgeStyle   string
+	StartArrow  string
+	EndArrow    string
+	Zone        string
+	Subnet      string
+	CIDR        string
+	Port        string
+	Protocol    string
+	// Partial marks an annotation that adds to a component declared
+	// elsewhere. Extends names that component and implies Partial.
+	Partial bool
+	Extends string
+}
+
+// ConnectionAttrs are per-connection settings written in brackets after a
+// connectsTo target. Empty fields fall back to the annotation's own settings.
+type ConnectionAttrs struct {
+	Label      string
+	Direction  model.ConnectionDirection
+	EdgeStyle  string
+	StartArrow string
+	EndArrow   string
+	Protocol   string
+	Port       string
+	Style      string
 }
 
 // ParseAnnotation parses a diagram annotation string.
 func ParseAnnotation(tag string) (*Annotation, error) {
+	ann, _, err := parseAnnotation(tag)
+	return ann, err
+}
+
+// ParseAnnotationGrammar parses a diagram annotation string with the given grammar.
+// Syntax errors are returned as *SyntaxError.
+func ParseAnnotationGrammar(tag string, grammar Grammar) (*Annotation, error) {
+	ann, _, err := parseNamedAnnotation(tag, "", grammar)
+	if ann != nil {
+		ann.applyDefaults(nil)
+	}
+	return ann, err
+}
+
+// parseAnnotation parses a diagram annotation string and also returns
+// warnings for parts that were ignored, such as unknown keys.
+func parseAnnotation(tag string) (*Annotation, []string, error) {
+	ann, warnings, err := parseNamedAnnotation(tag, "", GrammarAuto)
+	if ann != nil {
+		ann.applyDefaults(nil)
+	}
+	return ann, warnings, err
+}
+
+// parseNamedAnnotation is parseAnnotation with a fallback name used when the
+// annotation does not set one, such as the identifier a directive documents.
+// The type is left empty when not written, so package defaults can fill it
+// in; see applyDefaults.
+func parseNamedAnnotation(tag, fallbackName string, grammar Grammar) (*Annotation, []string, error) {
+	// A bare directive such as //diagram:component only names the component.
+	if tag == "" && fallbackName != "" {
+		return &Annotation{Name: fallbackName}, nil, nil
+	}
+
+	ann, warnings, err := parseAnnotationKeys(tag, grammar)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	if ann.Extends != "" {
+		if ann.Name != "" && ann.Name != ann.Extends {
+			warnings = append(warnings, fmt.Sprintf("ignoring name %q: extends=%s names the component", ann.Name, ann.Extends))
+		}
+		ann.Name = ann.Extends
+		ann.Partial = true
+	}
+	if ann.Name == "" {
+		ann.Name = fallbackName
+	}
+	if ann.Name == "" {
+		return nil, warnings, fmt.Errorf("name is required")
+	}
+
+	return ann, warnings, nil
+}
+
+// parseAnnotationKeys applies every key of an annotation string, without
+// requiring a name.
+func parseAnnotationKeys(tag string, grammar Grammar) (*Annotation, []string, error) {
 	if tag == "" {
-		return nil, fmt.Errorf("empty annotation tag")
+		return nil, nil, fmt.Errorf("empty annotation tag")
 	}
 
 	tag = strings.Trim(tag, "`")
 
-	ann := &Annotation{Raw: tag}
+	grammar, err := selectGrammar(tag, grammar)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	parts := splitKeyValuePairs(tag)
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" || part == "diagram" {
-			continue
+	var pairs []annotationPair
+	switch grammar {
+	case GrammarLegacy:
+		pairs = parseGrammarLegacy(tag)
+	case GrammarAuto:
+		pairs = parseGrammarAuto(tag)
+	default:
+		if pairs, err = parseGrammarV2(tag); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		kv := strings.SplitN(part, "=", 2)
-		if len(kv) != 2 {
-			continue
-		}
+	ann := &Annotation{Raw: tag}
+	var warnings []string
+	for _, pair := range pairs {
+		warnings = ann.set(pair, warnings)
+	}
 
-		key := strings.TrimSpace(kv[0])
-		value := strings.TrimSpace(kv[1])
-		value = strings.Trim(value, `"`)
+	if ann.ComponentType != "" && !validator.ValidateComponentType(ann.ComponentType) {
+		warnings = append(warnings, fmt.Sprintf("unknown component type %q", ann.ComponentType))
+	}
+	return ann, warnings, nil
+}
 
-		switch key {
-		case "type":
-			ann.ComponentType = model.ComponentType(value)
-		case "name":
-			ann.Name = value
-		case "connectsTo":
-			ann.ConnectsTo = strings.Split(value, ";")
-			for i := range ann.ConnectsTo {
-				ann.ConnectsTo[i] = strings.TrimSpace(ann.ConnectsTo[i])
+// set applies one key of the annotation and returns the warnings extended
+// with any problem found in it.
+func (a *Annotation) set(pair annotationPair, warnings []string) []string {
+	key, value := pair.Key, pair.Text
+	if !pair.HasValue {
+		if key != tagKey {
+			warnings = append(warnings, fmt.Sprintf("ignoring %q: expected key=value", key))
+		}
+		return warnings
+	}
+	if key != "connectsTo" {
+		for _, item := range pair.Items {
+			if len(item.Attrs) > 0 || item.Unclosed {
+				warnings = append(warnings, fmt.Sprintf("%s: [attributes] are only allowed in connectsTo", key))
+				break
 			}
-		case "description":
-			ann.Description = value
-		case "direction":
-			ann.Direction = model.ConnectionDirection(value)
-		case "shape":
-			ann.Shape = model.ShapeType(value)
-		case "page":
-			ann.Page = value
-		case "swimlane":
-			ann.Swimlane = value
-		case "fillColor", "strokeColor", "fontColor", "gradientColor",
-			"fontSize", "strokeWidth", "opacity", "rounded",
-			"dashed", "shadow", "glass":
-			if ann.Style != "" {
-				ann.Style += ";"
+		}
+	}
+
+	switch key {
+	case grammarKey:
+		// Already used to select the grammar.
+	case "type":
+		a.ComponentType = model.ComponentType(value)
+	case "name":
+		a.Name = value
+	case "connectsTo":
+		a.ConnectsTo, a.TargetAttrs = nil, nil
+		for _, item := range pair.Items {
+			var attrs ConnectionAttrs
+			if item.Unclosed {
+				warnings = append(warnings, fmt.Sprintf("connectsTo target %q: missing closing ]", item.Value))
 			}
-			ann.Style += key + "=" + value
-		case "edgeStyle":
-			ann.EdgeStyle = value
-		case "startArrow":
-			ann.StartArrow = value
-		case "endArrow":
-			ann.EndArrow = value
+			for _, attr := range item.Attrs {
+				warnings = attrs.set(item.Value, attr, warnings)
+			}
+			a.ConnectsTo = append(a.ConnectsTo, item.Value)
+			a.TargetAttrs = append(a.TargetAttrs, attrs)
+		}
+	case "description":
+		a.Description = value
+	case "direction":
+		a.Direction = model.ConnectionDirection(value)
+	case "shape":
+		a.Shape = model.ShapeType(value)
+	case "page":
+		a.Page = value
+	case "swimlane":
+		a.Swimlane = value
+	case "fillColor", "strokeColor", "fontColor", "gradientColor",
+		"fontSize", "strokeWidth", "opacity", "rounded",
+		"dashed", "shadow", "glass":
+		a.Style = mergeStyle(a.Style, key+"="+value)
+	case "edgeStyle":
+		a.EdgeStyle = value
+	case "startArrow":
+		a.StartArrow = value
+	case "endArrow":
+		a.EndArrow = value
+	case "zone":
+		a.Zone = value
+	case "subnet":
+		a.Subnet = value
+	case "cidr":
+		if _, err := netip.ParsePrefix(value); err != nil {
+			warnings = append(warnings, fmt.Sprintf("invalid cidr %q", value))
 		}
+		a.CIDR = value
+	case "port":
+		if !validPort(value) {
+			warnings = append(warnings, fmt.Sprintf("invalid port %q: expected a number or range from 1 to 65535", value))
+		}
+		a.Port = value
+	case "protocol":
+		a.Protocol = value
+	case "partial":
+		partial, err := strconv.ParseBool(value)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("invalid partial %q: expected true or false", value))
+		}
+		a.Partial = partial
+	case "extends":
+		a.Extends = value
+	default:
+		warnings = append(warnings, fmt.Sprintf("unknown annotation key %q", key))
 	}
+	return warnings
+}
 
-	if ann.Name == "" {
-		return nil, fmt.Errorf("name is required")
+// validPort reports whether s is a port number or a low-high port range.
+func validPort(s string) bool {
+	low, high, isRange := strings.Cut(s, "-")
+	lowPort, err := strconv.Atoi(low)
+	if err != nil || lowPort < 1 || lowPort > 65535 {
+		return false
 	}
+	if !isRange {
+		return true
+	}
+	highPort, err := strconv.Atoi(high)
+	return err == nil && highPort >= lowPort && highPort <= 65535
+}
 
-	if ann.ComponentType == "" {
-		ann.ComponentType = model.ComponentTypeService
+// set applies one bracketed attribute of a connectsTo target. Bare style
+// flags such as dashed stand for flag=1.
+func (c *ConnectionAttrs) set(target string, attr annotationPair, warnings []string) []string {
+	value := attr.Text
+
+	switch attr.Key {
+	case "label":
+		c.Label = value
+	case "direction":
+		c.Direction = model.ConnectionDirection(value)
+	case "edgeStyle":
+		c.EdgeStyle = value
+	case "startArrow":
+		c.StartArrow = value
+	case "endArrow":
+		c.EndArrow = value
+	case "protocol":
+		c.Protocol = value
+	case "port":
+		if !validPort(value) {
+			warnings = append(warnings, fmt.Sprintf("connectsTo target %q: invalid port %q", target, value))
+		}
+		c.Port = value
+	case "dashed", "curved", "rounded", "shadow",
+		"strokeColor", "strokeWidth", "fontColor", "fontSize", "opacity":
+		if !attr.HasValue {
+			value = "1"
+		}
+		if c.Style != "" {
+			c.Style += ";"
+		}
+		c.Style += attr.Key + "=" + value
+	default:
+		warnings = append(warnings, fmt.Sprintf("connectsTo target %q: unknown attribute %q", target, attr.Key))
 	}
+	return warnings
+}
 
-	return ann, nil
+// splitOutsideBrackets splits s at sep, except inside [...].
+func splitOutsideBrackets(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
 }
 
 func splitKeyValuePairs(s string) []string {
 	isInValue := false
 
 	insideValue := make(map[int]bool)
+	depth := 0
 	for i := 0; i < len(s); i++ {
+		// Separators inside connectsTo brackets belong to the value.
+		switch {
+		case s[i] == '[':
+			depth++
+		case s[i] == ']' && depth > 0:
+			depth--
+		}
+		if depth > 0 {
+			insideValue[i] = true
+			continue
+		}
+
 		if isInValue && s[i] == ';' {
 			insideValue[i] = true
 		}
 
 		if s[i] == '=' {
 			isInValue = true
-		} else if isInValue && (s[i] == ',' || s[i] == ';') {
+		} else if isInValue && s[i] == ',' {
 			isInValue = false
 		}
 	}
@@ -120,7 +347,7 @@ func splitKeyValuePairs(s string) []string {
 	for i := 0; i < len(s); i++ {
 		c := s[i]
 
-		if c == ';' && insideValue[i] {
+		if (c == ';' || c == ',') && insideValue[i] {
 			current.WriteByte(c)
 			continue
 		}
@@ -156,6 +383,12 @@ func (a *Annotation) ToComponent() model.Component {
 		Page:        a.Page,
 		Swimlane:    a.Swimlane,
 		Style:       a.Style,
+		Zone:        a.Zone,
+		Subnet:      a.Subnet,
+		CIDR:        a.CIDR,
+		Port:        a.Port,
+		Protocol:    a.Protocol,
+		Partial:     a.Partial,
 	}
 }
 
@@ -167,11 +400,11 @@ func (a *Annotation) ToConnections() []model.Connection {
 		direction = model.ConnectionDirectionUnidirectional
 	}
 
-	for _, target := range a.ConnectsTo {
+	for i, target := range a.ConnectsTo {
 		if target == "" {
 			continue
 		}
-		connections = append(connections, model.Connection{
+		conn := model.Connection{
 			Source:     a.Name,
 			Target:     target,
 			Direction:  direction,
@@ -179,7 +412,31 @@ func (a *Annotation) ToConnections() []model.Connection {
 			StartArrow: a.StartArrow,
 			EndArrow:   a.EndArrow,
 			Page:       a.Page,
-		})
+		}
+		if i < len(a.TargetAttrs) {
+			a.TargetAttrs[i].apply(&conn)
+		}
+		connections = append(connections, conn)
 	}
 	return connections
 }
+
+// apply overrides the connection settings that the attributes set.
+func (c ConnectionAttrs) apply(conn *model.Connection) {
+	conn.Label = c.Label
+	conn.Protocol = c.Protocol
+	conn.Port = c.Port
+	conn.Style = c.Style
+	if c.Direction != "" {
+		conn.Direction = c.Direction
+	}
+	if c.EdgeStyle != "" {
+		conn.EdgeStyle = c.EdgeStyle
+	}
+	if c.StartArrow != "" {
+		conn.StartArrow = c.StartArrow
+	}
+	if c.EndArrow != "" {
+		conn.EndArrow = c.EndArrow
+	}
+}
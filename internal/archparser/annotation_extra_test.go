@@ -1,6 +1,7 @@
 package archparser_test
 
 import (
+	"strings"
 	"testing"
 
 	"diagram-gen/internal/archparser"
@@ -144,3 +145,129 @@ func TestParseAnnotationInvalid(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAnnotationNetworkFields(t *testing.T) {
+	t.Parallel()
+
+	ann, err := archparser.ParseAnnotation(`type=database,name=OrderDB,zone=private,subnet=db-a,cidr=10.0.2.0/24,port=5432,protocol=tcp`)
+	if err != nil {
+		t.Fatalf("ParseAnnotation error: %v", err)
+	}
+
+	comp := ann.ToComponent()
+	want := model.Component{
+		Type:     model.ComponentTypeDatabase,
+		Name:     "OrderDB",
+		Zone:     "private",
+		Subnet:   "db-a",
+		CIDR:     "10.0.2.0/24",
+		Port:     "5432",
+		Protocol: "tcp",
+	}
+	if comp != want {
+		t.Errorf("ToComponent() = %+v, want %+v", comp, want)
+	}
+}
+
+func TestParseNetworkFieldWarnings(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"net.go": "package net\n\n" +
+			"type LB struct {\n" +
+			"\tField string `diagram:\"name=LB,cidr=10.0.0.0/33,port=443\"`\n" +
+			"}\n\n" +
+			"type Web struct {\n" +
+			"\tField string `diagram:\"name=Web,port=8080-80\"`\n" +
+			"}\n\n" +
+			"type Range struct {\n" +
+			"\tField string `diagram:\"name=Range,port=30000-32767\"`\n" +
+			"}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 3 {
+		t.Errorf("invalid values should only warn, got %d components", len(diagram.Components))
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Message, `invalid cidr "10.0.0.0/33"`) {
+		t.Errorf("unexpected first diagnostic %v", diags[0])
+	}
+	if !strings.Contains(diags[1].Message, `invalid port "8080-80"`) {
+		t.Errorf("unexpected second diagnostic %v", diags[1])
+	}
+}
+
+func TestParseAnnotationConnectionAttributes(t *testing.T) {
+	t.Parallel()
+
+	ann, warnings := parseWithWarnings(t, `name=Orders,connectsTo=OrderDB[label=SQL,dashed,protocol=tcp,port=5432];Queue[label=publishes,direction=unidirectional,endArrow=open];Cache,direction=bidirectional`)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	want := []model.Connection{
+		{Source: "Orders", Target: "OrderDB", Direction: model.ConnectionDirectionBidirectional, Label: "SQL", Protocol: "tcp", Port: "5432", Style: "dashed=1"},
+		{Source: "Orders", Target: "Queue", Direction: model.ConnectionDirectionUnidirectional, Label: "publishes", EndArrow: "open"},
+		{Source: "Orders", Target: "Cache", Direction: model.ConnectionDirectionBidirectional},
+	}
+	got := ann.ToConnections()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d connections, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("connection %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseAnnotationConnectionAttributeWarnings(t *testing.T) {
+	t.Parallel()
+
+	ann, warnings := parseWithWarnings(t, `name=Orders,connectsTo=OrderDB[colour=red,port=http];Queue[label=x`)
+	if len(ann.ConnectsTo) != 2 || ann.ConnectsTo[1] != "Queue" {
+		t.Errorf("ConnectsTo = %v, want [OrderDB Queue]", ann.ConnectsTo)
+	}
+
+	joined := strings.Join(warnings, "\n")
+	for _, want := range []string{`unknown attribute "colour"`, `invalid port "http"`, `"Queue": missing closing ]`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected a warning containing %s, got:\n%s", want, joined)
+		}
+	}
+}
+
+// parseWithWarnings parses tag from a struct field and returns the annotation
+// and the messages of the diagnostics reported for it.
+func parseWithWarnings(t *testing.T, tag string) (*archparser.Annotation, []string) {
+	t.Helper()
+
+	ann, err := archparser.ParseAnnotation(tag)
+	if err != nil {
+		t.Fatalf("ParseAnnotation error: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\ntype A struct {\n\tField string `diagram:\"" + tag + "\"`\n}\n",
+	})
+	p := archparser.New()
+	if _, err := p.Parse(dir); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var warnings []string
+	for _, d := range p.Diagnostics() {
+		warnings = append(warnings, d.Message)
+	}
+	return ann, warnings
+}
@@ -1,20 +1,66 @@
 package archparser
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"diagram-gen/internal/kubernetes"
 	"diagram-gen/internal/model"
+	"diagram-gen/internal/protobuf"
 )
 
 // Parser parses Go source files for diagram annotations.
 type Parser struct {
-	fset *token.FileSet
+	// Include limits parsed files to those matching at least one glob.
+	Include []string
+	// Exclude skips files and directories matching any glob.
+	Exclude []string
+	// InferConnections adds connections between annotated types that
+	// reference each other through struct fields or NewX constructor parameters.
+	InferConnections bool
+	// ExternalImports controls how ParseImportGraph shows imports from
+	// outside the module. The zero value collapses them.
+	ExternalImports ExternalImports
+	// Grammar selects the annotation syntax. The zero value is GrammarAuto.
+	Grammar Grammar
+	// Workers bounds how many files are parsed at once. Zero or less uses
+	// runtime.GOMAXPROCS(0); results do not depend on the worker count.
+	Workers int
+	// GOOS, GOARCH and BuildTags select a platform variant of the diagram.
+	// When any is set, files found in directories are skipped unless their
+	// //go:build lines and _GOOS/_GOARCH file name suffixes match; an empty
+	// GOOS or GOARCH means the host's. By default every file is parsed.
+	GOOS      string
+	GOARCH    string
+	BuildTags []string
+	// SkipGenerated skips files found in directories that carry a
+	// "// Code generated ... DO NOT EDIT." header.
+	SkipGenerated bool
+	// ModuleGroups groups components by the Go module declaring them. The
+	// zero value keeps the swimlanes and pages of the annotations.
+	ModuleGroups ModuleGrouping
+	// Kubernetes also picks up Kubernetes manifests, YAML files declaring
+	// apiVersion and kind, while listing or walking directories. Manifests
+	// named explicitly are always read.
+	Kubernetes bool
+	// OpenAPITags adds a component per tag of an OpenAPI document, holding
+	// the operations tagged with it, next to the component of the API.
+	OpenAPITags bool
+	// CacheDir, when set, stores the result of every parsed file on disk
+	// keyed by its content, path and the parser configuration, so unchanged
+	// files are not parsed again by later runs. See DefaultCacheDir.
+	CacheDir string
+
+	fset        *token.FileSet
+	diagnostics Diagnostics
 }
 
 // New creates a new Parser.
@@ -24,115 +70,496 @@ func New() *Parser {
 	}
 }
 
+// fileResult holds everything extracted from a single file.
+type fileResult struct {
+	// Path is the file the result was extracted from.
+	Path string
+	// Package identifies the package of the file by directory and name.
+	Package string
+	// Annotations holds the accepted annotations, before package defaults
+	// are applied, and Positions where each was declared.
+	Annotations []Annotation
+	Positions   []token.Position
+	// Defaults holds the package defaults set in the file, if any.
+	Defaults    *Annotation
+	DefaultsPos token.Position
+	Diagnostics Diagnostics
+	// Types maps the names of the file's Go types to the component declared on them.
+	Types map[string]string
+	// References lists the types each Go type depends on, for inference.
+	References []typeReference
+	// Flowcharts holds one page per function annotated with //diagram:flowchart.
+	Flowcharts []model.Page
+	// Components and Connections hold the contents of a model file.
+	Components  []model.Component
+	Connections []model.Connection
+	// Linked lists the components a Mermaid, PlantUML or Markdown file only
+	// links to. They stay partial only when another file declares them.
+	Linked []string
+	// Resources holds the resources of a Kubernetes manifest, which are
+	// resolved against those of the other manifests.
+	Resources []kubernetes.Resource
+	// ProtoServices holds the gRPC services of a .proto file, and Clients
+	// the services whose clients the Go types of a file create.
+	ProtoServices []protobuf.Service
+	Clients       []clientCall
+	// OptionalConnections hold connections kept only when their target is
+	// declared, such as those to the servers of an OpenAPI document.
+	OptionalConnections []model.Connection
+}
+
+// fileParser extracts annotations from one parsed file.
+type fileParser struct {
+	fset    *token.FileSet
+	grammar Grammar
+	pkg     string
+	imports map[string]string
+	result  *fileResult
+}
+
 // ParseFile parses a single Go file for diagram annotations.
+// Connection inference needs every file of a run and is only applied by Parse and ParseDirectory.
 func (p *Parser) ParseFile(path string) (*model.Diagram, error) {
-	f, err := parser.ParseFile(p.fset, path, nil, parser.ParseComments)
+	if err := ValidateModuleGrouping(p.ModuleGroups); err != nil {
+		return nil, err
+	}
+
+	result, err := p.parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.diagnostics = append(p.diagnostics, result.Diagnostics...)
+	diagram := p.buildDiagram([]*fileResult{result})
+	groupByModule(diagram, p.ModuleGroups)
+	return diagram, nil
+}
+
+// parseFile extracts the annotations of one file, serving them from the
+// cache when CacheDir is set and the file is unchanged.
+func (p *Parser) parseFile(path string) (*fileResult, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if p.CacheDir == "" {
+		return p.parseSource(path, src)
+	}
+
+	key, err := p.cacheKey(path, src)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := p.loadCached(key); ok {
+		return result, nil
+	}
+
+	result, err := p.parseSource(path, src)
+	if err != nil {
+		return nil, err
+	}
+	// The cache only saves work; a run that cannot write it still succeeds.
+	_ = p.storeCached(key, result)
+	return result, nil
+}
+
+// parseSource extracts the annotations of a file read from path, or the
+// contents of a model file or imported format.
+func (p *Parser) parseSource(path string, src []byte) (*fileResult, error) {
+	if result, ok, err := p.parseImported(path, src); ok {
+		return result, err
+	}
+
+	f, err := parser.ParseFile(p.fset, path, src, parser.ParseComments)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse file %s: %w", path, err)
 	}
 
-	diagram := &model.Diagram{
-		Type:        model.DiagramTypeArchitecture,
-		Components:  []model.Component{},
-		Connections: []model.Connection{},
+	fp := &fileParser{
+		fset:    p.fset,
+		grammar: p.Grammar,
+		pkg:     f.Name.Name,
+		imports: importPaths(f),
+		result: &fileResult{
+			Path:    path,
+			Package: filepath.Dir(path) + ":" + f.Name.Name,
+			Types:   make(map[string]string),
+		},
 	}
 
 	ast.Inspect(f, func(n ast.Node) bool {
-		typeSpec, ok := n.(*ast.TypeSpec)
-		if !ok {
-			return true
+		if target, ok := docTargetOf(n); ok {
+			fp.collectDirectives(target)
 		}
 
-		structType, ok := typeSpec.Type.(*ast.StructType)
-		if !ok {
-			return true
+		switch node := n.(type) {
+		case *ast.TypeSpec:
+			fp.collectTypeSpec(node)
+		case *ast.FuncDecl:
+			fp.collectConstructor(node)
+			fp.collectClientCalls(node)
 		}
 
-		for _, field := range structType.Fields.List {
-			if field.Tag == nil {
-				continue
-			}
+		return true
+	})
 
-			tag := ParseStructTag(field.Tag.Value, "diagram")
-			if tag == "" {
-				continue
-			}
+	return fp.result, nil
+}
 
-			ann, err := ParseAnnotation(tag)
-			if err != nil {
-				continue
-			}
+// collectTypeSpec adds the components declared by struct field tags and
+// records the field types for inference.
+func (fp *fileParser) collectTypeSpec(typeSpec *ast.TypeSpec) {
+	structType, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return
+	}
 
-			component := ann.ToComponent()
-			diagram.AddComponent(component)
+	for _, field := range structType.Fields.List {
+		fp.addReference(typeSpec.Name.Name, field.Type)
 
-			connections := ann.ToConnections()
-			for _, conn := range connections {
-				diagram.AddConnection(conn)
-			}
+		if field.Tag == nil {
+			continue
 		}
 
-		return true
-	})
+		fp.collectStructTag(typeSpec.Name.Name, field.Tag)
+	}
+}
 
-	return diagram, nil
+// collectStructTag adds the component declared by the diagram key of a struct field tag.
+func (fp *fileParser) collectStructTag(owner string, lit *ast.BasicLit) {
+	tag, found, err := lookupStructTag(lit.Value, tagKey)
+	if err != nil {
+		switch {
+		case found:
+			fp.report(lit.Pos(), SeverityWarning, lit.Value, "%v", err)
+		case strings.Contains(lit.Value, tagKey+":"):
+			fp.report(lit.Pos(), SeverityError, lit.Value, "annotation ignored: %v", err)
+		}
+	}
+	if !found {
+		return
+	}
+	if tag == "" {
+		fp.report(lit.Pos(), SeverityWarning, lit.Value, "empty %s tag", tagKey)
+		return
+	}
+
+	ann, warnings, err := parseNamedAnnotation(tag, "", fp.grammar)
+	fp.addAnnotation(owner, lit.Pos(), tag, ann, warnings, fp.syntaxPos(err, func(offset int) token.Pos {
+		return lit.Pos() + token.Pos(structTagOffset(lit.Value, tagKey, offset))
+	}))
 }
 
-// ParseDirectory parses all Go files in a directory.
-func (p *Parser) ParseDirectory(dirPath string) (*model.Diagram, error) {
+// collectDirectives adds the components declared by //diagram: directives in a doc comment.
+func (fp *fileParser) collectDirectives(target docTarget) {
+	for _, d := range parseDirectives(target.Doc) {
+		switch d.Kind {
+		case directiveComponent:
+			ann, warnings, err := parseNamedAnnotation(d.Text, target.Name, fp.grammar)
+			fp.addAnnotation(target.Type, d.Pos, d.Text, ann, warnings, fp.syntaxPos(err, d.textPos))
+		case directiveFlowchart:
+			fp.collectFlowchart(target, d)
+		case directiveDefaults:
+			fp.collectDefaults(target, d)
+		default:
+			fp.report(d.Pos, SeverityWarning, d.Text, "unknown directive %q", directivePrefix+d.Kind)
+		}
+	}
+}
+
+// collectFlowchart builds the flowchart of a function annotated with //diagram:flowchart.
+func (fp *fileParser) collectFlowchart(target docTarget, d directive) {
+	if target.Func == nil || target.Func.Body == nil {
+		fp.report(d.Pos, SeverityWarning, d.Text, "%s must document a function with a body", directivePrefix+d.Kind)
+		return
+	}
+
+	// A bare //diagram:flowchart needs no options; the function name is enough.
+	text := d.Text
+	if strings.TrimSpace(text) == "" {
+		text = "name=" + target.Name
+	}
+
+	ann, warnings, err := parseNamedAnnotation(text, target.Name, fp.grammar)
+	for _, warning := range warnings {
+		fp.report(d.Pos, SeverityWarning, d.Text, "%s", warning)
+	}
+	if err != nil {
+		err = fp.syntaxPos(err, d.textPos)
+		fp.report(errorPos(d.Pos, err), SeverityError, d.Text, "flowchart ignored: %v", err)
+		return
+	}
+
+	// An explicit name identifies the flowchart; the default one is
+	// qualified, since handlers of several packages often share it.
+	id := ann.Name
+	if id == target.Name {
+		id = funcID(fp.pkg, target.Func)
+	}
+	fp.result.Flowcharts = append(fp.result.Flowcharts, buildFlowchart(fp.fset, target.Func, ann, id))
+}
+
+// addAnnotation records the diagnostics for a parsed annotation and, when it
+// was accepted, the annotation itself. owner is the Go type the annotation
+// is attached to, if any.
+func (fp *fileParser) addAnnotation(owner string, pos token.Pos, raw string, ann *Annotation, warnings []string, err error) {
+	for _, warning := range warnings {
+		fp.report(pos, SeverityWarning, raw, "%s", warning)
+	}
+	if err != nil {
+		fp.report(errorPos(pos, err), SeverityError, raw, "annotation ignored: %v", err)
+		return
+	}
+
+	fp.result.Annotations = append(fp.result.Annotations, *ann)
+	fp.result.Positions = append(fp.result.Positions, fp.fset.Position(pos))
+
+	if owner != "" {
+		if _, exists := fp.result.Types[owner]; !exists {
+			fp.result.Types[owner] = ann.Name
+		}
+	}
+}
+
+// locatedError is a syntax error placed at its exact source position.
+type locatedError struct {
+	Pos token.Pos
+	Err *SyntaxError
+}
+
+func (e *locatedError) Error() string { return e.Err.Msg }
+
+func (e *locatedError) Unwrap() error { return e.Err }
+
+// syntaxPos attaches the source position of a syntax error, found with at
+// from its offset in the annotation text. Other errors are returned unchanged.
+func (fp *fileParser) syntaxPos(err error, at func(offset int) token.Pos) error {
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) {
+		return err
+	}
+	return &locatedError{Pos: at(syntax.Offset), Err: syntax}
+}
+
+// errorPos is the position to report err at: its own for located syntax
+// errors, pos otherwise.
+func errorPos(pos token.Pos, err error) token.Pos {
+	var located *locatedError
+	if errors.As(err, &located) {
+		return located.Pos
+	}
+	return pos
+}
+
+func (fp *fileParser) report(pos token.Pos, severity Severity, raw, format string, args ...any) {
+	fp.result.Diagnostics = append(fp.result.Diagnostics,
+		newDiagnostic(fp.fset, pos, severity, raw, format, args...))
+}
+
+// buildDiagram converts the annotations of every file, with the defaults of
+// their package applied, tags the components with their module, and merges
+// the components declared more than once.
+func (p *Parser) buildDiagram(results []*fileResult) *model.Diagram {
 	diagram := &model.Diagram{
 		Type:        model.DiagramTypeArchitecture,
 		Components:  []model.Component{},
 		Connections: []model.Connection{},
 	}
 
-	entries, err := os.ReadDir(dirPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+	defaults := p.packageDefaults(results)
+	modules := make(moduleIndex)
+	var positions []token.Position
+	var resources []kubernetes.Resource
+	var resourceModules []string
+	var resourcePositions []token.Position
+	var optional []model.Connection
+	var services []protobuf.Service
+	var serviceModules []string
+	var servicePositions []token.Position
+	linked := make(map[int]bool)
+	for _, result := range results {
+		var module string
+		if mod := modules.moduleOf(filepath.Dir(result.Path)); mod != nil {
+			module = mod.Path
+		}
+		for _, ann := range result.Annotations {
+			ann.applyDefaults(defaults[result.Package])
+			comp := ann.ToComponent()
+			comp.Module = module
+			diagram.AddComponent(comp)
+			for _, conn := range ann.ToConnections() {
+				diagram.AddConnection(conn)
+			}
+		}
+		positions = append(positions, result.Positions...)
+
+		for _, comp := range result.Components {
+			if comp.Module == "" {
+				comp.Module = module
+			}
+			if comp.Partial && slices.Contains(result.Linked, comp.Name) {
+				linked[len(diagram.Components)] = true
+			}
+			diagram.AddComponent(comp)
+			positions = append(positions, token.Position{Filename: result.Path})
+		}
+		diagram.Connections = append(diagram.Connections, result.Connections...)
+		optional = append(optional, result.OptionalConnections...)
+
+		for _, svc := range result.ProtoServices {
+			services = append(services, svc)
+			serviceModules = append(serviceModules, module)
+			servicePositions = append(servicePositions, token.Position{Filename: result.Path, Line: svc.Line})
+		}
+
+		for _, res := range result.Resources {
+			resources = append(resources, res)
+			resourceModules = append(resourceModules, module)
+			resourcePositions = append(resourcePositions, token.Position{Filename: result.Path, Line: res.Line})
+		}
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
-			continue
+	// Manifests refer to each other, so they are resolved once all are read.
+	manifests := kubernetes.Build(resources)
+	for i, comp := range manifests.Components {
+		comp.Module = resourceModules[i]
+		diagram.AddComponent(comp)
+	}
+	positions = append(positions, resourcePositions...)
+	diagram.Connections = append(diagram.Connections, manifests.Connections...)
+
+	for i, svc := range services {
+		diagram.AddComponent(serviceComponent(diagram, svc, serviceModules[i]))
+	}
+	positions = append(positions, servicePositions...)
+
+	for _, conn := range optional {
+		if diagram.GetComponentByName(conn.Target) != nil {
+			diagram.AddConnection(conn)
 		}
+	}
 
-		filePath := filepath.Join(dirPath, entry.Name())
-		fileDiagram, err := p.ParseFile(filePath)
-		if err != nil {
-			continue
+	resolveLinked(diagram, linked)
+	p.reconcile(diagram, positions)
+	connectClients(diagram, results, services)
+	return diagram
+}
+
+// resolveLinked turns the components that text diagrams only link to into
+// declarations when no other file declares them, so that a flowchart made
+// of links alone reads without warnings and still merges with the code.
+// linked holds their indexes in Components.
+func resolveLinked(diagram *model.Diagram, linked map[int]bool) {
+	declared := make(map[string]bool)
+	for i, comp := range diagram.Components {
+		if !linked[i] {
+			declared[comp.Name] = true
+		}
+	}
+	for i := range diagram.Components {
+		if comp := &diagram.Components[i]; linked[i] && !declared[comp.Name] {
+			comp.Partial = false
+			declared[comp.Name] = true
 		}
+	}
+}
 
-		diagram.Components = append(diagram.Components, fileDiagram.Components...)
-		diagram.Connections = append(diagram.Connections, fileDiagram.Connections...)
+// reconcile merges the components declared more than once, reporting merge
+// problems at the declaration they concern. positions holds where each
+// component was declared, by index.
+func (p *Parser) reconcile(diagram *model.Diagram, positions []token.Position) {
+	for _, issue := range diagram.Reconcile() {
+		var pos token.Position
+		if issue.Index < len(positions) {
+			pos = positions[issue.Index]
+		}
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			File:     pos.Filename,
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Severity: SeverityWarning,
+			Message:  issue.Message,
+		})
 	}
+}
 
-	return diagram, nil
+// ParseDirectory parses the Go files directly inside a directory.
+func (p *Parser) ParseDirectory(dirPath string) (*model.Diagram, error) {
+	files, err := p.listGoFiles(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseFiles(context.Background(), files)
+}
+
+// Parse parses the files matched by one or more Go-style patterns for diagram annotations.
+// See ResolvePatterns for the accepted pattern forms.
+func (p *Parser) Parse(patterns ...string) (*model.Diagram, error) {
+	return p.ParseContext(context.Background(), patterns...)
+}
+
+// ParseContext is Parse with a context that stops the parse when canceled.
+func (p *Parser) ParseContext(ctx context.Context, patterns ...string) (*model.Diagram, error) {
+	files, err := p.ResolvePatterns(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parseFiles(ctx, files)
+}
+
+// ParseFlowcharts builds a flowchart diagram with one page per function
+// annotated with //diagram:flowchart in the files matched by patterns.
+func (p *Parser) ParseFlowcharts(patterns ...string) (*model.Diagram, error) {
+	return p.ParseFlowchartsContext(context.Background(), patterns...)
 }
 
-// Parse parses a file or directory for diagram annotations.
-func (p *Parser) Parse(inputPath string) (*model.Diagram, error) {
-	info, err := os.Stat(inputPath)
+// ParseFlowchartsContext is ParseFlowcharts with a context that stops the
+// parse when canceled.
+func (p *Parser) ParseFlowchartsContext(ctx context.Context, patterns ...string) (*model.Diagram, error) {
+	files, err := p.ResolvePatterns(patterns...)
 	if err != nil {
-		return nil, fmt.Errorf("cannot access input path: %w", err)
+		return nil, err
 	}
 
-	if info.IsDir() {
-		return p.ParseDirectory(inputPath)
+	results, err := p.parseAll(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	diagram := &model.Diagram{
+		Type:        model.DiagramTypeFlowchart,
+		Components:  []model.Component{},
+		Connections: []model.Connection{},
+	}
+	for _, result := range results {
+		for _, page := range result.Flowcharts {
+			diagram.Pages = append(diagram.Pages, page)
+			diagram.Components = append(diagram.Components, page.Components...)
+			diagram.Connections = append(diagram.Connections, page.Connections...)
+		}
 	}
 
-	return p.ParseFile(inputPath)
+	return diagram, nil
 }
 
-// ParseStructTag extracts a value for a key from a struct tag.
-func ParseStructTag(tagValue, key string) string {
-	tagValue = tagValue[1 : len(tagValue)-1]
-	tagValue = strings.ReplaceAll(tagValue, `\"`, `"`)
+func (p *Parser) parseFiles(ctx context.Context, files []string) (*model.Diagram, error) {
+	if err := ValidateModuleGrouping(p.ModuleGroups); err != nil {
+		return nil, err
+	}
 
-	tag := strings.TrimSpace(tagValue)
-	if strings.HasPrefix(tag, key+":") {
-		tag = strings.TrimPrefix(tag, key+":")
-		tag = strings.Trim(tag, "\"")
-		return tag
+	results, err := p.parseAll(ctx, files)
+	if err != nil {
+		return nil, err
 	}
-	return ""
+
+	diagram := p.buildDiagram(results)
+	if p.InferConnections {
+		inferConnections(diagram, results)
+	}
+	// Grouping comes last, so that inferred connections are placed too.
+	groupByModule(diagram, p.ModuleGroups)
+
+	return diagram, nil
 }
@@ -0,0 +1,114 @@
+package archparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultCacheDir is the conventional cache location, relative to the
+// directory diagram-gen runs in.
+const DefaultCacheDir = ".diagram-gen/cache"
+
+// cacheFormat is bumped whenever fileResult or the way a file is read
+// changes, so entries written by older versions are never served.
+const cacheFormat = 22
+
+// cacheConfig holds every input besides the file itself that shapes a
+// fileResult. Changing any field gives every file a new cache key.
+type cacheConfig struct {
+	Format      int     `json:"format"`
+	Version     int     `json:"grammarVersion"`
+	Grammar     Grammar `json:"grammar"`
+	OpenAPITags bool    `json:"openapiTags,omitempty"`
+}
+
+// cacheConfig returns the parser settings that key cached results.
+func (p *Parser) cacheConfig() cacheConfig {
+	grammar := p.Grammar
+	if grammar == "" {
+		grammar = GrammarAuto
+	}
+	return cacheConfig{
+		Format:      cacheFormat,
+		Version:     GrammarVersion,
+		Grammar:     grammar,
+		OpenAPITags: p.OpenAPITags,
+	}
+}
+
+// cacheKey derives the cache entry name for a file from the parser
+// configuration, the path reported in diagnostics, and the file content.
+func (p *Parser) cacheKey(path string, src []byte) (string, error) {
+	config, err := json.Marshal(p.cacheConfig())
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cache config: %w", err)
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{config, []byte(path), src} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachePath returns where the entry for key is stored. Entries are spread
+// over subdirectories named by the first two hex digits of the key.
+func (p *Parser) cachePath(key string) string {
+	return filepath.Join(p.CacheDir, key[:2], key+".json")
+}
+
+// loadCached returns the cached result for key. A missing or unreadable
+// entry is a cache miss; the file is parsed again and the entry rewritten.
+func (p *Parser) loadCached(key string) (*fileResult, bool) {
+	data, err := os.ReadFile(p.cachePath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var result fileResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	if result.Types == nil {
+		result.Types = make(map[string]string)
+	}
+	return &result, true
+}
+
+// storeCached writes result for key. The entry is written to a temporary
+// file and renamed so concurrent runs never read a partial entry.
+func (p *Parser) storeCached(key string, result *fileResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	path := p.cachePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
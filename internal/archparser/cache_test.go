@@ -0,0 +1,154 @@
+package archparser_test
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+// cacheEntries returns the paths of the entries stored in a cache directory.
+func cacheEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	var entries []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".json") {
+			entries = append(entries, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk cache: %v", err)
+	}
+	return entries
+}
+
+// poisonCache renames a component in every cache entry so a test can tell
+// results served from the cache from freshly parsed ones.
+func poisonCache(t *testing.T, dir, from, to string) {
+	t.Helper()
+	for _, entry := range cacheEntries(t, dir) {
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			t.Fatalf("read cache entry: %v", err)
+		}
+		data = bytes.ReplaceAll(data, []byte(`"Name":"`+from+`"`), []byte(`"Name":"`+to+`"`))
+		if err := os.WriteFile(entry, data, 0o644); err != nil {
+			t.Fatalf("write cache entry: %v", err)
+		}
+	}
+}
+
+func TestParseCacheMatchesUncachedParse(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeCorpus(t, root, 50)
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	parse := func(cacheDir string) (any, archparser.Diagnostics) {
+		p := archparser.New()
+		p.CacheDir = cacheDir
+		p.InferConnections = true
+		diagram, err := p.Parse(root + "/...")
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		return diagram, p.Diagnostics()
+	}
+
+	want, wantDiags := parse("")
+	for _, run := range []string{"cold", "warm"} {
+		diagram, diags := parse(cacheDir)
+		if !reflect.DeepEqual(diagram, want) {
+			t.Errorf("%s cache: diagram differs from an uncached parse", run)
+		}
+		if !reflect.DeepEqual(diags, wantDiags) {
+			t.Errorf("%s cache: diagnostics differ from an uncached parse", run)
+		}
+	}
+	if got := len(cacheEntries(t, cacheDir)); got != 50 {
+		t.Errorf("expected 50 cache entries, got %d", got)
+	}
+}
+
+func TestParseCacheInvalidation(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, ".diagram-gen", "cache")
+	source := filepath.Join(dir, "a.go")
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\ntype A struct {\n\tField string `diagram:\"name=Orders\"`\n}\n",
+	})
+
+	names := func(grammar archparser.Grammar) []string {
+		p := archparser.New()
+		p.CacheDir = cacheDir
+		p.Grammar = grammar
+		diagram, err := p.Parse(source)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		var names []string
+		for _, c := range diagram.Components {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	if got := names(archparser.GrammarAuto); !reflect.DeepEqual(got, []string{"Orders"}) {
+		t.Fatalf("first parse got %v", got)
+	}
+	poisonCache(t, dir, "Orders", "Cached")
+
+	if got := names(""); !reflect.DeepEqual(got, []string{"Cached"}) {
+		t.Errorf("unchanged file should be served from the cache, got %v", got)
+	}
+	if got := names(archparser.GrammarLegacy); !reflect.DeepEqual(got, []string{"Orders"}) {
+		t.Errorf("a grammar change should invalidate the cache, got %v", got)
+	}
+
+	if err := os.WriteFile(source, []byte("package a\n\ntype A struct {\n\tField string `diagram:\"name=Invoices\"`\n}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := names(archparser.GrammarAuto); !reflect.DeepEqual(got, []string{"Invoices"}) {
+		t.Errorf("an edited file should be parsed again, got %v", got)
+	}
+}
+
+func TestParseCacheIgnoresCorruptEntries(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\ntype A struct {\n\tField string `diagram:\"name=Orders\"`\n}\n",
+	})
+
+	p := archparser.New()
+	p.CacheDir = cacheDir
+	if _, err := p.Parse(filepath.Join(dir, "a.go")); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, entry := range cacheEntries(t, cacheDir) {
+		if err := os.WriteFile(entry, []byte("{truncated"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	p = archparser.New()
+	p.CacheDir = cacheDir
+	diagram, err := p.Parse(filepath.Join(dir, "a.go"))
+	if err != nil {
+		t.Fatalf("Parse with a corrupt cache failed: %v", err)
+	}
+	if len(diagram.Components) != 1 || diagram.Components[0].Name != "Orders" {
+		t.Errorf("expected the file to be parsed again, got %+v", diagram.Components)
+	}
+}
@@ -0,0 +1,63 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParseComposeFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"gateway/gateway.go": "package gateway\n\n" +
+			"//diagram:component type=gateway,connectsTo=orders\n" +
+			"type Gateway struct{}\n",
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component partial=true,connectsTo=orders-db\n" +
+			"type orders struct{}\n",
+		"gateway/docker-compose.yml": "services:\n" +
+			"  orders:\n" +
+			"    build: .\n" +
+			"    depends_on: [orders-db]\n" +
+			"  orders-db:\n" +
+			"    image: postgres:16\n",
+	})
+
+	// Compose files found while walking directories are left alone.
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 2 {
+		t.Errorf("walking picked up %+v, want only Gateway and orders", diagram.Components)
+	}
+
+	p = archparser.New()
+	diagram, err = p.Parse(dir+"/...", filepath.Join(dir, "gateway", "docker-compose.yml"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	for name, want := range map[string]model.ComponentType{
+		"Gateway":   model.ComponentTypeGateway,
+		"orders":    model.ComponentTypeService,
+		"orders-db": model.ComponentTypeDatabase,
+	} {
+		comp := diagram.GetComponentByName(name)
+		if comp == nil || comp.Type != want {
+			t.Errorf("component %s = %+v, want type %s", name, comp, want)
+		}
+	}
+	// The annotation of orders and the compose file both declare
+	// orders -> orders-db.
+	if len(diagram.Connections) != 2 {
+		t.Errorf("connections = %+v, want Gateway->orders and orders->orders-db", diagram.Connections)
+	}
+}
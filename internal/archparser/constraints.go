@@ -0,0 +1,67 @@
+package archparser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+)
+
+// buildContext returns the context build constraints are evaluated in, or
+// nil when no platform was selected and every file is parsed.
+func (p *Parser) buildContext() *build.Context {
+	if p.GOOS == "" && p.GOARCH == "" && len(p.BuildTags) == 0 {
+		return nil
+	}
+
+	ctxt := build.Default
+	ctxt.GOOS, ctxt.GOARCH = runtime.GOOS, runtime.GOARCH
+	if p.GOOS != "" {
+		ctxt.GOOS = p.GOOS
+	}
+	if p.GOARCH != "" {
+		ctxt.GOARCH = p.GOARCH
+	}
+	// cgo files are only kept when asked for with the cgo tag, so the
+	// result does not depend on the machine's C toolchain.
+	ctxt.CgoEnabled = false
+	ctxt.BuildTags = p.BuildTags
+	return &ctxt
+}
+
+// keepFile reports whether a file found while listing or walking a directory
+// belongs to the selected platform and, with SkipGenerated, was not generated.
+// Files that are not Go source are checked by keepImported.
+func (p *Parser) keepFile(ctxt *build.Context, path string) (bool, error) {
+	if !isGoSource(filepath.Base(path)) {
+		return keepImported(path), nil
+	}
+	if ctxt != nil {
+		match, err := ctxt.MatchFile(filepath.Dir(path), filepath.Base(path))
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate build constraints of %s: %w", path, err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+
+	if p.SkipGenerated && isGenerated(path) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// isGenerated reports whether a file has a "// Code generated ... DO NOT EDIT."
+// header. A file that cannot be read is not treated as generated, so the
+// problem is reported when the file is parsed.
+func isGenerated(path string) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	return ast.IsGenerated(f)
+}
@@ -0,0 +1,102 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+// platformComponent returns the source of an adapter component behind a
+// header of build constraints or a generated-file comment.
+func platformComponent(header, name string) string {
+	return header + "package adapters\n\n//diagram:component type=service\ntype " + name + " struct{}\n"
+}
+
+// platformTree holds files for several platforms, tags and a generated file.
+var platformTree = map[string]string{
+	"core.go":             platformComponent("", "Core"),
+	"epoll.go":            platformComponent("//go:build linux\n\n", "Epoll"),
+	"kqueue.go":           platformComponent("//go:build darwin || freebsd\n\n", "Kqueue"),
+	"iocp_windows.go":     platformComponent("", "IOCP"),
+	"simd_amd64.go":       platformComponent("", "SIMD"),
+	"sub/integration.go":  platformComponent("//go:build integration && !race\n\n", "Integration"),
+	"sub/ignored.go":      platformComponent("//go:build ignore\n\n", "Ignored"),
+	"sub/zz_generated.go": platformComponent("// Code generated by mockgen. DO NOT EDIT.\n\n", "Mock"),
+}
+
+func TestParseBuildConstraints(t *testing.T) {
+	t.Parallel()
+	all := []string{"Core", "Epoll", "IOCP", "Ignored", "Integration", "Kqueue", "Mock", "SIMD"}
+
+	tests := []struct {
+		name          string
+		goos, goarch  string
+		tags          []string
+		skipGenerated bool
+		want          []string
+	}{
+		{"no platform parses every file", "", "", nil, false, all},
+		{"linux amd64", "linux", "amd64", nil, false, []string{"Core", "Epoll", "Mock", "SIMD"}},
+		{"windows arm64", "windows", "arm64", nil, false, []string{"Core", "IOCP", "Mock"}},
+		{"darwin with tags", "darwin", "arm64", []string{"integration"}, false, []string{"Core", "Integration", "Kqueue", "Mock"}},
+		{"negated tag", "darwin", "arm64", []string{"integration", "race"}, false, []string{"Core", "Kqueue", "Mock"}},
+		{"skip generated", "", "", nil, true, []string{"Core", "Epoll", "IOCP", "Ignored", "Integration", "Kqueue", "SIMD"}},
+		{"skip generated on linux", "linux", "arm64", nil, true, []string{"Core", "Epoll"}},
+	}
+
+	dir := tempTree(t, platformTree)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := archparser.New()
+			p.GOOS, p.GOARCH, p.BuildTags = tt.goos, tt.goarch, tt.tags
+			p.SkipGenerated = tt.skipGenerated
+
+			diagram, err := p.Parse(dir + "/...")
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var names []string
+			for _, c := range diagram.Components {
+				names = append(names, c.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("components = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExplicitFileIgnoresConstraints(t *testing.T) {
+	t.Parallel()
+	dir := tempTree(t, platformTree)
+
+	p := archparser.New()
+	p.GOOS = "windows"
+	p.SkipGenerated = true
+	diagram, err := p.Parse(filepath.Join(dir, "epoll.go"), filepath.Join(dir, "sub", "zz_generated.go"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 2 {
+		t.Errorf("files named explicitly should always be parsed, got %+v", diagram.Components)
+	}
+}
+
+func TestParseMalformedBuildConstraint(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "//go:build linux &&\n\npackage a\n",
+	})
+
+	p := archparser.New()
+	p.GOOS = "linux"
+	if _, err := p.Parse(dir); err == nil {
+		t.Fatal("expected an error for a malformed //go:build line")
+	}
+}
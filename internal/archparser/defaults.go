@@ -0,0 +1,154 @@
+package archparser
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+// directiveDefaults sets package defaults from the package clause, e.g.
+//
+//	//diagram:defaults page=Billing,swimlane=Payments,fillColor=#dae8fc
+//	package billing
+const directiveDefaults = "defaults"
+
+// parseDefaults parses the text of a //diagram:defaults directive. Keys that
+// identify a single component cannot be defaulted and are reported.
+func parseDefaults(text string, grammar Grammar) (*Annotation, []string, error) {
+	defaults, warnings, err := parseAnnotationKeys(text, grammar)
+	if err != nil {
+		return nil, warnings, err
+	}
+
+	for _, key := range []struct {
+		name string
+		set  bool
+	}{
+		{"name", defaults.Name != ""},
+		{"connectsTo", len(defaults.ConnectsTo) > 0},
+		{"description", defaults.Description != ""},
+		{"extends", defaults.Extends != ""},
+		{"partial", defaults.Partial},
+		{"cidr", defaults.CIDR != ""},
+		{"port", defaults.Port != ""},
+	} {
+		if key.set {
+			warnings = append(warnings, fmt.Sprintf("ignoring %q: it cannot be a package default", key.name))
+		}
+	}
+	return defaults, warnings, nil
+}
+
+// applyDefaults fills the fields a full declaration leaves empty from its
+// package defaults, which may be nil, and defaults the type to service.
+// Partial declarations only add what they state, so they are left as written.
+func (a *Annotation) applyDefaults(defaults *Annotation) {
+	if a.Partial {
+		return
+	}
+
+	if defaults != nil {
+		fill := func(field *string, value string) {
+			if *field == "" {
+				*field = value
+			}
+		}
+		fill((*string)(&a.ComponentType), string(defaults.ComponentType))
+		fill((*string)(&a.Direction), string(defaults.Direction))
+		fill((*string)(&a.Shape), string(defaults.Shape))
+		fill(&a.Page, defaults.Page)
+		fill(&a.Swimlane, defaults.Swimlane)
+		fill(&a.EdgeStyle, defaults.EdgeStyle)
+		fill(&a.StartArrow, defaults.StartArrow)
+		fill(&a.EndArrow, defaults.EndArrow)
+		fill(&a.Zone, defaults.Zone)
+		fill(&a.Subnet, defaults.Subnet)
+		fill(&a.Protocol, defaults.Protocol)
+		a.Style = mergeStyle(defaults.Style, a.Style)
+	}
+
+	if a.ComponentType == "" {
+		a.ComponentType = model.ComponentTypeService
+	}
+}
+
+// mergeStyle merges two key=value;key=value style strings. Keys of override
+// replace those of base in place; new keys are appended.
+func mergeStyle(base, override string) string {
+	if base == "" {
+		return override
+	}
+
+	entries := strings.Split(base, ";")
+	for _, entry := range strings.Split(override, ";") {
+		if entry == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(entry, "=")
+		replaced := false
+		for i, existing := range entries {
+			if k, _, _ := strings.Cut(existing, "="); k == key {
+				entries[i], replaced = entry, true
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+	}
+	return strings.Join(entries, ";")
+}
+
+// collectDefaults records the //diagram:defaults directive of the package clause.
+func (fp *fileParser) collectDefaults(target docTarget, d directive) {
+	if target.Type != "" || target.Func != nil || target.Name != fp.pkg {
+		fp.report(d.Pos, SeverityWarning, d.Text, "%s must document the package clause", directivePrefix+d.Kind)
+		return
+	}
+	if fp.result.Defaults != nil {
+		fp.report(d.Pos, SeverityWarning, d.Text, "ignoring %s: the package clause already sets defaults", directivePrefix+d.Kind)
+		return
+	}
+
+	defaults, warnings, err := parseDefaults(d.Text, fp.grammar)
+	for _, warning := range warnings {
+		fp.report(d.Pos, SeverityWarning, d.Text, "%s", warning)
+	}
+	if err != nil {
+		err = fp.syntaxPos(err, d.textPos)
+		fp.report(errorPos(d.Pos, err), SeverityError, d.Text, "defaults ignored: %v", err)
+		return
+	}
+
+	fp.result.Defaults = defaults
+	fp.result.DefaultsPos = fp.fset.Position(d.Pos)
+}
+
+// packageDefaults returns the defaults of every package, keyed like
+// fileResult.Package. Only one file of a package may set them; the others
+// are reported and ignored.
+func (p *Parser) packageDefaults(results []*fileResult) map[string]*Annotation {
+	defaults := make(map[string]*Annotation)
+	declared := make(map[string]token.Position)
+	for _, result := range results {
+		if result.Defaults == nil {
+			continue
+		}
+		if first, exists := declared[result.Package]; exists {
+			pos := result.DefaultsPos
+			p.diagnostics = append(p.diagnostics, Diagnostic{
+				File:     pos.Filename,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("ignoring %s%s: package defaults are already set at %s", directivePrefix, directiveDefaults, first),
+				Raw:      result.Defaults.Raw,
+			})
+			continue
+		}
+		defaults[result.Package] = result.Defaults
+		declared[result.Package] = result.DefaultsPos
+	}
+	return defaults
+}
@@ -0,0 +1,124 @@
+package archparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParsePackageDefaults(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"billing/doc.go": "// Package billing charges customers.\n" +
+			"//\n" +
+			"//diagram:defaults type=database,page=Billing,swimlane=Payments,fillColor=#dae8fc,strokeColor=#6c8ebf,edgeStyle=orthogonalEdgeStyle\n" +
+			"package billing\n",
+		"billing/invoices.go": "package billing\n\n" +
+			"//diagram:component\n" +
+			"type Invoices struct{}\n\n" +
+			"type Ledger struct {\n" +
+			"\tField string `diagram:\"name=Ledger,type=service,swimlane=Accounting,fillColor=#fff,connectsTo=Invoices\"`\n" +
+			"}\n",
+		"billing/orders.go": "package billing\n\n" +
+			"//diagram:component extends=Orders,connectsTo=Invoices\n" +
+			"type orderBilling struct{}\n",
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component\n" +
+			"type Orders struct{}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	tests := []struct {
+		name     string
+		want     model.Component
+		wantEdge string
+	}{
+		{
+			name: "Invoices",
+			want: model.Component{Name: "Invoices", Type: model.ComponentTypeDatabase, Page: "Billing", Swimlane: "Payments",
+				Style: "fillColor=#dae8fc;strokeColor=#6c8ebf"},
+		},
+		{
+			name: "Ledger",
+			want: model.Component{Name: "Ledger", Type: model.ComponentTypeService, Page: "Billing", Swimlane: "Accounting",
+				Style: "fillColor=#fff;strokeColor=#6c8ebf"},
+		},
+		{
+			// Partial declarations do not take the defaults of their package.
+			name: "Orders",
+			want: model.Component{Name: "Orders", Type: model.ComponentTypeService},
+		},
+	}
+	for _, tt := range tests {
+		got := diagram.GetComponentByName(tt.name)
+		if got == nil {
+			t.Errorf("missing component %s", tt.name)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%s = %+v\nwant %+v", tt.name, *got, tt.want)
+		}
+	}
+
+	for _, conn := range diagram.Connections {
+		wantPage, wantStyle := "", ""
+		if conn.Source == "Ledger" {
+			wantPage, wantStyle = "Billing", "orthogonalEdgeStyle"
+		}
+		if conn.Page != wantPage || conn.EdgeStyle != wantStyle {
+			t.Errorf("connection %s->%s has page %q edge style %q, want %q %q",
+				conn.Source, conn.Target, conn.Page, conn.EdgeStyle, wantPage, wantStyle)
+		}
+	}
+}
+
+func TestParsePackageDefaultsDiagnostics(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "//diagram:defaults page=A,name=Shared,connectsTo=B\n" +
+			"package a\n\n" +
+			"//diagram:defaults page=B\n" +
+			"type T struct{}\n",
+		"b.go": "//diagram:defaults page=B\n" +
+			"package a\n\n" +
+			"//diagram:component\n" +
+			"type U struct{}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if u := diagram.GetComponentByName("U"); u == nil || u.Page != "A" {
+		t.Errorf("expected the first file's defaults to apply, got %+v", u)
+	}
+
+	want := []string{
+		`a.go:1:1: warning: ignoring "name": it cannot be a package default`,
+		`a.go:1:1: warning: ignoring "connectsTo": it cannot be a package default`,
+		`a.go:4:1: warning: //diagram:defaults must document the package clause`,
+		`b.go:1:1: warning: ignoring //diagram:defaults: package defaults are already set at `,
+	}
+	diags := p.Diagnostics()
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for i, w := range want {
+		if !strings.Contains(diags[i].String(), w) {
+			t.Errorf("diagnostic %d = %q, want %q", i, diags[i].String(), w)
+		}
+	}
+}
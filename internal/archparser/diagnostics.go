@@ -0,0 +1,123 @@
+package archparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+)
+
+// Severity classifies a diagnostic.
+type Severity string
+
+const (
+	// SeverityError marks an annotation or file that was rejected.
+	SeverityError Severity = "error"
+	// SeverityWarning marks an annotation that was accepted but looks suspicious.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a problem found while parsing, positioned in the source.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Raw      string   `json:"raw,omitempty"`
+}
+
+// String formats the diagnostic in compiler style: file:line:column: severity: message.
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", d.File, d.Line, d.Column)
+	}
+
+	s := fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+	if d.Raw != "" {
+		s += fmt.Sprintf(" (in %q)", d.Raw)
+	}
+	return s
+}
+
+// Diagnostics is an ordered list of diagnostics.
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any diagnostic has error severity.
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteText writes one compiler-style line per diagnostic.
+func (d Diagnostics) WriteText(w io.Writer) error {
+	for _, diag := range d {
+		if _, err := fmt.Fprintln(w, diag.String()); err != nil {
+			return fmt.Errorf("failed to write diagnostics: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the diagnostics as a JSON array for editor integrations.
+func (d Diagnostics) WriteJSON(w io.Writer) error {
+	if d == nil {
+		d = Diagnostics{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return nil
+}
+
+// Diagnostics returns the diagnostics collected by every parse run so far.
+func (p *Parser) Diagnostics() Diagnostics {
+	return p.diagnostics
+}
+
+func newDiagnostic(fset *token.FileSet, pos token.Pos, severity Severity, raw, format string, args ...any) Diagnostic {
+	position := fset.Position(pos)
+	return Diagnostic{
+		File:     position.Filename,
+		Line:     position.Line,
+		Column:   position.Column,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Raw:      raw,
+	}
+}
+
+// fileErrorDiagnostics converts a file-level parse error into diagnostics,
+// keeping the positions reported by go/parser when available.
+func fileErrorDiagnostics(path string, err error) Diagnostics {
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		diags := make(Diagnostics, 0, len(list))
+		for _, e := range list {
+			diags = append(diags, Diagnostic{
+				File:     e.Pos.Filename,
+				Line:     e.Pos.Line,
+				Column:   e.Pos.Column,
+				Severity: SeverityError,
+				Message:  e.Msg,
+			})
+		}
+		return diags
+	}
+
+	return Diagnostics{{
+		File:     path,
+		Severity: SeverityError,
+		Message:  err.Error(),
+	}}
+}
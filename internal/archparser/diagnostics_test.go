@@ -0,0 +1,122 @@
+package archparser_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseReportsRejectedAnnotations(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\n" +
+			"type A struct {\n" +
+			"\tField string `diagram:\"type=service\"`\n" +
+			"}\n\n" +
+			"type B struct {\n" +
+			"\tField string `diagram:\"type=widget,name=B,colour=red,oops\"`\n" +
+			"}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(diagram.Components))
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %d: %v", len(diags), diags)
+	}
+	if !diags.HasErrors() {
+		t.Error("expected an error diagnostic")
+	}
+
+	first := diags[0]
+	if first.File != filepath.Join(dir, "a.go") || first.Line != 4 || first.Column != 15 {
+		t.Errorf("unexpected position %s:%d:%d", first.File, first.Line, first.Column)
+	}
+	if first.Severity != archparser.SeverityError || first.Raw != "type=service" {
+		t.Errorf("unexpected diagnostic %+v", first)
+	}
+
+	var messages []string
+	for _, d := range diags[1:] {
+		if d.Severity != archparser.SeverityWarning || d.Line != 8 {
+			t.Errorf("expected warning on line 8, got %+v", d)
+		}
+		messages = append(messages, d.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, want := range []string{`"oops"`, `"colour"`, `"widget"`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected a warning mentioning %s, got:\n%s", want, joined)
+		}
+	}
+}
+
+func TestParseReportsFileErrors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"broken.go": "package broken\n\nfunc {\n",
+	})
+
+	p := archparser.New()
+	if _, err := p.Parse(dir); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) == 0 || !diags.HasErrors() {
+		t.Fatalf("expected file error diagnostics, got %v", diags)
+	}
+	if diags[0].Line != 3 {
+		t.Errorf("expected error on line 3, got %d", diags[0].Line)
+	}
+}
+
+func TestDiagnosticsOutput(t *testing.T) {
+	t.Parallel()
+	diags := archparser.Diagnostics{
+		{File: "a.go", Line: 3, Column: 7, Severity: archparser.SeverityError, Message: "annotation ignored: name is required", Raw: "type=service"},
+		{File: "b.go", Severity: archparser.SeverityWarning, Message: "odd"},
+	}
+
+	var text bytes.Buffer
+	if err := diags.WriteText(&text); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	want := "a.go:3:7: error: annotation ignored: name is required (in \"type=service\")\nb.go: warning: odd\n"
+	if text.String() != want {
+		t.Errorf("WriteText() = %q, want %q", text.String(), want)
+	}
+
+	var out bytes.Buffer
+	if err := diags.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded []archparser.Diagnostic
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Column != 7 || decoded[1].Severity != archparser.SeverityWarning {
+		t.Errorf("unexpected decoded diagnostics: %+v", decoded)
+	}
+
+	var empty bytes.Buffer
+	if err := archparser.Diagnostics(nil).WriteJSON(&empty); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Errorf("expected empty JSON array, got %q", empty.String())
+	}
+}
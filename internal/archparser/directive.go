@@ -0,0 +1,95 @@
+package archparser
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// directivePrefix starts every diagram comment directive, e.g.
+//
+//	//diagram:component type=service,name=Orders
+const directivePrefix = "//diagram:"
+
+// Directive kinds understood by the parser.
+const (
+	directiveComponent = "component"
+	directiveFlowchart = "flowchart"
+)
+
+// directive is a single //diagram:<kind> line found in a doc comment.
+type directive struct {
+	Kind string
+	Text string
+	Pos  token.Pos
+	// TextOffset is the offset of Text from Pos.
+	TextOffset int
+}
+
+// textPos returns the source position of an offset into the directive text.
+func (d directive) textPos(offset int) token.Pos {
+	return d.Pos + token.Pos(d.TextOffset+offset)
+}
+
+// parseDirectives returns the diagram directives contained in a comment group.
+// Like //go: directives, they must start at the beginning of the comment with
+// no space after the slashes.
+func parseDirectives(doc *ast.CommentGroup) []directive {
+	if doc == nil {
+		return nil
+	}
+
+	var directives []directive
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(c.Text, directivePrefix)
+		kind, text, _ := strings.Cut(rest, " ")
+		trimmed := strings.TrimLeft(text, " \t")
+		directives = append(directives, directive{
+			Kind:       strings.TrimSpace(kind),
+			Text:       strings.TrimSpace(trimmed),
+			Pos:        c.Pos(),
+			TextOffset: len(c.Text) - len(trimmed),
+		})
+	}
+	return directives
+}
+
+// docTarget pairs a doc comment with the name of the declaration it documents.
+// Type is set when the declaration is a single Go type, Func when it is a function.
+type docTarget struct {
+	Doc  *ast.CommentGroup
+	Name string
+	Type string
+	Func *ast.FuncDecl
+}
+
+// docTargetOf returns the doc comment of an AST node that may carry
+// directives: the package clause, type declarations and functions.
+func docTargetOf(n ast.Node) (docTarget, bool) {
+	switch node := n.(type) {
+	case *ast.File:
+		return docTarget{Doc: node.Doc, Name: node.Name.Name}, true
+	case *ast.GenDecl:
+		if node.Tok != token.TYPE {
+			return docTarget{}, false
+		}
+		// A grouped type declaration documents several types at once, so
+		// directives on it have no single name to fall back to.
+		name := ""
+		if len(node.Specs) == 1 {
+			if spec, ok := node.Specs[0].(*ast.TypeSpec); ok {
+				name = spec.Name.Name
+			}
+		}
+		return docTarget{Doc: node.Doc, Name: name, Type: name}, true
+	case *ast.TypeSpec:
+		return docTarget{Doc: node.Doc, Name: node.Name.Name, Type: node.Name.Name}, true
+	case *ast.FuncDecl:
+		return docTarget{Doc: node.Doc, Name: node.Name.Name, Func: node}, true
+	}
+	return docTarget{}, false
+}
@@ -0,0 +1,117 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+const directiveSource = `// Package orders handles orders.
+//
+//diagram:component type=gateway,name=OrdersAPI,connectsTo=Orders
+package orders
+
+// Orders processes orders.
+//
+//diagram:component type=service,name=Orders,connectsTo=OrderDB,swimlane=Core
+type Orders struct {
+	db *OrderDB
+}
+
+//diagram:component type=database
+type OrderDB struct{}
+
+type (
+	// Queue buffers events.
+	//diagram:component type=queue
+	Queue struct{}
+
+	// Unrelated has an ordinary comment.
+	Unrelated struct{}
+)
+
+// HandleRefund issues refunds.
+//
+//diagram:component type=service,name=Refunds,connectsTo=Queue
+func HandleRefund() {}
+
+// Notes is documented but not annotated.
+// diagram:component name=NotADirective
+type Notes struct{}
+
+//diagram:widget name=Odd
+type Odd struct{}
+`
+
+func TestParseFileDirectives(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"orders.go": directiveSource})
+
+	p := archparser.New()
+	diagram, err := p.ParseFile(filepath.Join(dir, "orders.go"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	want := []struct {
+		name     string
+		compType model.ComponentType
+	}{
+		{"OrdersAPI", model.ComponentTypeGateway},
+		{"Orders", model.ComponentTypeService},
+		{"OrderDB", model.ComponentTypeDatabase},
+		{"Queue", model.ComponentTypeQueue},
+		{"Refunds", model.ComponentTypeService},
+	}
+	if len(diagram.Components) != len(want) {
+		t.Fatalf("expected %d components, got %d: %+v", len(want), len(diagram.Components), diagram.Components)
+	}
+	for i, w := range want {
+		comp := diagram.Components[i]
+		if comp.Name != w.name || comp.Type != w.compType {
+			t.Errorf("component %d = (%s, %s), want (%s, %s)", i, comp.Name, comp.Type, w.name, w.compType)
+		}
+	}
+
+	if diagram.Components[1].Swimlane != "Core" {
+		t.Errorf("expected Orders in swimlane Core, got %q", diagram.Components[1].Swimlane)
+	}
+	if len(diagram.Connections) != 3 {
+		t.Errorf("expected 3 connections, got %d", len(diagram.Connections))
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Message != `unknown directive "//diagram:widget"` {
+		t.Errorf("expected one unknown directive warning, got %v", diags)
+	}
+}
+
+func TestParseFileDirectiveWithoutName(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"grouped.go": `package grouped
+
+//diagram:component type=service
+type (
+	A struct{}
+	B struct{}
+)
+`})
+
+	p := archparser.New()
+	diagram, err := p.ParseFile(filepath.Join(dir, "grouped.go"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	if len(diagram.Components) != 0 {
+		t.Errorf("expected no components, got %+v", diagram.Components)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Severity != archparser.SeverityError || diags[0].Line != 3 {
+		t.Errorf("expected a name error on line 3, got %v", diags)
+	}
+}
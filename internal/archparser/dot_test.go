@@ -0,0 +1,57 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseDOT(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component partial=true,connectsTo=Ledger\n" +
+			"type OrderService struct{}\n",
+		"docs/deps.dot": "digraph deps {\n" +
+			"  Ledger [shape=cylinder]\n" +
+			"  OrderService -> Ledger\n" +
+			"  Ledger -> Archive [label=\"backs up\"]\n" +
+			"}\n",
+	})
+
+	// Walking the tree does not read it.
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 {
+		t.Fatalf("components = %+v, want only OrderService", diagram.Components)
+	}
+
+	p = archparser.New()
+	diagram, err = p.Parse(dir+"/...", filepath.Join(dir, "docs", "deps.dot"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// Archive is only named by an edge and is a service without warnings.
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("diagnostics = %v, want none", diags)
+	}
+	if len(diagram.Components) != 3 {
+		t.Fatalf("components = %+v, want OrderService, Ledger and Archive", diagram.Components)
+	}
+	if ledger := diagram.GetComponentByName("Ledger"); ledger == nil || ledger.Type != "database" {
+		t.Errorf("Ledger = %+v, want a database", ledger)
+	}
+	if archive := diagram.GetComponentByName("Archive"); archive == nil || archive.Partial || archive.Type != "service" {
+		t.Errorf("Archive = %+v, want a service", archive)
+	}
+	// The annotation and the edge both declare OrderService -> Ledger; the
+	// partial annotation merges with the node of the edge.
+	if len(diagram.Connections) != 2 || diagram.Connections[1].Label != "backs up" {
+		t.Errorf("connections = %+v, want OrderService -> Ledger and Ledger -> Archive labelled backs up", diagram.Connections)
+	}
+}
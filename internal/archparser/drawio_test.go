@@ -0,0 +1,50 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseDrawIOFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component connectsTo=Ledger\n" +
+			"type OrderService struct{}\n",
+		"docs/legacy.drawio": `<mxfile><diagram name="Architecture Diagram"><mxGraphModel><root>
+<mxCell id="0" /><mxCell id="1" parent="0" />
+<mxCell id="a" value="Ledger" style="shape=cylinder3;" vertex="1" parent="1"><mxGeometry x="400" y="80" width="60" height="80" as="geometry" /></mxCell>
+<mxCell id="b" value="Mainframe" style="shape=document;" vertex="1" parent="1"><mxGeometry x="400" y="300" width="120" height="60" as="geometry" /></mxCell>
+<mxCell id="c" value="batch" edge="1" parent="1" source="a" target="b"><mxGeometry relative="1" as="geometry" /></mxCell>
+</root></mxGraphModel></diagram></mxfile>`,
+	})
+
+	// Walking the tree does not read .drawio files.
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 {
+		t.Fatalf("components = %+v, want only OrderService", diagram.Components)
+	}
+
+	p = archparser.New()
+	diagram, err = p.Parse(dir+"/...", filepath.Join(dir, "docs", "legacy.drawio"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+	ledger := diagram.GetComponentByName("Ledger")
+	if ledger == nil || ledger.Type != "database" || ledger.X != 400 || ledger.Y != 80 {
+		t.Fatalf("Ledger = %+v, want the drawn database at 400,80", ledger)
+	}
+	if len(diagram.Connections) != 2 {
+		t.Errorf("connections = %+v, want the annotated and the drawn one", diagram.Connections)
+	}
+}
@@ -0,0 +1,350 @@
+package archparser
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+const (
+	// maxFlowLabel is the longest node label, in runes, before it is shortened.
+	maxFlowLabel = 60
+	// backEdgeStyle routes loop back-edges around the loop body.
+	backEdgeStyle = "orthogonalEdgeStyle"
+)
+
+// flowExit is a dangling edge leaving a node, waiting for the next node.
+type flowExit struct {
+	From  string
+	Label string
+}
+
+// flowTarget is an enclosing loop, switch or select that break and continue refer to.
+type flowTarget struct {
+	Label  string
+	Head   string
+	IsLoop bool
+	Breaks []flowExit
+}
+
+// flowchartBuilder turns a function body into flowchart nodes and edges.
+type flowchartBuilder struct {
+	fset    *token.FileSet
+	prefix  string
+	page    model.Page
+	count   int
+	targets []*flowTarget
+	label   string
+}
+
+// buildFlowchart walks the body of fn and returns its flowchart as a page.
+// Decisions become rhombus nodes, returns become terminal ellipses, calls
+// become process boxes, and loops get back-edges to their decision node.
+// id prefixes the node names and is the page name unless ann sets one.
+func buildFlowchart(fset *token.FileSet, fn *ast.FuncDecl, ann *Annotation, id string) model.Page {
+	pageName := ann.Page
+	if pageName == "" {
+		pageName = id
+	}
+
+	b := &flowchartBuilder{
+		fset:   fset,
+		prefix: id,
+		page:   model.Page{Name: pageName},
+	}
+
+	start := b.node(model.ComponentTypeTerminal, "start "+ann.Name)
+	exits := b.block(fn.Body.List, []flowExit{{From: start}})
+	if len(exits) > 0 {
+		end := b.node(model.ComponentTypeTerminal, "end")
+		b.connect(exits, end, false)
+	}
+
+	return b.page
+}
+
+// funcID names a function by its package and, for a method, its receiver
+// type, as in orders.(*Server).Handle, so that handlers sharing a name in
+// different packages or on different types stay apart.
+func funcID(pkg string, fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return pkg + "." + fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	star, pointer := recv.(*ast.StarExpr)
+	if pointer {
+		recv = star.X
+	}
+	switch generic := recv.(type) {
+	case *ast.IndexExpr:
+		recv = generic.X
+	case *ast.IndexListExpr:
+		recv = generic.X
+	}
+	name := ""
+	if ident, ok := recv.(*ast.Ident); ok {
+		name = ident.Name
+	}
+	if pointer {
+		name = "(*" + name + ")"
+	}
+	return pkg + "." + name + "." + fn.Name.Name
+}
+
+func (b *flowchartBuilder) node(compType model.ComponentType, label string) string {
+	b.count++
+	name := fmt.Sprintf("%s/%d", b.prefix, b.count)
+	b.page.Components = append(b.page.Components, model.Component{
+		Type:  compType,
+		Name:  name,
+		Label: label,
+		Page:  b.page.Name,
+	})
+	return name
+}
+
+// connect links every dangling exit to a node. Back-edges to a loop head are
+// routed orthogonally so they stay readable.
+func (b *flowchartBuilder) connect(exits []flowExit, to string, back bool) {
+	for _, exit := range exits {
+		conn := model.Connection{
+			Source:    exit.From,
+			Target:    to,
+			Direction: model.ConnectionDirectionUnidirectional,
+			Label:     exit.Label,
+			Page:      b.page.Name,
+		}
+		if back {
+			conn.EdgeStyle = backEdgeStyle
+		}
+		b.page.Connections = append(b.page.Connections, conn)
+	}
+}
+
+func (b *flowchartBuilder) block(stmts []ast.Stmt, exits []flowExit) []flowExit {
+	for _, stmt := range stmts {
+		if len(exits) == 0 {
+			// The rest of the block is unreachable.
+			break
+		}
+		exits = b.stmt(stmt, exits)
+	}
+	return exits
+}
+
+func (b *flowchartBuilder) stmt(stmt ast.Stmt, exits []flowExit) []flowExit {
+	label := b.label
+	b.label = ""
+
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		return b.block(s.List, exits)
+	case *ast.LabeledStmt:
+		b.label = s.Label.Name
+		return b.stmt(s.Stmt, exits)
+	case *ast.ReturnStmt:
+		end := b.node(model.ComponentTypeTerminal, b.source(s))
+		b.connect(exits, end, false)
+		return nil
+	case *ast.IfStmt:
+		return b.ifStmt(s, exits)
+	case *ast.SwitchStmt:
+		exits = b.simple(s.Init, exits)
+		text := "switch"
+		if s.Tag != nil {
+			text += " " + b.source(s.Tag)
+		}
+		return b.cases(label, text, s.Body, exits)
+	case *ast.TypeSwitchStmt:
+		exits = b.simple(s.Init, exits)
+		return b.cases(label, "switch "+b.source(s.Assign), s.Body, exits)
+	case *ast.SelectStmt:
+		return b.cases(label, "select", s.Body, exits)
+	case *ast.ForStmt:
+		exits = b.simple(s.Init, exits)
+		text := "for"
+		if s.Cond != nil {
+			text += " " + b.source(s.Cond)
+		}
+		return b.loop(label, text, s.Cond != nil, s.Post, s.Body, exits)
+	case *ast.RangeStmt:
+		text := "range " + b.source(s.X)
+		if s.Key != nil {
+			text = "for " + b.source(s.Key)
+			if s.Value != nil {
+				text += ", " + b.source(s.Value)
+			}
+			text += " := range " + b.source(s.X)
+		}
+		return b.loop(label, text, true, nil, s.Body, exits)
+	case *ast.BranchStmt:
+		return b.branch(s, exits)
+	default:
+		return b.simple(stmt, exits)
+	}
+}
+
+// simple adds a process box for a statement that calls a function.
+// Statements without calls do not change control flow and are left out.
+func (b *flowchartBuilder) simple(stmt ast.Stmt, exits []flowExit) []flowExit {
+	if stmt == nil || !containsCall(stmt) {
+		return exits
+	}
+
+	process := b.node(model.ComponentTypeProcess, b.source(stmt))
+	b.connect(exits, process, false)
+	return []flowExit{{From: process}}
+}
+
+func (b *flowchartBuilder) ifStmt(s *ast.IfStmt, exits []flowExit) []flowExit {
+	exits = b.simple(s.Init, exits)
+
+	decision := b.node(model.ComponentTypeDecision, b.source(s.Cond)+"?")
+	b.connect(exits, decision, false)
+
+	result := b.block(s.Body.List, []flowExit{{From: decision, Label: "yes"}})
+	no := []flowExit{{From: decision, Label: "no"}}
+	if s.Else != nil {
+		no = b.stmt(s.Else, no)
+	}
+	return append(result, no...)
+}
+
+func (b *flowchartBuilder) cases(label, text string, body *ast.BlockStmt, exits []flowExit) []flowExit {
+	decision := b.node(model.ComponentTypeDecision, text)
+	b.connect(exits, decision, false)
+
+	target := &flowTarget{Label: label, Head: decision}
+	b.targets = append(b.targets, target)
+
+	var result []flowExit
+	hasDefault := false
+	for _, clause := range body.List {
+		var caseLabel string
+		var stmts []ast.Stmt
+		switch c := clause.(type) {
+		case *ast.CaseClause:
+			caseLabel = b.exprList(c.List)
+			stmts = c.Body
+		case *ast.CommClause:
+			if c.Comm != nil {
+				caseLabel = b.source(c.Comm)
+			}
+			stmts = c.Body
+		}
+		if caseLabel == "" {
+			caseLabel = "default"
+			hasDefault = true
+		}
+		result = append(result, b.block(stmts, []flowExit{{From: decision, Label: caseLabel}})...)
+	}
+	if !hasDefault {
+		result = append(result, flowExit{From: decision, Label: "default"})
+	}
+
+	b.targets = b.targets[:len(b.targets)-1]
+	return append(result, target.Breaks...)
+}
+
+func (b *flowchartBuilder) loop(label, text string, hasCond bool, post ast.Stmt, body *ast.BlockStmt, exits []flowExit) []flowExit {
+	head := b.node(model.ComponentTypeDecision, text)
+	b.connect(exits, head, false)
+
+	target := &flowTarget{Label: label, Head: head, IsLoop: true}
+	b.targets = append(b.targets, target)
+
+	bodyExits := b.block(body.List, []flowExit{{From: head, Label: "loop"}})
+	bodyExits = b.simple(post, bodyExits)
+	b.connect(bodyExits, head, true)
+
+	b.targets = b.targets[:len(b.targets)-1]
+
+	var result []flowExit
+	if hasCond {
+		result = append(result, flowExit{From: head, Label: "done"})
+	}
+	return append(result, target.Breaks...)
+}
+
+func (b *flowchartBuilder) branch(s *ast.BranchStmt, exits []flowExit) []flowExit {
+	switch s.Tok {
+	case token.BREAK, token.CONTINUE:
+		target := b.findTarget(s)
+		if target == nil {
+			return exits
+		}
+		if s.Tok == token.BREAK {
+			target.Breaks = append(target.Breaks, exits...)
+		} else {
+			b.connect(exits, target.Head, true)
+		}
+		return nil
+	case token.GOTO:
+		jump := b.node(model.ComponentTypeTerminal, b.source(s))
+		b.connect(exits, jump, false)
+		return nil
+	}
+	// fallthrough continues into the next case, which the case clauses
+	// already model as a separate branch of the decision.
+	return exits
+}
+
+// findTarget returns the statement a break or continue refers to: the
+// labelled one, or the innermost loop (continue) or loop/switch/select (break).
+func (b *flowchartBuilder) findTarget(s *ast.BranchStmt) *flowTarget {
+	for i := len(b.targets) - 1; i >= 0; i-- {
+		target := b.targets[i]
+		if s.Label != nil {
+			if target.Label == s.Label.Name {
+				return target
+			}
+			continue
+		}
+		if s.Tok == token.BREAK || target.IsLoop {
+			return target
+		}
+	}
+	return nil
+}
+
+func (b *flowchartBuilder) exprList(exprs []ast.Expr) string {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		parts = append(parts, b.source(expr))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "case " + strings.Join(parts, ", ")
+}
+
+// source prints a node as Go source on a single, shortened line.
+func (b *flowchartBuilder) source(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, b.fset, node); err != nil {
+		return fmt.Sprintf("%T", node)
+	}
+
+	text := strings.Join(strings.Fields(buf.String()), " ")
+	if runes := []rune(text); len(runes) > maxFlowLabel {
+		text = string(runes[:maxFlowLabel-1]) + "…"
+	}
+	return text
+}
+
+func containsCall(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
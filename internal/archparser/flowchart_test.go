@@ -0,0 +1,234 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/validator"
+)
+
+const flowchartSource = `package handlers
+
+// HandleOrder processes an order.
+//
+//diagram:flowchart page=Orders
+func HandleOrder(req Request) error {
+	if err := validate(req); err != nil {
+		return err
+	}
+
+	for _, item := range req.Items {
+		if item.Skip {
+			continue
+		}
+		reserve(item)
+	}
+
+	switch req.Kind {
+	case "express":
+		ship(req, true)
+	case "standard", "economy":
+		ship(req, false)
+	}
+
+	notify(req)
+	return nil
+}
+
+//diagram:flowchart name=Poll
+func poll(ch chan int) {
+	for {
+		select {
+		case v := <-ch:
+			if v < 0 {
+				return
+			}
+		default:
+			break
+		}
+		wait()
+	}
+}
+
+//diagram:flowchart
+type NotAFunc struct{}
+
+func validate(Request) error { return nil }
+`
+
+type flowEdge struct {
+	source, target, label string
+}
+
+func flowchartByPage(t *testing.T) (*model.Diagram, *archparser.Parser) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"handlers.go": flowchartSource})
+
+	p := archparser.New()
+	diagram, err := p.ParseFlowcharts(filepath.Join(dir, "handlers.go"))
+	if err != nil {
+		t.Fatalf("ParseFlowcharts failed: %v", err)
+	}
+	return diagram, p
+}
+
+func pageLabels(page model.Page) (map[string]model.Component, map[flowEdge]int) {
+	nodes := make(map[string]model.Component)
+	byName := make(map[string]string)
+	for _, comp := range page.Components {
+		nodes[comp.Label] = comp
+		byName[comp.Name] = comp.Label
+	}
+	edges := make(map[flowEdge]int)
+	for _, conn := range page.Connections {
+		edges[flowEdge{byName[conn.Source], byName[conn.Target], conn.Label}]++
+	}
+	return nodes, edges
+}
+
+func TestParseFlowcharts(t *testing.T) {
+	t.Parallel()
+	diagram, p := flowchartByPage(t)
+
+	if diagram.Type != model.DiagramTypeFlowchart {
+		t.Errorf("Type = %q, want flowchart", diagram.Type)
+	}
+	if len(diagram.Pages) != 2 || diagram.Pages[0].Name != "Orders" || diagram.Pages[1].Name != "Poll" {
+		t.Fatalf("expected pages Orders and Poll, got %+v", diagram.Pages)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Line != 44 {
+		t.Errorf("expected one warning for the directive on a type, got %v", diags)
+	}
+
+	names := make(map[string]bool)
+	for _, comp := range diagram.Components {
+		if names[comp.Name] {
+			t.Errorf("duplicate component name %s", comp.Name)
+		}
+		names[comp.Name] = true
+	}
+}
+
+func TestParseFlowchartsOrderHandler(t *testing.T) {
+	t.Parallel()
+	diagram, _ := flowchartByPage(t)
+	nodes, edges := pageLabels(diagram.Pages[0])
+
+	wantTypes := map[string]model.ComponentType{
+		"start HandleOrder":              model.ComponentTypeTerminal,
+		"err := validate(req)":           model.ComponentTypeProcess,
+		"err != nil?":                    model.ComponentTypeDecision,
+		"return err":                     model.ComponentTypeTerminal,
+		"for _, item := range req.Items": model.ComponentTypeDecision,
+		"item.Skip?":                     model.ComponentTypeDecision,
+		"reserve(item)":                  model.ComponentTypeProcess,
+		"switch req.Kind":                model.ComponentTypeDecision,
+		"ship(req, true)":                model.ComponentTypeProcess,
+		"ship(req, false)":               model.ComponentTypeProcess,
+		"notify(req)":                    model.ComponentTypeProcess,
+		"return nil":                     model.ComponentTypeTerminal,
+	}
+	if len(nodes) != len(wantTypes) {
+		t.Errorf("expected %d nodes, got %d: %v", len(wantTypes), len(nodes), nodes)
+	}
+	for label, want := range wantTypes {
+		if got, ok := nodes[label]; !ok || got.Type != want {
+			t.Errorf("node %q = %+v, want type %s", label, got, want)
+		}
+	}
+
+	wantEdges := []flowEdge{
+		{"start HandleOrder", "err := validate(req)", ""},
+		{"err := validate(req)", "err != nil?", ""},
+		{"err != nil?", "return err", "yes"},
+		{"err != nil?", "for _, item := range req.Items", "no"},
+		{"for _, item := range req.Items", "item.Skip?", "loop"},
+		{"item.Skip?", "for _, item := range req.Items", "yes"},
+		{"item.Skip?", "reserve(item)", "no"},
+		{"reserve(item)", "for _, item := range req.Items", ""},
+		{"for _, item := range req.Items", "switch req.Kind", "done"},
+		{"switch req.Kind", "ship(req, true)", `case "express"`},
+		{"switch req.Kind", "ship(req, false)", `case "standard", "economy"`},
+		{"switch req.Kind", "notify(req)", "default"},
+		{"ship(req, true)", "notify(req)", ""},
+		{"ship(req, false)", "notify(req)", ""},
+		{"notify(req)", "return nil", ""},
+	}
+	for _, w := range wantEdges {
+		if edges[w] != 1 {
+			t.Errorf("expected edge %q -> %q [%s], got %d", w.source, w.target, w.label, edges[w])
+		}
+	}
+	if len(diagram.Pages[0].Connections) != len(wantEdges) {
+		t.Errorf("expected %d edges, got %d", len(wantEdges), len(diagram.Pages[0].Connections))
+	}
+}
+
+func TestParseFlowchartsInfiniteLoop(t *testing.T) {
+	t.Parallel()
+	diagram, _ := flowchartByPage(t)
+	nodes, edges := pageLabels(diagram.Pages[1])
+
+	if _, ok := nodes["end"]; ok {
+		t.Error("infinite loop without break out of it should not reach the end node")
+	}
+
+	wantEdges := []flowEdge{
+		{"start Poll", "for", ""},
+		{"for", "select", "loop"},
+		{"select", "v < 0?", "v := <-ch"},
+		{"v < 0?", "return", "yes"},
+		{"v < 0?", "wait()", "no"},
+		{"select", "wait()", "default"},
+		{"wait()", "for", ""},
+	}
+	for _, w := range wantEdges {
+		if edges[w] != 1 {
+			t.Errorf("expected edge %q -> %q [%s], got %d", w.source, w.target, w.label, edges[w])
+		}
+	}
+}
+
+func TestParseFlowchartsSameNamedFunctions(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, map[string]string{
+		"orders/serve.go": "package orders\n\n" +
+			"type A struct{}\n\ntype B[T any] struct{}\n\n" +
+			"//diagram:flowchart\n" +
+			"func (A) Serve() { log() }\n\n" +
+			"//diagram:flowchart\n" +
+			"func (b *B[T]) Serve() { log() }\n\n" +
+			"//diagram:flowchart\n" +
+			"func Handle() { log() }\n",
+		"users/handle.go": "package users\n\n" +
+			"//diagram:flowchart\n" +
+			"func Handle() { log() }\n",
+	})
+
+	diagram, err := archparser.New().ParseFlowcharts(root + "/...")
+	if err != nil {
+		t.Fatalf("ParseFlowcharts failed: %v", err)
+	}
+	if err := validator.ValidateDiagram(diagram); err != nil {
+		t.Fatalf("ValidateDiagram failed: %v", err)
+	}
+
+	var pages []string
+	for _, page := range diagram.Pages {
+		pages = append(pages, page.Name)
+	}
+	want := []string{"orders.A.Serve", "orders.(*B).Serve", "orders.Handle", "users.Handle"}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+	// Labels keep the bare function name.
+	if nodes, _ := pageLabels(diagram.Pages[1]); nodes["start Serve"].Name != "orders.(*B).Serve/1" {
+		t.Errorf("start node = %+v, want orders.(*B).Serve/1", nodes["start Serve"])
+	}
+}
@@ -0,0 +1,200 @@
+package archparser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GoModule describes a module found through its go.mod file.
+type GoModule struct {
+	// Path is the module path declared by the module directive.
+	Path string
+	// Dir is the directory containing go.mod.
+	Dir string
+	// Requires lists the module paths of the require directives.
+	Requires []string
+}
+
+// FindGoModule locates the go.mod governing dir by walking up the directory tree.
+func FindGoModule(dir string) (*GoModule, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	for {
+		goMod := filepath.Join(abs, "go.mod")
+		if _, err := os.Stat(goMod); err == nil {
+			return ReadGoModule(goMod)
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return nil, fmt.Errorf("no go.mod found for %s", dir)
+		}
+		abs = parent
+	}
+}
+
+// ReadGoModule reads the module path and requirements from a go.mod file.
+func ReadGoModule(goModPath string) (*GoModule, error) {
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goModPath, err)
+	}
+
+	mod := &GoModule{Dir: filepath.Dir(goModPath)}
+	inRequire := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inRequire {
+			if fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+			mod.Requires = append(mod.Requires, unquoteModPath(fields[0]))
+			continue
+		}
+
+		switch fields[0] {
+		case "module":
+			if len(fields) > 1 {
+				mod.Path = unquoteModPath(fields[1])
+			}
+		case "require":
+			if len(fields) > 1 && fields[1] == "(" {
+				inRequire = true
+			} else if len(fields) > 1 {
+				mod.Requires = append(mod.Requires, unquoteModPath(fields[1]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goModPath, err)
+	}
+
+	if mod.Path == "" {
+		return nil, fmt.Errorf("%s has no module directive", goModPath)
+	}
+	return mod, nil
+}
+
+// GoWorkspace describes a go.work file and the modules it uses.
+type GoWorkspace struct {
+	// Dir is the directory containing go.work.
+	Dir string
+	// Modules lists the modules of the use directives, in file order.
+	Modules []*GoModule
+}
+
+// ReadGoWorkspace reads the use directives of a go.work file and the go.mod
+// of every module they name.
+func ReadGoWorkspace(goWorkPath string) (*GoWorkspace, error) {
+	data, err := os.ReadFile(goWorkPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goWorkPath, err)
+	}
+
+	work := &GoWorkspace{Dir: filepath.Dir(goWorkPath)}
+	var uses []string
+	inUse := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inUse {
+			if fields[0] == ")" {
+				inUse = false
+				continue
+			}
+			uses = append(uses, unquoteModPath(fields[0]))
+			continue
+		}
+
+		if fields[0] == "use" && len(fields) > 1 {
+			if fields[1] == "(" {
+				inUse = true
+			} else {
+				uses = append(uses, unquoteModPath(fields[1]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", goWorkPath, err)
+	}
+
+	for _, use := range uses {
+		dir := filepath.FromSlash(use)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(work.Dir, dir)
+		}
+		mod, err := ReadGoModule(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: use %s: %w", goWorkPath, use, err)
+		}
+		work.Modules = append(work.Modules, mod)
+	}
+	return work, nil
+}
+
+func unquoteModPath(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+// ImportPath returns the import path of the package in dir, which must be inside the module.
+func (m *GoModule) ImportPath(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.Dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	if rel == "." {
+		return m.Path, true
+	}
+	return m.Path + "/" + filepath.ToSlash(rel), true
+}
+
+// Contains reports whether an import path belongs to the module.
+func (m *GoModule) Contains(importPath string) bool {
+	return importPath == m.Path || strings.HasPrefix(importPath, m.Path+"/")
+}
+
+// RequiredModule returns the required module providing an import path, if any.
+func (m *GoModule) RequiredModule(importPath string) (string, bool) {
+	best := ""
+	for _, req := range m.Requires {
+		if (importPath == req || strings.HasPrefix(importPath, req+"/")) && len(req) > len(best) {
+			best = req
+		}
+	}
+	return best, best != ""
+}
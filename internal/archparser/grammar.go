@@ -0,0 +1,469 @@
+package archparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GrammarVersion is the version of the annotation grammar implemented by
+// this package. It changes whenever annotations may parse differently.
+const GrammarVersion = 2
+
+// Grammar selects the syntax used to read annotations.
+type Grammar string
+
+const (
+	// GrammarAuto reads annotations the way the legacy grammar does, unless
+	// they use quotes or escapes that grammar 2 reads into different keys,
+	// such as a quoted comma. It is the zero value's behavior.
+	GrammarAuto Grammar = "auto"
+	// GrammarLegacy reads annotations the way releases before grammar 2
+	// did, splitting on commas without quoting.
+	GrammarLegacy Grammar = "1"
+	// GrammarV2 reads annotations with grammar 2 only and reports every
+	// syntax error.
+	GrammarV2 Grammar = "2"
+)
+
+// grammarKey selects the grammar of a single annotation when it is the first
+// key, e.g. grammar=2,name=Payments,description='Stripe payments, EU region'.
+const grammarKey = "grammar"
+
+// SyntaxError is an annotation that does not follow the grammar.
+// Offset is the byte offset of the problem in the annotation text.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at column %d: %s", e.Offset+1, e.Msg)
+}
+
+// annotationPair is one key, with an optional value, of an annotation.
+// A value is a ;-separated list of items; scalar keys use its Text.
+type annotationPair struct {
+	Key      string
+	HasValue bool
+	Text     string
+	Items    []annotationItem
+	Offset   int
+}
+
+// annotationItem is one list entry, optionally followed by [key=value,...]
+// attributes as in connectsTo=OrderDB[label=SQL].
+type annotationItem struct {
+	Value  string
+	Attrs  []annotationPair
+	Offset int
+	// Unclosed marks legacy attributes missing their closing bracket.
+	Unclosed bool
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenText
+	tokenString
+	tokenEquals
+	tokenComma
+	tokenSemicolon
+	tokenOpen
+	tokenClose
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokenEOF:
+		return "end of annotation"
+	case tokenText:
+		return "text"
+	case tokenString:
+		return "quoted string"
+	case tokenEquals:
+		return `"="`
+	case tokenComma:
+		return `","`
+	case tokenSemicolon:
+		return `";"`
+	case tokenOpen:
+		return `"["`
+	default:
+		return `"]"`
+	}
+}
+
+type annotationToken struct {
+	Kind   tokenKind
+	Text   string
+	Offset int
+	// End is the offset just past the token, to spot text glued to a string.
+	End int
+}
+
+// lexAnnotation splits an annotation into tokens. Quotes only start a string
+// at the beginning of a token, so apostrophes inside bare text are literal.
+// Both quoted strings and bare text accept backslash escapes.
+func lexAnnotation(text string) ([]annotationToken, error) {
+	var tokens []annotationToken
+	i := 0
+	for {
+		for i < len(text) && isAnnotationSpace(text[i]) {
+			i++
+		}
+		if i >= len(text) {
+			return append(tokens, annotationToken{Kind: tokenEOF, Offset: i, End: i}), nil
+		}
+
+		start := i
+		if kind, ok := punctuation(text[i]); ok {
+			tokens = append(tokens, annotationToken{Kind: kind, Text: text[i : i+1], Offset: i, End: i + 1})
+			i++
+			continue
+		}
+
+		var tok annotationToken
+		var err error
+		if text[i] == '"' || text[i] == '\'' {
+			tok, i, err = lexString(text, i)
+		} else {
+			tok, i, err = lexText(text, i)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tok.Offset = start
+		tokens = append(tokens, tok)
+	}
+}
+
+func lexString(text string, i int) (annotationToken, int, error) {
+	quote := text[i]
+	start := i
+	var sb strings.Builder
+	for i++; i < len(text); i++ {
+		switch c := text[i]; c {
+		case quote:
+			return annotationToken{Kind: tokenString, Text: sb.String(), End: i + 1}, i + 1, nil
+		case '\\':
+			r, err := unescape(text, i)
+			if err != nil {
+				return annotationToken{}, i, err
+			}
+			sb.WriteByte(r)
+			i++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return annotationToken{}, i, &SyntaxError{Offset: start, Msg: "unterminated quoted string"}
+}
+
+func lexText(text string, i int) (annotationToken, int, error) {
+	var sb strings.Builder
+	// trimmed is the length of sb without trailing unescaped spaces.
+	trimmed := 0
+	end := i
+	for ; i < len(text); i++ {
+		c := text[i]
+		if _, ok := punctuation(c); ok {
+			break
+		}
+		if c == '\\' {
+			r, err := unescape(text, i)
+			if err != nil {
+				return annotationToken{}, i, err
+			}
+			sb.WriteByte(r)
+			i++
+			trimmed, end = sb.Len(), i+1
+			continue
+		}
+		sb.WriteByte(c)
+		if !isAnnotationSpace(c) {
+			trimmed, end = sb.Len(), i+1
+		}
+	}
+	return annotationToken{Kind: tokenText, Text: sb.String()[:trimmed], End: end}, i, nil
+}
+
+// unescape decodes the escape sequence starting with the backslash at i.
+func unescape(text string, i int) (byte, error) {
+	if i+1 >= len(text) {
+		return 0, &SyntaxError{Offset: i, Msg: "unfinished escape sequence"}
+	}
+	switch c := text[i+1]; c {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case '\\', '"', '\'', ',', ';', '=', '[', ']', ' ':
+		return c, nil
+	default:
+		return 0, &SyntaxError{Offset: i, Msg: fmt.Sprintf("unknown escape sequence \\%c", c)}
+	}
+}
+
+func punctuation(c byte) (tokenKind, bool) {
+	switch c {
+	case '=':
+		return tokenEquals, true
+	case ',':
+		return tokenComma, true
+	case ';':
+		return tokenSemicolon, true
+	case '[':
+		return tokenOpen, true
+	case ']':
+		return tokenClose, true
+	}
+	return 0, false
+}
+
+func isAnnotationSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// annotationParser reads grammar 2:
+//
+//	annotation = [ pair ] { "," [ pair ] }
+//	pair       = key [ "=" value ]
+//	value      = item { ";" item }
+//	item       = [ text | string ] [ "[" [ pair ] { "," [ pair ] } "]" ]
+type annotationParser struct {
+	tokens []annotationToken
+	pos    int
+}
+
+// parseGrammarV2 parses an annotation written in grammar 2.
+func parseGrammarV2(text string) ([]annotationPair, error) {
+	tokens, err := lexAnnotation(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &annotationParser{tokens: tokens}
+	pairs, err := p.pairs(tokenEOF)
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+func (p *annotationParser) peek() annotationToken {
+	return p.tokens[p.pos]
+}
+
+func (p *annotationParser) next() annotationToken {
+	tok := p.tokens[p.pos]
+	if tok.Kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// pairs reads comma-separated pairs up to, but not including, the end token.
+// Empty pairs, such as a trailing comma, are allowed.
+func (p *annotationParser) pairs(end tokenKind) ([]annotationPair, error) {
+	var pairs []annotationPair
+	for {
+		switch tok := p.peek(); tok.Kind {
+		case end:
+			return pairs, nil
+		case tokenComma:
+			p.next()
+			continue
+		case tokenText:
+			pair, err := p.pair()
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, pair)
+		default:
+			return nil, &SyntaxError{Offset: tok.Offset, Msg: fmt.Sprintf("expected key, found %s", tok.Kind)}
+		}
+
+		if tok := p.peek(); tok.Kind != tokenComma && tok.Kind != end {
+			return nil, &SyntaxError{Offset: tok.Offset, Msg: fmt.Sprintf("expected %s or %s, found %s", tokenComma, end, tok.Kind)}
+		}
+	}
+}
+
+func (p *annotationParser) pair() (annotationPair, error) {
+	key := p.next()
+	if !isAnnotationKey(key.Text) {
+		return annotationPair{}, &SyntaxError{Offset: key.Offset, Msg: fmt.Sprintf("invalid key %q", key.Text)}
+	}
+
+	pair := annotationPair{Key: key.Text, Offset: key.Offset}
+	if p.peek().Kind != tokenEquals {
+		return pair, nil
+	}
+	p.next()
+	pair.HasValue = true
+
+	var texts []string
+	for {
+		item, err := p.item()
+		if err != nil {
+			return annotationPair{}, err
+		}
+		pair.Items = append(pair.Items, item)
+		texts = append(texts, item.Value)
+
+		if p.peek().Kind != tokenSemicolon {
+			break
+		}
+		p.next()
+	}
+	pair.Text = strings.Join(texts, ";")
+	return pair, nil
+}
+
+func (p *annotationParser) item() (annotationItem, error) {
+	item := annotationItem{Offset: p.peek().Offset}
+
+	if tok := p.peek(); tok.Kind == tokenText || tok.Kind == tokenString {
+		p.next()
+		item.Value = tok.Text
+		if after := p.peek(); tok.Kind == tokenString && after.Kind == tokenText && after.Offset == tok.End {
+			return annotationItem{}, &SyntaxError{Offset: after.Offset, Msg: "unexpected text after quoted string"}
+		}
+	}
+
+	if p.peek().Kind != tokenOpen {
+		return item, nil
+	}
+	open := p.next()
+	attrs, err := p.pairs(tokenClose)
+	if err != nil {
+		if tok := p.peek(); tok.Kind == tokenEOF {
+			return annotationItem{}, &SyntaxError{Offset: open.Offset, Msg: "unclosed ["}
+		}
+		return annotationItem{}, err
+	}
+	p.next()
+	item.Attrs = attrs
+	return item, nil
+}
+
+func isAnnotationKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-' || c == '.' ||
+			i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGrammarLegacy reads an annotation the way the first grammar did:
+// pairs are split on commas, semicolons stay inside values and quotes are
+// only trimmed from the ends of a value.
+func parseGrammarLegacy(text string) []annotationPair {
+	var pairs []annotationPair
+	for _, part := range splitKeyValuePairs(text) {
+		key, value, hasValue := strings.Cut(part, "=")
+		pair := annotationPair{
+			Key:      strings.TrimSpace(key),
+			HasValue: hasValue,
+			Text:     strings.Trim(strings.TrimSpace(value), `"`),
+		}
+		if pair.Key == "connectsTo" {
+			pair.Items = legacyItems(pair.Text)
+		} else {
+			pair.Items = []annotationItem{{Value: pair.Text}}
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// legacyItems splits a connectsTo value into targets and their bracketed attributes.
+func legacyItems(value string) []annotationItem {
+	var items []annotationItem
+	for _, entry := range splitOutsideBrackets(value, ';') {
+		name, rest, hasAttrs := strings.Cut(strings.TrimSpace(entry), "[")
+		item := annotationItem{Value: strings.TrimSpace(name)}
+		if hasAttrs {
+			body, closed := strings.CutSuffix(strings.TrimSpace(rest), "]")
+			item.Unclosed = !closed
+			for _, attr := range strings.Split(body, ",") {
+				key, value, hasValue := strings.Cut(strings.TrimSpace(attr), "=")
+				if key = strings.TrimSpace(key); key == "" {
+					continue
+				}
+				item.Attrs = append(item.Attrs, annotationPair{
+					Key:      key,
+					HasValue: hasValue,
+					Text:     strings.Trim(strings.TrimSpace(value), `"`),
+				})
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// selectGrammar returns the grammar to read text with: the one named by a
+// leading grammar= key, or else mode. Auto resolves to legacy for text
+// without quotes or backslashes, which both grammars read alike, and stays
+// auto for the rest.
+func selectGrammar(text string, mode Grammar) (Grammar, error) {
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(text), grammarKey+"="); ok {
+		version, _, _ := strings.Cut(rest, ",")
+		switch g := Grammar(strings.TrimSpace(version)); g {
+		case GrammarLegacy, GrammarV2:
+			return g, nil
+		default:
+			return "", &SyntaxError{Offset: strings.Index(text, rest), Msg: fmt.Sprintf("unknown grammar %q (supported: 1, 2)", g)}
+		}
+	}
+
+	if mode == "" || mode == GrammarAuto {
+		if strings.ContainsAny(text, `"'\`) {
+			return GrammarAuto, nil
+		}
+		return GrammarLegacy, nil
+	}
+	return mode, nil
+}
+
+// parseGrammarAuto reads an annotation with quotes or backslashes. Tags
+// written before grammar 2 keep their meaning: grammar 2 is only used when
+// it parses and splits the annotation into other keys than the legacy
+// grammar, as for description='one, two'. Otherwise the legacy reading
+// wins, so connectsTo="B;C" still names two targets and C:\data is kept.
+func parseGrammarAuto(text string) []annotationPair {
+	legacy := parseGrammarLegacy(text)
+	v2, err := parseGrammarV2(text)
+	if err != nil || len(v2) == len(legacy) && sameKeys(legacy, v2) {
+		return legacy
+	}
+	return v2
+}
+
+// sameKeys reports whether two readings of an annotation have the same keys
+// in the same order.
+func sameKeys(a, b []annotationPair) bool {
+	for i := range a {
+		if a[i].Key != b[i].Key || a[i].HasValue != b[i].HasValue {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateGrammar reports whether g names a supported grammar mode.
+func ValidateGrammar(g Grammar) error {
+	switch g {
+	case "", GrammarAuto, GrammarLegacy, GrammarV2:
+		return nil
+	}
+	return fmt.Errorf("unknown grammar: %s (valid grammars: auto, 1, 2)", g)
+}
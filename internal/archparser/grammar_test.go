@@ -0,0 +1,254 @@
+package archparser_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParseAnnotationGrammarQuoting(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{"single quotes", `name=Payments,description='Stripe payments, EU region'`, "Stripe payments, EU region"},
+		{"double quotes", `name=Payments,description="Stripe payments; EU=region"`, "Stripe payments; EU=region"},
+		{"escaped quote", `name=Payments,description='it\'s "fine"'`, `it's "fine"`},
+		{"escaped comma", `name=Payments,description=Stripe payments\, EU region`, "Stripe payments, EU region"},
+		{"escape sequences", `name=Payments,description="line\nnext\ttab\\"`, "line\nnext\ttab\\"},
+		{"apostrophe in text", `name=Payments,description=user's payments`, "user's payments"},
+		{"spaces trimmed", `name=Payments , description =  padded value  `, "padded value"},
+		{"escaped trailing space", `name=Payments,description=kept\ `, "kept "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ann, err := archparser.ParseAnnotationGrammar(tt.tag, archparser.GrammarV2)
+			if err != nil {
+				t.Fatalf("ParseAnnotationGrammar error: %v", err)
+			}
+			if ann.Name != "Payments" || ann.Description != tt.want {
+				t.Errorf("got name %q description %q, want Payments and %q", ann.Name, ann.Description, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAnnotationGrammarLists(t *testing.T) {
+	t.Parallel()
+
+	ann, err := archparser.ParseAnnotationGrammar(
+		`name=Orders,connectsTo='Order DB'[label='SQL, read-only',dashed]; Queue [ label = publishes ]; "a;b"`,
+		archparser.GrammarV2)
+	if err != nil {
+		t.Fatalf("ParseAnnotationGrammar error: %v", err)
+	}
+
+	if want := []string{"Order DB", "Queue", "a;b"}; !reflect.DeepEqual(ann.ConnectsTo, want) {
+		t.Errorf("ConnectsTo = %q, want %q", ann.ConnectsTo, want)
+	}
+	conns := ann.ToConnections()
+	if conns[0].Label != "SQL, read-only" || conns[0].Style != "dashed=1" || conns[1].Label != "publishes" {
+		t.Errorf("unexpected connections %+v", conns)
+	}
+}
+
+func TestParseAnnotationGrammarErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		tag     string
+		offset  int
+		message string
+	}{
+		{"unterminated string", `name=A,description='oops`, 19, "unterminated quoted string"},
+		{"unknown escape", `name=A,description=a\qb`, 20, `unknown escape sequence \q`},
+		{"unfinished escape", `name=A\`, 6, "unfinished escape sequence"},
+		{"text after string", `name='A'B`, 8, "unexpected text after quoted string"},
+		{"missing key", `name=A,=B`, 7, `expected key, found "="`},
+		{"invalid key", `name=A,my key=B`, 7, `invalid key "my key"`},
+		{"double equals", `name=A=B`, 6, `expected "," or end of annotation, found "="`},
+		{"unclosed bracket", `name=A,connectsTo=B[label=x`, 19, "unclosed ["},
+		{"stray bracket", `name=A]`, 6, `expected "," or end of annotation, found "]"`},
+		{"unknown grammar", `grammar=3,name=A`, 8, `unknown grammar "3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := archparser.ParseAnnotationGrammar(tt.tag, archparser.GrammarV2)
+			var syntax *archparser.SyntaxError
+			if !errors.As(err, &syntax) {
+				t.Fatalf("expected a SyntaxError, got %v", err)
+			}
+			if syntax.Offset != tt.offset || !strings.Contains(syntax.Msg, tt.message) {
+				t.Errorf("got offset %d %q, want offset %d %q", syntax.Offset, syntax.Msg, tt.offset, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseAnnotationGrammarSelection(t *testing.T) {
+	t.Parallel()
+	const unquoted = `name=A,description=one=two`
+	const quoted = `name=A,description='one, two'`
+
+	tests := []struct {
+		name    string
+		tag     string
+		grammar archparser.Grammar
+		want    string
+		wantErr bool
+	}{
+		{"auto reads unquoted tags as before", unquoted, archparser.GrammarAuto, "one=two", false},
+		{"zero value is auto", unquoted, "", "one=two", false},
+		{"auto reads quoted tags with grammar 2", quoted, archparser.GrammarAuto, "one, two", false},
+		{"legacy keeps the quote", quoted, archparser.GrammarLegacy, "'one", false},
+		{"grammar 2 rejects ambiguous text", unquoted, archparser.GrammarV2, "", true},
+		{"grammar key overrides the mode", "grammar=1," + unquoted, archparser.GrammarV2, "one=two", false},
+		{"grammar key selects grammar 2", "grammar=2," + quoted, archparser.GrammarLegacy, "one, two", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ann, err := archparser.ParseAnnotationGrammar(tt.tag, tt.grammar)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAnnotationGrammar error: %v", err)
+			}
+			if ann.Description != tt.want {
+				t.Errorf("Description = %q, want %q", ann.Description, tt.want)
+			}
+		})
+	}
+}
+
+// Annotations written for the legacy grammar must read the same with grammar 2
+// unless they relied on its heuristics.
+func TestParseAnnotationGrammarCompatibility(t *testing.T) {
+	t.Parallel()
+	tags := []string{
+		"type=service,name=UserService,connectsTo=UserDatabase;MessageQueue",
+		"type=external,name=PaymentGateway,description=Stripe payments",
+		"type=gateway,name=APIGateway,connectsTo=AuthService;UserService;OrderService",
+		`type=service,name="Quoted"`,
+		"diagram,name=S,shape=iso:server,page=network,swimlane=AWS",
+		"name=Service,fillColor=#fff,strokeColor=#000,edgeStyle=elbowEdgeStyle,startArrow=block",
+		"name=Orders,connectsTo=OrderDB[label=SQL,dashed];Queue[label=publishes,direction=unidirectional],direction=bidirectional",
+		"type=database,name=OrderDB,zone=private,subnet=db-a,cidr=10.0.2.0/24,port=5432,protocol=tcp",
+		"name=Service,connectsTo=A; B ;C",
+	}
+
+	for _, tag := range tags {
+		legacy, err := archparser.ParseAnnotationGrammar(tag, archparser.GrammarLegacy)
+		if err != nil {
+			t.Fatalf("legacy grammar rejected %q: %v", tag, err)
+		}
+		v2, err := archparser.ParseAnnotationGrammar(tag, archparser.GrammarV2)
+		if err != nil {
+			t.Fatalf("grammar 2 rejected %q: %v", tag, err)
+		}
+		if !reflect.DeepEqual(legacy, v2) {
+			t.Errorf("grammars disagree on %q:\nlegacy: %+v\nv2:     %+v", tag, legacy, v2)
+		}
+	}
+}
+
+// Tags written for releases before grammar 2 read the same in auto mode,
+// even with quotes and backslashes.
+func TestParseAutoKeepsLegacyTags(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\n" +
+			"type A struct {\n" +
+			"\tField string `diagram:\"type=service,name=A,connectsTo=\\\"B;C\\\"\"`\n" +
+			"}\n\n" +
+			"type B struct {\n" +
+			"\tField string `diagram:\"type=database,name=B,description=C:\\\\data\"`\n" +
+			"}\n\n" +
+			"//diagram:component name=C,description=\"Stripe\" payments\n" +
+			"type C struct{}\n",
+	})
+
+	var diagrams []*model.Diagram
+	for _, grammar := range []archparser.Grammar{archparser.GrammarAuto, archparser.GrammarLegacy} {
+		p := archparser.New()
+		p.Grammar = grammar
+		diagram, err := p.Parse(filepath.Join(dir, "a.go"))
+		if err != nil {
+			t.Fatalf("grammar %s: Parse failed: %v", grammar, err)
+		}
+		if diags := p.Diagnostics(); len(diags) != 0 {
+			t.Errorf("grammar %s: diagnostics = %v, want none", grammar, diags)
+		}
+		diagrams = append(diagrams, diagram)
+	}
+
+	auto := diagrams[0]
+	var targets []string
+	for _, conn := range auto.Connections {
+		targets = append(targets, conn.Target)
+	}
+	if !reflect.DeepEqual(targets, []string{"B", "C"}) {
+		t.Errorf("targets = %q, want B and C", targets)
+	}
+	if b := auto.GetComponentByName("B"); b == nil || b.Description != `C:\data` {
+		t.Errorf("B = %+v, want description C:\\data", b)
+	}
+	if !reflect.DeepEqual(auto, diagrams[1]) {
+		t.Errorf("auto and legacy disagree:\nauto:   %+v\nlegacy: %+v", auto, diagrams[1])
+	}
+}
+
+func TestParseReportsSyntaxErrorPositions(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.go": "package a\n\n" +
+			"type A struct {\n" +
+			"\tField string `json:\"a\" diagram:\"name=A,description=\\\"oops\"`\n" +
+			"}\n\n" +
+			"//diagram:component  name=B,description='unterminated\n" +
+			"type B struct{}\n",
+	})
+
+	// Auto falls back to the legacy grammar on syntax errors.
+	p := archparser.New()
+	p.Grammar = archparser.GrammarV2
+	if _, err := p.Parse(filepath.Join(dir, "a.go")); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", diags)
+	}
+	// The tag starts at column 15; the escaped quote opening the description
+	// is 38 bytes into the literal.
+	if diags[0].Line != 4 || diags[0].Column != 53 {
+		t.Errorf("struct tag error at %d:%d, want 4:53: %v", diags[0].Line, diags[0].Column, diags[0])
+	}
+	if diags[1].Line != 7 || diags[1].Column != 41 {
+		t.Errorf("directive error at %d:%d, want 7:41: %v", diags[1].Line, diags[1].Column, diags[1])
+	}
+	for _, d := range diags {
+		if !strings.Contains(d.Message, "unterminated quoted string") {
+			t.Errorf("unexpected message %q", d.Message)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package archparser
+
+import (
+	"go/ast"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/protobuf"
+)
+
+// clientCall records that a Go type creates a gRPC client by calling the
+// New<Service>Client constructor generated by protoc-gen-go-grpc.
+type clientCall struct {
+	// From names the calling type.
+	From typeName
+	// Service is the Go name of the service.
+	Service string
+}
+
+// clientService returns the service named by a New<Service>Client function.
+func clientService(name string) (string, bool) {
+	service, ok := strings.CutPrefix(name, "New")
+	if !ok {
+		return "", false
+	}
+	service, ok = strings.CutSuffix(service, "Client")
+	if !ok || service == "" {
+		return "", false
+	}
+	r, _ := utf8.DecodeRuneInString(service)
+	return service, unicode.IsUpper(r)
+}
+
+// collectClientCalls records the gRPC clients created by a method or by a
+// NewX constructor, on behalf of the receiver or the returned type.
+func (fp *fileParser) collectClientCalls(fn *ast.FuncDecl) {
+	if fn.Body == nil {
+		return
+	}
+
+	var owner typeName
+	var ok bool
+	switch {
+	case fn.Recv != nil && len(fn.Recv.List) > 0:
+		owner, ok = fp.resolveType(fn.Recv.List[0].Type)
+	case strings.HasPrefix(fn.Name.Name, "New") && fn.Type.Results != nil && len(fn.Type.Results.List) > 0:
+		owner, ok = fp.resolveType(fn.Type.Results.List[0].Type)
+	}
+	if !ok {
+		return
+	}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var name string
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			name = fun.Name
+		case *ast.SelectorExpr:
+			name = fun.Sel.Name
+		}
+		if service, ok := clientService(name); ok {
+			c := clientCall{From: owner, Service: service}
+			for _, existing := range fp.result.Clients {
+				if existing == c {
+					return true
+				}
+			}
+			fp.result.Clients = append(fp.result.Clients, c)
+		}
+		return true
+	})
+}
+
+// serviceComponent returns the component of a service read from a .proto
+// file. When a component of the same name is already declared, such as the
+// Go type implementing the server, the service only adds its protocol to it.
+func serviceComponent(diagram *model.Diagram, svc protobuf.Service, module string) model.Component {
+	comp := svc.Component()
+	if diagram.GetComponentByName(comp.Name) != nil {
+		comp = model.Component{Name: comp.Name, Protocol: comp.Protocol, Partial: true}
+	}
+	comp.Module = module
+	return comp
+}
+
+// connectClients connects every annotated type that creates a client of a
+// service read from a .proto file to that service, once per RPC.
+func connectClients(diagram *model.Diagram, results []*fileResult, services []protobuf.Service) {
+	if len(services) == 0 {
+		return
+	}
+
+	byGoName := make(map[string]protobuf.Service)
+	for _, svc := range services {
+		if _, exists := byGoName[svc.GoName()]; !exists {
+			byGoName[svc.GoName()] = svc
+		}
+	}
+	types := declaredTypes(results)
+
+	type edge struct{ source, target string }
+	seen := make(map[edge]bool)
+	for i, result := range results {
+		for _, call := range result.Clients {
+			source, ok := types.component(i, call.From)
+			if !ok {
+				continue
+			}
+			svc, ok := byGoName[call.Service]
+			if !ok || source == svc.Name || seen[edge{source, svc.Name}] {
+				continue
+			}
+			seen[edge{source, svc.Name}] = true
+
+			page := ""
+			if comp := diagram.GetComponentByName(source); comp != nil {
+				page = comp.Page
+			}
+			for _, conn := range svc.Connections(source) {
+				conn.Page = page
+				diagram.AddConnection(conn)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParseProtoServices(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"proto/orders.proto": "syntax = \"proto3\";\npackage shop.v1;\n\n" +
+			"// Places orders.\n" +
+			"service OrderService {\n" +
+			"  rpc PlaceOrder(PlaceOrderRequest) returns (Order);\n" +
+			"  rpc Watch(stream WatchRequest) returns (stream Order);\n" +
+			"}\n\n" +
+			"service Inventory {\n  rpc Reserve(ReserveRequest) returns (Reservation);\n}\n",
+		"web/web.go": "package web\n\n" +
+			"import pb \"example.com/shop/gen/shop/v1\"\n\n" +
+			"//diagram:component\n" +
+			"type Storefront struct{ orders pb.OrderServiceClient }\n\n" +
+			"func NewStorefront(conn any) *Storefront {\n" +
+			"\treturn &Storefront{orders: pb.NewOrderServiceClient(conn)}\n" +
+			"}\n\n" +
+			"func (s *Storefront) reconnect(conn any) {\n" +
+			"\ts.orders = pb.NewOrderServiceClient(conn)\n" +
+			"}\n",
+		"orders/orders.go": "package orders\n\n" +
+			"import pb \"example.com/shop/gen/shop/v1\"\n\n" +
+			"//diagram:component type=service\n" +
+			"type OrderService struct{}\n\n" +
+			"func (o *OrderService) inventory(conn any) pb.InventoryClient {\n" +
+			"\treturn pb.NewInventoryClient(conn)\n" +
+			"}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir+"/...", filepath.Join(dir, "proto", "orders.proto"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	// The Go server type is merged with the service of the same name.
+	orders := diagram.GetComponentByName("OrderService")
+	if orders == nil || orders.Protocol != "grpc" || orders.Type != model.ComponentTypeService {
+		t.Fatalf("OrderService = %+v, want the merged gRPC service", orders)
+	}
+	inventory := diagram.GetComponentByName("Inventory")
+	if inventory == nil || inventory.Description != "shop.v1.Inventory" {
+		t.Fatalf("Inventory = %+v, want a service described by its full name", inventory)
+	}
+
+	var edges []string
+	for _, conn := range diagram.Connections {
+		edge := conn.Source + "->" + conn.Target + ":" + conn.Label + "/" + conn.Protocol
+		if conn.Direction == model.ConnectionDirectionBidirectional {
+			edge += "<>"
+		}
+		edges = append(edges, edge)
+	}
+	got := strings.Join(edges, " ")
+	want := "OrderService->Inventory:Reserve/grpc " +
+		"Storefront->OrderService:PlaceOrder/grpc Storefront->OrderService:Watch/grpc<>"
+	if got != want {
+		t.Errorf("connections = %s\nwant %s", got, want)
+	}
+}
+
+func TestParseProtoClientsNeedProtoFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"web/web.go": "package web\n\n" +
+			"//diagram:component\n" +
+			"type Storefront struct{}\n\n" +
+			"func (s *Storefront) dial(conn any) { _ = NewOrderServiceClient(conn) }\n",
+		"orders.proto": "service OrderService {\n  rpc PlaceOrder(Req) returns (Resp);\n}\n",
+	})
+
+	// Walking the tree does not read .proto files, so no client edges appear.
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 || len(diagram.Connections) != 0 {
+		t.Errorf("got %d components and %d connections, want 1 and 0",
+			len(diagram.Components), len(diagram.Connections))
+	}
+}
@@ -0,0 +1,130 @@
+package archparser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"diagram-gen/internal/compose"
+	"diagram-gen/internal/dot"
+	"diagram-gen/internal/drawio"
+	"diagram-gen/internal/kubernetes"
+	"diagram-gen/internal/markdown"
+	"diagram-gen/internal/mermaid"
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/modelfile"
+	"diagram-gen/internal/openapi"
+	"diagram-gen/internal/plantuml"
+	"diagram-gen/internal/protobuf"
+)
+
+// parseImported reads the components and connections of a file that is not
+// Go source. It reports false for Go files. YAML and JSON files other than
+// compose and *.diagram.* files are recognized by their content.
+func (p *Parser) parseImported(path string, src []byte) (*fileResult, bool, error) {
+	result := &fileResult{Path: path, Types: make(map[string]string)}
+	discoverable := modelfile.IsDiscoverable(filepath.Base(path))
+
+	var err error
+	switch {
+	case protobuf.IsProtoFile(path):
+		result.ProtoServices, err = protobuf.Parse(path, src)
+	case drawio.IsDrawIOFile(path):
+		var diagram *model.Diagram
+		if diagram, err = drawio.Read(path, src); err == nil {
+			result.Components, result.Connections = diagram.Components, diagram.Connections
+		}
+	case dot.IsDOTFile(path):
+		var diagram *model.Diagram
+		if diagram, err = dot.Parse(path, src); err == nil {
+			result.Components, result.Connections = diagram.Components, diagram.Connections
+		}
+	case mermaid.IsMermaidFile(path), plantuml.IsPlantUMLFile(path), markdown.IsMarkdownFile(path):
+		var diagram *model.Diagram
+		if diagram, err = parseText(path, src); err == nil {
+			result.Components, result.Connections = diagram.Components, diagram.Connections
+			for _, comp := range diagram.Components {
+				if comp.Partial {
+					result.Linked = append(result.Linked, comp.Name)
+				}
+			}
+		}
+	case compose.IsComposeFile(path):
+		var diagram *model.Diagram
+		if diagram, err = compose.Load(path, src); err == nil {
+			result.Components, result.Connections = diagram.Components, diagram.Connections
+		}
+	case !discoverable && kubernetes.IsManifestFile(path) && kubernetes.IsManifest(src):
+		result.Resources, err = kubernetes.Load(path, src)
+	case !discoverable && modelfile.IsModelFile(path) && openapi.IsDocument(src):
+		var doc *openapi.Result
+		if doc, err = openapi.Load(path, src, openapi.Options{Tags: p.OpenAPITags}); err == nil {
+			result.Components, result.Connections = doc.Components, doc.Connections
+			result.OptionalConnections = doc.ServerConnections
+		}
+	case modelfile.IsModelFile(path):
+		result, err = parseModelFile(path, src)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return result, true, nil
+}
+
+// parseText reads a Mermaid or PlantUML file, or the diagrams fenced in a
+// Markdown document.
+func parseText(path string, src []byte) (*model.Diagram, error) {
+	switch {
+	case mermaid.IsMermaidFile(path):
+		return mermaid.Parse(path, src)
+	case plantuml.IsPlantUMLFile(path):
+		return plantuml.Parse(path, src)
+	}
+	return markdown.Load(path, src)
+}
+
+// isInputFile reports whether a file found in a directory is parsed: Go
+// source, a model file such as saas.diagram.yaml or, with Kubernetes, a YAML
+// file that keepImported then checks is a manifest.
+func (p *Parser) isInputFile(name string) bool {
+	return isGoSource(name) || modelfile.IsDiscoverable(name) ||
+		p.Kubernetes && kubernetes.IsManifestFile(name)
+}
+
+// keepImported reports whether a file found in a directory that is not Go
+// source is read. YAML files other than model files are only read when they
+// are Kubernetes manifests. A file that cannot be read is kept, so the
+// problem is reported when it is parsed.
+func keepImported(path string) bool {
+	if modelfile.IsDiscoverable(filepath.Base(path)) {
+		return true
+	}
+	data, err := os.ReadFile(path)
+	return err != nil || kubernetes.IsManifest(data)
+}
+
+// parseModelFile reads the components and connections of a JSON or YAML
+// model file. Schema violations are reported and reject the whole file.
+func parseModelFile(path string, src []byte) (*fileResult, error) {
+	result := &fileResult{Path: path, Types: make(map[string]string)}
+	diagram, err := modelfile.Load(path, src)
+	var invalid *modelfile.ValidationError
+	switch {
+	case err == nil:
+		result.Components, result.Connections = diagram.Components, diagram.Connections
+		return result, nil
+	case !errors.As(err, &invalid):
+		return nil, err
+	}
+
+	for _, problem := range invalid.Problems {
+		result.Diagnostics = append(result.Diagnostics, Diagnostic{
+			File:     path,
+			Severity: SeverityError,
+			Message:  "model file ignored: " + problem.String(),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,247 @@
+package archparser
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+// ExternalImports controls how imports from outside the module appear in an import graph.
+type ExternalImports string
+
+const (
+	// ExternalImportsCollapse draws one node for the standard library and one for third-party modules.
+	ExternalImportsCollapse ExternalImports = "collapse"
+	// ExternalImportsExpand draws one node per standard library package and per required module.
+	ExternalImportsExpand ExternalImports = "expand"
+	// ExternalImportsHide leaves imports from outside the module out of the diagram.
+	ExternalImportsHide ExternalImports = "hide"
+)
+
+// Names of the collapsed external nodes.
+const (
+	StdlibNodeName     = "Go standard library"
+	ThirdPartyNodeName = "Third-party modules"
+)
+
+// ParseImportGraph builds a diagram of the package import graph for the files
+// matched by patterns. Each package of the enclosing module becomes a
+// component named by its module-relative path, and each import becomes a
+// connection. No annotations are needed. When the files belong to several
+// modules, such as those of a go.work file, packages are named by their
+// import path and imports between the modules are connections too.
+func (p *Parser) ParseImportGraph(patterns ...string) (*model.Diagram, error) {
+	return p.ParseImportGraphContext(context.Background(), patterns...)
+}
+
+// ParseImportGraphContext is ParseImportGraph with a context that stops the
+// parse when canceled. The imports of the files are read on the worker pool.
+func (p *Parser) ParseImportGraphContext(ctx context.Context, patterns ...string) (*model.Diagram, error) {
+	switch p.ExternalImports {
+	case "", ExternalImportsCollapse, ExternalImportsExpand, ExternalImportsHide:
+	default:
+		return nil, fmt.Errorf("unknown external imports mode: %s (valid modes: collapse, expand, hide)", p.ExternalImports)
+	}
+
+	files, err := p.ResolvePatterns(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Model files, imported formats and files outside every module have no
+	// imports to draw.
+	index := make(moduleIndex)
+	var goFiles []string
+	var fileModules []*GoModule
+	var modules graphModules
+	for _, file := range files {
+		if filepath.Ext(file) != ".go" {
+			continue
+		}
+		mod := index.moduleOf(filepath.Dir(file))
+		if mod == nil {
+			continue
+		}
+		goFiles = append(goFiles, file)
+		fileModules = append(fileModules, mod)
+		if !slices.ContainsFunc(modules, func(m *GoModule) bool { return m.Path == mod.Path }) {
+			modules = append(modules, mod)
+		}
+	}
+	if len(modules) == 0 {
+		root := "."
+		if len(patterns) > 0 {
+			root, _ = splitPattern(patterns[0])
+			if !isDir(root) {
+				root = filepath.Dir(root)
+			}
+		}
+		if _, err := FindGoModule(root); err != nil {
+			return nil, err
+		}
+	}
+
+	parsed := make([]*ast.File, len(goFiles))
+	errs := make([]error, len(goFiles))
+	err = p.runPool(ctx, len(goFiles), func(i int) {
+		parsed[i], errs[i] = parser.ParseFile(p.fset, goFiles[i], nil, parser.ImportsOnly)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	diagram := &model.Diagram{
+		Type:        model.DiagramTypeImports,
+		Components:  []model.Component{},
+		Connections: []model.Connection{},
+	}
+	components := make(map[string]bool)
+	connections := make(map[[2]string]bool)
+
+	addComponent := func(comp model.Component) {
+		if components[comp.Name] {
+			return
+		}
+		components[comp.Name] = true
+		diagram.AddComponent(comp)
+	}
+
+	for i, file := range goFiles {
+		mod, f := fileModules[i], parsed[i]
+		if errs[i] != nil {
+			p.diagnostics = append(p.diagnostics, fileErrorDiagnostics(file, errs[i])...)
+			continue
+		}
+
+		importPath, ok := mod.ImportPath(filepath.Dir(file))
+		if !ok {
+			continue
+		}
+		source := modules.nodeName(mod, importPath)
+		addComponent(model.Component{
+			Type:        model.ComponentTypeService,
+			Name:        source,
+			Description: f.Name.Name,
+		})
+
+		for _, spec := range f.Imports {
+			imported, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+
+			target, comp, ok := p.importNode(modules, mod, imported)
+			if !ok || target == source {
+				continue
+			}
+			if comp != nil {
+				addComponent(*comp)
+			}
+
+			key := [2]string{source, target}
+			if connections[key] {
+				continue
+			}
+			connections[key] = true
+			diagram.AddConnection(model.Connection{
+				Source:    source,
+				Target:    target,
+				Direction: model.ConnectionDirectionUnidirectional,
+			})
+		}
+	}
+
+	return diagram, nil
+}
+
+// graphModules holds the modules of the files of an import graph.
+type graphModules []*GoModule
+
+// owner returns the module providing an import path, the one with the
+// longest path when modules are nested, or nil.
+func (m graphModules) owner(importPath string) *GoModule {
+	var best *GoModule
+	for _, mod := range m {
+		if mod.Contains(importPath) && (best == nil || len(mod.Path) > len(best.Path)) {
+			best = mod
+		}
+	}
+	return best
+}
+
+// nodeName names a package of mod: by its module-relative path in a single
+// module, by its import path across several.
+func (m graphModules) nodeName(mod *GoModule, importPath string) string {
+	if len(m) > 1 {
+		return importPath
+	}
+	return packageNodeName(mod, importPath)
+}
+
+// importNode returns the node an import of a file in mod points to. For
+// imports outside every module it also returns the external component to add.
+func (p *Parser) importNode(modules graphModules, mod *GoModule, imported string) (string, *model.Component, bool) {
+	if owner := modules.owner(imported); owner != nil {
+		return modules.nodeName(owner, imported), nil, true
+	}
+
+	mode := p.ExternalImports
+	if mode == "" {
+		mode = ExternalImportsCollapse
+	}
+
+	var name, description string
+	switch mode {
+	case ExternalImportsHide:
+		return "", nil, false
+	case ExternalImportsExpand:
+		name = imported
+		description = "standard library"
+		if !isStdlibImport(imported) {
+			description = "third-party module"
+			if required, ok := mod.RequiredModule(imported); ok {
+				name = required
+			}
+		}
+	default:
+		name = StdlibNodeName
+		if !isStdlibImport(imported) {
+			name = ThirdPartyNodeName
+		}
+	}
+
+	return name, &model.Component{
+		Type:        model.ComponentTypeExternal,
+		Name:        name,
+		Description: description,
+	}, true
+}
+
+// packageNodeName names a module package by its path relative to the module root.
+func packageNodeName(mod *GoModule, importPath string) string {
+	if importPath == mod.Path {
+		return mod.Path
+	}
+	return strings.TrimPrefix(importPath, mod.Path+"/")
+}
+
+// isStdlibImport reports whether an import path belongs to the standard
+// library, using the go command's rule that only those lack a dot in their
+// first path element.
+func isStdlibImport(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
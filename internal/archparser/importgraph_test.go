@@ -0,0 +1,212 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+// moduleTree is a module importing its own packages, the standard library
+// and required modules.
+var moduleTree = map[string]string{
+	"go.mod": `module example.com/shop // the shop
+
+go 1.22
+
+require github.com/spf13/cobra v1.10.2
+
+require (
+	github.com/spf13/pflag v1.0.10 // indirect
+	"golang.org/x/text" v0.14.0
+)
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/shop/internal/orders"
+	"github.com/spf13/cobra"
+)
+
+func main() { fmt.Println(orders.Name, cobra.Command{}) }
+`,
+	"internal/orders/orders.go": `package orders
+
+import (
+	"strings"
+
+	"example.com/shop/internal/store"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/language"
+)
+
+const Name = "orders"
+`,
+	"internal/orders/orders_test.go": `package orders
+
+import "testing"
+`,
+	"internal/store/store.go": `package store
+
+import "os"
+`,
+}
+
+type importEdge struct{ source, target string }
+
+// importGraph parses the import graph of every package under root, or of
+// the modules of root when it names a go.work file.
+func importGraph(t *testing.T, mode archparser.ExternalImports, root string) (*model.Diagram, map[importEdge]bool) {
+	t.Helper()
+	p := archparser.New()
+	p.ExternalImports = mode
+	pattern := root
+	if filepath.Base(root) != "go.work" {
+		pattern += "/..."
+	}
+	diagram, err := p.ParseImportGraph(pattern)
+	if err != nil {
+		t.Fatalf("ParseImportGraph failed: %v", err)
+	}
+	edges := make(map[importEdge]bool)
+	for _, conn := range diagram.Connections {
+		edges[importEdge{conn.Source, conn.Target}] = true
+	}
+	return diagram, edges
+}
+
+func TestParseImportGraphCollapsed(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	diagram, edges := importGraph(t, "", root)
+
+	if diagram.Type != model.DiagramTypeImports {
+		t.Errorf("Type = %q, want %q", diagram.Type, model.DiagramTypeImports)
+	}
+	if len(diagram.Components) != 5 {
+		t.Errorf("expected 5 components, got %+v", diagram.Components)
+	}
+	if comp := diagram.GetComponentByName(archparser.StdlibNodeName); comp == nil || comp.Type != model.ComponentTypeExternal {
+		t.Errorf("expected collapsed standard library node, got %+v", comp)
+	}
+
+	want := []importEdge{
+		{"example.com/shop", "internal/orders"},
+		{"example.com/shop", archparser.StdlibNodeName},
+		{"example.com/shop", archparser.ThirdPartyNodeName},
+		{"internal/orders", "internal/store"},
+		{"internal/orders", archparser.StdlibNodeName},
+		{"internal/orders", archparser.ThirdPartyNodeName},
+		{"internal/store", archparser.StdlibNodeName},
+	}
+	if len(edges) != len(want) || len(diagram.Connections) != len(want) {
+		t.Errorf("expected %d connections, got %+v", len(want), diagram.Connections)
+	}
+	for _, w := range want {
+		if !edges[w] {
+			t.Errorf("missing connection %s -> %s", w.source, w.target)
+		}
+	}
+}
+
+func TestParseImportGraphExpanded(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	diagram, edges := importGraph(t, archparser.ExternalImportsExpand, root)
+
+	for _, name := range []string{"fmt", "strings", "os", "github.com/spf13/cobra", "github.com/spf13/pflag", "golang.org/x/text"} {
+		if diagram.GetComponentByName(name) == nil {
+			t.Errorf("expected external component %s", name)
+		}
+	}
+	if !edges[importEdge{"internal/orders", "golang.org/x/text"}] {
+		t.Error("expected import of a package to map to its required module")
+	}
+}
+
+func TestParseImportGraphHidden(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	diagram, _ := importGraph(t, archparser.ExternalImportsHide, root)
+
+	if len(diagram.Components) != 3 || len(diagram.Connections) != 2 {
+		t.Errorf("expected only module packages, got %+v and %+v", diagram.Components, diagram.Connections)
+	}
+}
+
+func TestParseImportGraphErrors(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	p := archparser.New()
+	p.ExternalImports = "sideways"
+	if _, err := p.ParseImportGraph(root + "/..."); err == nil {
+		t.Error("expected error for unknown external imports mode")
+	}
+
+	noModule := t.TempDir()
+	writeTree(t, noModule, map[string]string{"a.go": "package a\n"})
+	if _, err := archparser.New().ParseImportGraph(noModule); err == nil {
+		t.Error("expected error without go.mod")
+	}
+}
+
+func TestReadGoModule(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	mod, err := archparser.FindGoModule(filepath.Join(root, "internal", "store"))
+	if err != nil {
+		t.Fatalf("FindGoModule failed: %v", err)
+	}
+	if mod.Path != "example.com/shop" {
+		t.Errorf("Path = %q, want example.com/shop", mod.Path)
+	}
+	if len(mod.Requires) != 3 {
+		t.Errorf("expected 3 requirements, got %v", mod.Requires)
+	}
+
+	importPath, ok := mod.ImportPath(filepath.Join(root, "internal", "store"))
+	if !ok || importPath != "example.com/shop/internal/store" {
+		t.Errorf("ImportPath = %q, %v", importPath, ok)
+	}
+	if _, ok := mod.ImportPath(filepath.Dir(root)); ok {
+		t.Error("expected directory outside the module to be rejected")
+	}
+
+	writeTree(t, root, map[string]string{"bad/go.mod": "go 1.22\n"})
+	if _, err := archparser.ReadGoModule(filepath.Join(root, "bad", "go.mod")); err == nil {
+		t.Error("expected error for go.mod without module directive")
+	}
+}
+
+func TestParseImportGraphWorkspace(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, workspaceTree)
+	diagram, edges := importGraph(t, archparser.ExternalImportsHide, filepath.Join(root, "ws", "go.work"))
+
+	// Packages of every module in use are drawn, by import path, and the
+	// import of orders from billing joins them. The nested tools module is
+	// not in use.
+	names := make(map[string]bool)
+	for _, comp := range diagram.Components {
+		names[comp.Name] = true
+	}
+	for _, want := range []string{"example.com/billing/invoices", "example.com/orders"} {
+		if !names[want] {
+			t.Errorf("missing package %s in %+v", want, diagram.Components)
+		}
+	}
+	if names["example.com/orders/tools"] {
+		t.Errorf("unexpected package of the nested tools module in %+v", diagram.Components)
+	}
+	if !edges[importEdge{"example.com/billing/invoices", "example.com/orders"}] {
+		t.Errorf("expected billing/invoices -> orders, got %+v", diagram.Connections)
+	}
+}
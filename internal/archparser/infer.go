@@ -0,0 +1,223 @@
+package archparser
+
+import (
+	"go/ast"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+// typeName names a Go type by the import path of its package, left empty
+// for the package of the file it appears in.
+type typeName struct {
+	Package string
+	Name    string
+}
+
+// typeReference records that one Go type depends on another, through a
+// struct field or a constructor parameter.
+type typeReference struct {
+	From typeName
+	To   typeName
+}
+
+// importPaths maps the names a file uses for its imports to import paths.
+func importPaths(f *ast.File) map[string]string {
+	paths := make(map[string]string)
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		local := path.Base(importPath)
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		paths[local] = importPath
+	}
+	return paths
+}
+
+// resolveType returns the named type behind a type expression, looking
+// through pointers, slices, arrays, maps and channels. ok is false for
+// types that have no name, or whose package is not imported.
+func (fp *fileParser) resolveType(expr ast.Expr) (typeName, bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return typeName{Name: t.Name}, true
+	case *ast.StarExpr:
+		return fp.resolveType(t.X)
+	case *ast.ArrayType:
+		return fp.resolveType(t.Elt)
+	case *ast.MapType:
+		return fp.resolveType(t.Value)
+	case *ast.ChanType:
+		return fp.resolveType(t.Value)
+	case *ast.IndexExpr:
+		return fp.resolveType(t.X)
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		if !ok {
+			return typeName{}, false
+		}
+		importPath, ok := fp.imports[x.Name]
+		if !ok {
+			return typeName{}, false
+		}
+		return typeName{Package: importPath, Name: t.Sel.Name}, true
+	}
+	return typeName{}, false
+}
+
+func (fp *fileParser) addReference(owner string, expr ast.Expr) {
+	to, ok := fp.resolveType(expr)
+	if !ok {
+		return
+	}
+	fp.result.References = append(fp.result.References, typeReference{
+		From: typeName{Name: owner},
+		To:   to,
+	})
+}
+
+// collectConstructor records the parameters of a NewX function as
+// dependencies of the type it returns.
+func (fp *fileParser) collectConstructor(fn *ast.FuncDecl) {
+	if fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "New") {
+		return
+	}
+	if fn.Type.Results == nil || len(fn.Type.Results.List) == 0 {
+		return
+	}
+
+	owner, ok := fp.resolveType(fn.Type.Results.List[0].Type)
+	if !ok {
+		return
+	}
+
+	for _, param := range fn.Type.Params.List {
+		to, ok := fp.resolveType(param.Type)
+		if !ok {
+			continue
+		}
+		fp.result.References = append(fp.result.References, typeReference{From: owner, To: to})
+	}
+}
+
+// typeIndex finds the component declared on a Go type. Packages inside a
+// module are known by their import path. Outside any module, a package is
+// known by its directory, and imports reach it by its name alone.
+type typeIndex struct {
+	packages []string
+	types    map[string]string
+	byName   map[string]string
+	paths    map[string]bool
+}
+
+// declaredTypes indexes the types annotated in results. The first
+// declaration of a type wins.
+func declaredTypes(results []*fileResult) *typeIndex {
+	idx := &typeIndex{
+		packages: make([]string, len(results)),
+		types:    make(map[string]string),
+		byName:   make(map[string]string),
+		paths:    make(map[string]bool),
+	}
+	modules := make(moduleIndex)
+	for i, result := range results {
+		dir := filepath.Dir(result.Path)
+		pkg := result.Package
+		mod := modules.moduleOf(dir)
+		if mod != nil {
+			pkg = packageImportPath(mod, dir)
+			idx.paths[pkg] = true
+		}
+		idx.packages[i] = pkg
+
+		_, pkgName, _ := strings.Cut(result.Package, ":")
+		for name, comp := range result.Types {
+			if _, exists := idx.types[pkg+"."+name]; !exists {
+				idx.types[pkg+"."+name] = comp
+			}
+			if _, exists := idx.byName[pkgName+"."+name]; mod == nil && !exists {
+				idx.byName[pkgName+"."+name] = comp
+			}
+		}
+	}
+	return idx
+}
+
+// component returns the component declared on a type named in the file
+// of results[i].
+func (idx *typeIndex) component(i int, t typeName) (string, bool) {
+	var comp string
+	var ok bool
+	switch {
+	case t.Package == "":
+		comp, ok = idx.types[idx.packages[i]+"."+t.Name]
+	case idx.paths[t.Package]:
+		comp, ok = idx.types[t.Package+"."+t.Name]
+	default:
+		comp, ok = idx.byName[path.Base(t.Package)+"."+t.Name]
+	}
+	return comp, ok
+}
+
+// packageImportPath returns the import path of the package in dir, a
+// directory of mod.
+func packageImportPath(mod *GoModule, dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return mod.Path
+	}
+	rel, err := filepath.Rel(mod.Dir, abs)
+	if err != nil || rel == "." {
+		return mod.Path
+	}
+	return mod.Path + "/" + filepath.ToSlash(rel)
+}
+
+// inferConnections adds a connection for every reference between two
+// annotated types that is not already declared with connectsTo.
+// Inferred connections are marked so they can be styled or filtered.
+func inferConnections(diagram *model.Diagram, results []*fileResult) {
+	types := declaredTypes(results)
+
+	type edge struct{ source, target string }
+	existing := make(map[edge]bool)
+	for _, conn := range diagram.Connections {
+		existing[edge{conn.Source, conn.Target}] = true
+	}
+
+	for i, result := range results {
+		for _, ref := range result.References {
+			source, ok := types.component(i, ref.From)
+			if !ok {
+				continue
+			}
+			target, ok := types.component(i, ref.To)
+			if !ok || source == target {
+				continue
+			}
+			if existing[edge{source, target}] {
+				continue
+			}
+			existing[edge{source, target}] = true
+
+			page := ""
+			if comp := diagram.GetComponentByName(source); comp != nil {
+				page = comp.Page
+			}
+			diagram.AddConnection(model.Connection{
+				Source:    source,
+				Target:    target,
+				Direction: model.ConnectionDirectionUnidirectional,
+				Page:      page,
+				Inferred:  true,
+			})
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package archparser_test
+
+import (
+	"reflect"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+// inferenceTree declares types that reference each other through fields,
+// constructor parameters and an aliased import.
+var inferenceTree = map[string]string{
+	"orders/orders.go": `package orders
+
+import (
+	"context"
+
+	st "example.com/app/store"
+)
+
+//diagram:component type=service,name=OrderService,connectsTo=Payments
+type OrderService struct {
+	db      *OrderDatabase
+	queue   chan Event
+	cache   map[string]*st.Cache
+	payment Payments
+	ctx     context.Context
+}
+
+//diagram:component type=database
+type OrderDatabase struct{}
+
+//diagram:component type=external
+type Payments struct{}
+
+type Event struct{}
+
+//diagram:component type=service
+type Notifier struct{}
+
+// NewNotifier wires the notifier.
+func NewNotifier(db *OrderDatabase, orders []*OrderService) *Notifier {
+	return &Notifier{}
+}
+`,
+	"store/cache.go": "package store\n\n" +
+		"type Cache struct {\n" +
+		"\tField string `diagram:\"type=cache,name=OrderCache,page=Data\"`\n" +
+		"}\n",
+}
+
+func TestParseInferConnections(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, inferenceTree)
+
+	p := archparser.New()
+	p.InferConnections = true
+	diagram, err := p.Parse(root + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	type edge struct {
+		source, target string
+		inferred       bool
+	}
+	got := make(map[edge]int)
+	for _, conn := range diagram.Connections {
+		got[edge{conn.Source, conn.Target, conn.Inferred}]++
+	}
+
+	want := []edge{
+		{"OrderService", "Payments", false},
+		{"OrderService", "OrderDatabase", true},
+		{"OrderService", "OrderCache", true},
+		{"Notifier", "OrderDatabase", true},
+		{"Notifier", "OrderService", true},
+	}
+	if len(diagram.Connections) != len(want) {
+		t.Errorf("expected %d connections, got %d: %+v", len(want), len(diagram.Connections), diagram.Connections)
+	}
+	for _, w := range want {
+		if got[w] != 1 {
+			t.Errorf("expected exactly one connection %+v, got %d", w, got[w])
+		}
+	}
+
+	for _, conn := range diagram.Connections {
+		if conn.Inferred && conn.Direction != model.ConnectionDirectionUnidirectional {
+			t.Errorf("inferred connection should be unidirectional: %+v", conn)
+		}
+	}
+}
+
+func TestParseWithoutInference(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, inferenceTree)
+
+	diagram, err := archparser.New().Parse(root + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(diagram.Connections) != 1 || diagram.Connections[0].Inferred {
+		t.Errorf("expected only the declared connection, got %+v", diagram.Connections)
+	}
+}
+
+func TestParseInferConnectionsSameNamedPackages(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, map[string]string{
+		"go.mod": "module example.com/app\n",
+		"internal/orders/orders.go": `package orders
+
+import "example.com/app/internal/orders/store"
+
+//diagram:component type=service
+type OrderService struct {
+	db *store.Store
+}
+`,
+		"internal/orders/store/store.go": `package store
+
+//diagram:component type=database,name=OrderStore
+type Store struct{}
+`,
+		"internal/users/users.go": `package users
+
+import "example.com/app/internal/users/store"
+
+//diagram:component type=service
+type UserService struct{}
+
+// NewUserService wires the service.
+func NewUserService(db *store.Store) *UserService {
+	return &UserService{}
+}
+`,
+		"internal/users/store/store.go": `package store
+
+//diagram:component type=database,name=UserStore
+type Store struct{}
+`,
+	})
+
+	p := archparser.New()
+	p.InferConnections = true
+	diagram, err := p.Parse(root + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	type edge struct{ source, target string }
+	var got []edge
+	for _, conn := range diagram.Connections {
+		got = append(got, edge{conn.Source, conn.Target})
+	}
+	want := []edge{{"OrderService", "OrderStore"}, {"UserService", "UserStore"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected connections %v, got %v", want, got)
+	}
+}
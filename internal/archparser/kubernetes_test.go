@@ -0,0 +1,97 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseKubernetesManifests(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component\n" +
+			"type Orders struct{}\n",
+		"deploy/ingress.yaml": "apiVersion: networking.k8s.io/v1\n" +
+			"kind: Ingress\n" +
+			"metadata:\n  name: shop\n" +
+			"spec:\n  defaultBackend:\n    service:\n      name: orders\n",
+		"deploy/orders.yml": "apiVersion: v1\n" +
+			"kind: Service\n" +
+			"metadata:\n  name: orders\n" +
+			"spec:\n  selector:\n    app: orders\n" +
+			"---\n" +
+			"apiVersion: apps/v1\n" +
+			"kind: Deployment\n" +
+			"metadata:\n  name: orders\n" +
+			"spec:\n  template:\n    metadata:\n      labels:\n        app: orders\n",
+		// YAML files that are not manifests are left alone.
+		"deploy/values.yaml":        "replicas: 3\n",
+		"deploy/docker-compose.yml": "services:\n  web:\n    image: nginx\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 {
+		t.Errorf("without Kubernetes got %+v, want only Orders", diagram.Components)
+	}
+
+	p = archparser.New()
+	p.Kubernetes = true
+	diagram, err = p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	var names []string
+	for _, comp := range diagram.Components {
+		names = append(names, comp.Name)
+	}
+	want := "Orders default/ingress/shop default/service/orders default/deployment/orders"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("components = %s, want %s", got, want)
+	}
+
+	// Edges are resolved across files.
+	var edges []string
+	for _, conn := range diagram.Connections {
+		edges = append(edges, conn.Source+"->"+conn.Target)
+	}
+	wantEdges := "default/ingress/shop->default/service/orders default/service/orders->default/deployment/orders"
+	if got := strings.Join(edges, " "); got != wantEdges {
+		t.Errorf("connections = %s, want %s", got, wantEdges)
+	}
+}
+
+func TestParseKubernetesDuplicateResource(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n"
+	writeTree(t, dir, map[string]string{
+		"a.yaml": manifest,
+		"b.yaml": "# copy\n" + manifest,
+	})
+
+	// Named manifests are read without setting Kubernetes.
+	p := archparser.New()
+	if _, err := p.Parse(filepath.Join(dir, "a.yaml"), filepath.Join(dir, "b.yaml")); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("got diagnostics %v, want one duplicate warning", diags)
+	}
+	if diags[0].File != filepath.Join(dir, "b.yaml") || diags[0].Line != 2 ||
+		!strings.Contains(diags[0].Message, "duplicate declaration") {
+		t.Errorf("diagnostic = %+v, want a duplicate declaration at b.yaml:2", diags[0])
+	}
+}
@@ -0,0 +1,106 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParseModelFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component page=Shop\n" +
+			"type Orders struct{}\n",
+		"orders/saas.diagram.yaml": "components:\n" +
+			"  - name: Stripe\n" +
+			"    type: external\n" +
+			"    page: Shop\n" +
+			"  - name: Orders\n" +
+			"    partial: true\n" +
+			"    label: Order Service\n" +
+			"connections:\n" +
+			"  - source: Orders\n" +
+			"    target: Stripe\n" +
+			"    port: 443\n",
+		// Only *.diagram.* files are picked up from directories.
+		"orders/config.yaml": "components: not a list\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	want := []model.Component{
+		{Name: "Orders", Type: model.ComponentTypeService, Label: "Order Service", Page: "Shop"},
+		{Name: "Stripe", Type: model.ComponentTypeExternal, Page: "Shop"},
+	}
+	if len(diagram.Components) != len(want) {
+		t.Fatalf("got %d components %+v, want %d", len(diagram.Components), diagram.Components, len(want))
+	}
+	for i, comp := range diagram.Components {
+		if comp != want[i] {
+			t.Errorf("component %d = %+v\nwant %+v", i, comp, want[i])
+		}
+	}
+
+	if len(diagram.Connections) != 1 {
+		t.Fatalf("got connections %+v, want one", diagram.Connections)
+	}
+	if conn := diagram.Connections[0]; conn.Source != "Orders" || conn.Target != "Stripe" || conn.Port != "443" {
+		t.Errorf("connection = %+v, want Orders->Stripe on port 443", conn)
+	}
+}
+
+func TestParseModelFileSchemaErrors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.json")
+	writeTree(t, dir, map[string]string{
+		"model.json": `{"components": [{"name": "Queue", "type": "kafka"}, {"name": "Cache", "type": "cache"}]}`,
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(path)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 0 {
+		t.Errorf("an invalid model file contributed components %+v", diagram.Components)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("got diagnostics %v, want one", diags)
+	}
+	if diags[0].File != path || diags[0].Severity != archparser.SeverityError ||
+		!strings.Contains(diags[0].Message, "/components/0/type") {
+		t.Errorf("diagnostic = %+v, want an error about /components/0/type in %s", diags[0], path)
+	}
+}
+
+func TestParseModelFileSyntaxError(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"broken.diagram.yaml": "components: [\n",
+	})
+
+	p := archparser.New()
+	if _, err := p.Parse(dir); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	diags := p.Diagnostics()
+	if len(diags) != 1 || diags[0].Severity != archparser.SeverityError || !strings.Contains(diags[0].Message, "invalid YAML") {
+		t.Errorf("diagnostics = %v, want one invalid YAML error", diags)
+	}
+}
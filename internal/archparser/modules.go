@@ -0,0 +1,160 @@
+package archparser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"diagram-gen/internal/model"
+)
+
+// ModuleGrouping controls how components are grouped by the Go module
+// declaring them.
+type ModuleGrouping string
+
+const (
+	// ModuleGroupingNone keeps the swimlanes and pages of the annotations.
+	ModuleGroupingNone ModuleGrouping = "none"
+	// ModuleGroupingSwimlane puts the components of each module in a swimlane.
+	ModuleGroupingSwimlane ModuleGrouping = "swimlane"
+	// ModuleGroupingPage puts the components of each module on a page.
+	ModuleGroupingPage ModuleGrouping = "page"
+)
+
+// ValidateModuleGrouping reports an error for an unknown module grouping.
+func ValidateModuleGrouping(g ModuleGrouping) error {
+	switch g {
+	case "", ModuleGroupingNone, ModuleGroupingSwimlane, ModuleGroupingPage:
+		return nil
+	}
+	return fmt.Errorf("unknown module grouping: %s (valid groupings: none, swimlane, page)", g)
+}
+
+// inputPattern is a pattern to resolve. A module pattern walks its module
+// only, leaving out the nested modules found below it.
+type inputPattern struct {
+	pattern string
+	module  bool
+}
+
+// workspacePatterns expands a pattern naming a go.work or go.mod file into
+// one recursive module pattern per module. Other patterns are returned
+// unchanged.
+func workspacePatterns(pattern string) ([]inputPattern, error) {
+	switch filepath.Base(pattern) {
+	case "go.work":
+		work, err := ReadGoWorkspace(pattern)
+		if err != nil {
+			return nil, err
+		}
+		patterns := make([]inputPattern, 0, len(work.Modules))
+		for _, mod := range work.Modules {
+			patterns = append(patterns, inputPattern{filepath.Join(mod.Dir, recursiveSuffix), true})
+		}
+		return patterns, nil
+	case "go.mod":
+		return []inputPattern{{filepath.Join(filepath.Dir(pattern), recursiveSuffix), true}}, nil
+	}
+	return []inputPattern{{pattern: pattern}}, nil
+}
+
+// moduleIndex finds the module of a directory through the nearest go.mod,
+// remembering the answer for every directory it visits.
+type moduleIndex map[string]*GoModule
+
+// moduleOf returns the module containing dir, or nil outside any module.
+func (idx moduleIndex) moduleOf(dir string) *GoModule {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	var visited []string
+	var mod *GoModule
+	for {
+		if cached, ok := idx[abs]; ok {
+			mod = cached
+			break
+		}
+		visited = append(visited, abs)
+		if _, err := os.Stat(filepath.Join(abs, "go.mod")); err == nil {
+			// An unreadable go.mod leaves its files without a module.
+			mod, _ = ReadGoModule(filepath.Join(abs, "go.mod"))
+			break
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			break
+		}
+		abs = parent
+	}
+
+	for _, d := range visited {
+		idx[d] = mod
+	}
+	return mod
+}
+
+// groupByModule moves every component with a module into a swimlane or page
+// named by its module path, replacing the one of its annotation. On pages,
+// connections follow the module of their source, and those reaching another
+// module end at a stub of their target drawn on the source's page.
+func groupByModule(diagram *model.Diagram, grouping ModuleGrouping) {
+	modules := make(map[string]string)
+	for i := range diagram.Components {
+		comp := &diagram.Components[i]
+		if comp.Module == "" {
+			continue
+		}
+		modules[comp.Name] = comp.Module
+		switch grouping {
+		case ModuleGroupingSwimlane:
+			comp.Swimlane = comp.Module
+		case ModuleGroupingPage:
+			comp.Page = comp.Module
+		}
+	}
+
+	if grouping != ModuleGroupingPage {
+		return
+	}
+	pages := make(map[string]string, len(diagram.Components))
+	for _, comp := range diagram.Components {
+		pages[comp.Name] = comp.Page
+	}
+	for i := range diagram.Connections {
+		conn := &diagram.Connections[i]
+		module, ok := modules[conn.Source]
+		if !ok {
+			continue
+		}
+		conn.Page = module
+		if page, ok := pages[conn.Target]; ok && page != module {
+			conn.Target = addModuleStub(diagram, pages, conn.Target, module)
+		}
+	}
+}
+
+// addModuleStub adds to a page a dashed copy of a component declared on
+// another page, unless the page already has one, and returns its name.
+func addModuleStub(diagram *model.Diagram, pages map[string]string, name, page string) string {
+	target := diagram.GetComponentByName(name)
+	stub := fmt.Sprintf("%s (%s)", name, target.Page)
+	if _, exists := pages[stub]; exists {
+		return stub
+	}
+	label := target.Label
+	if label == "" {
+		label = name
+	}
+	diagram.AddComponent(model.Component{
+		Type:        target.Type,
+		Name:        stub,
+		Label:       label,
+		Description: fmt.Sprintf("Declared in %s", target.Page),
+		Page:        page,
+		Style:       "dashed=1",
+	})
+	pages[stub] = page
+	return stub
+}
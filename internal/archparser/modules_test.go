@@ -0,0 +1,191 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/generator"
+)
+
+// workspaceTree is a workspace whose billing module depends on the orders
+// module, which lives outside the workspace directory. The tools module
+// nested in orders is not used by the workspace.
+var workspaceTree = map[string]string{
+	"ws/go.work":        "go 1.22\n\nuse (\n\t./billing // the billing module\n\t\"../orders\"\n)\n",
+	"ws/billing/go.mod": "module example.com/billing\n\nrequire example.com/orders v0.0.0\n",
+	"ws/billing/invoices/invoices.go": "package invoices\n\n" +
+		"import \"example.com/orders\"\n\n" +
+		"//diagram:component connectsTo=Ledger\n" +
+		"type Invoices struct {\n" +
+		"\tsource *orders.Orders\n" +
+		"}\n\n" +
+		"//diagram:component type=database,swimlane=Data\n" +
+		"type Ledger struct{}\n",
+	"orders/go.mod": "module example.com/orders\n",
+	"orders/orders.go": "package orders\n\n" +
+		"//diagram:component connectsTo=Invoices\n" +
+		"type Orders struct{}\n",
+	"orders/tools/go.mod": "module example.com/orders/tools\n",
+	"orders/tools/gen.go": "package tools\n\n" +
+		"//diagram:component\n" +
+		"type Generator struct{}\n",
+}
+
+func TestParseWorkspace(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, workspaceTree)
+
+	p := archparser.New()
+	p.InferConnections = true
+	diagram, err := p.Parse(filepath.Join(root, "ws", "go.work"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %v", diags)
+	}
+
+	wantModules := map[string]string{
+		"Invoices": "example.com/billing",
+		"Ledger":   "example.com/billing",
+		"Orders":   "example.com/orders",
+	}
+	if len(diagram.Components) != len(wantModules) {
+		t.Fatalf("expected %d components, got %+v", len(wantModules), diagram.Components)
+	}
+	for _, comp := range diagram.Components {
+		if comp.Module != wantModules[comp.Name] {
+			t.Errorf("%s has module %q, want %q", comp.Name, comp.Module, wantModules[comp.Name])
+		}
+	}
+
+	edges := make(map[[2]string]bool)
+	for _, conn := range diagram.Connections {
+		edges[[2]string{conn.Source, conn.Target}] = conn.Inferred
+	}
+	for edge, inferred := range map[[2]string]bool{
+		{"Invoices", "Ledger"}: false,
+		{"Orders", "Invoices"}: false,
+		{"Invoices", "Orders"}: true,
+	} {
+		got, ok := edges[edge]
+		if !ok || got != inferred {
+			t.Errorf("expected connection %s -> %s (inferred %v), got %v", edge[0], edge[1], inferred, diagram.Connections)
+		}
+	}
+}
+
+func TestParseModuleGroups(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, workspaceTree)
+
+	tests := []struct {
+		grouping     archparser.ModuleGrouping
+		wantSwimlane string
+		wantPage     string
+		wantConnPage string
+	}{
+		{archparser.ModuleGroupingNone, "Data", "", ""},
+		{archparser.ModuleGroupingSwimlane, "example.com/billing", "", ""},
+		{archparser.ModuleGroupingPage, "Data", "example.com/billing", "example.com/orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.grouping), func(t *testing.T) {
+			t.Parallel()
+			p := archparser.New()
+			p.ModuleGroups = tt.grouping
+			diagram, err := p.Parse(filepath.Join(root, "ws", "go.work"))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			ledger := diagram.GetComponentByName("Ledger")
+			if ledger.Swimlane != tt.wantSwimlane || ledger.Page != tt.wantPage {
+				t.Errorf("Ledger in swimlane %q page %q, want %q %q", ledger.Swimlane, ledger.Page, tt.wantSwimlane, tt.wantPage)
+			}
+			for _, conn := range diagram.Connections {
+				if conn.Source == "Orders" && conn.Page != tt.wantConnPage {
+					t.Errorf("Orders -> %s on page %q, want %q", conn.Target, conn.Page, tt.wantConnPage)
+				}
+			}
+		})
+	}
+
+	// On module pages, edges to another module end at a stub of their
+	// target, so that no generator drops them.
+	p := archparser.New()
+	p.ModuleGroups = archparser.ModuleGroupingPage
+	p.InferConnections = true
+	diagram, err := p.Parse(filepath.Join(root, "ws", "go.work"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	stub := diagram.GetComponentByName("Invoices (example.com/billing)")
+	if stub == nil || stub.Page != "example.com/orders" || stub.Label != "Invoices" {
+		t.Fatalf("stub = %+v, want Invoices on the orders page", stub)
+	}
+	pages := make(map[string]string)
+	for _, comp := range diagram.Components {
+		pages[comp.Name] = comp.Page
+	}
+	for _, conn := range diagram.Connections {
+		if pages[conn.Source] != conn.Page || pages[conn.Target] != conn.Page {
+			t.Errorf("%s -> %s on page %q joins pages %q and %q", conn.Source, conn.Target, conn.Page, pages[conn.Source], pages[conn.Target])
+		}
+	}
+	data, err := generator.NewDOTGenerator().Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"Orders" -> "Invoices (example.com/billing)";`) {
+		t.Errorf("expected the Orders edge in the DOT output:\n%s", data)
+	}
+
+	p = archparser.New()
+	p.ModuleGroups = "package"
+	if _, err := p.Parse(filepath.Join(root, "orders")); err == nil {
+		t.Error("expected an error for an unknown module grouping")
+	}
+}
+
+func TestParseGoModPattern(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, workspaceTree)
+
+	p := archparser.New()
+	diagram, err := p.Parse(filepath.Join(root, "ws", "billing", "go.mod"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 2 || diagram.Components[0].Module != "example.com/billing" {
+		t.Errorf("expected the billing components, got %+v", diagram.Components)
+	}
+}
+
+func TestReadGoWorkspace(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, workspaceTree)
+
+	work, err := archparser.ReadGoWorkspace(filepath.Join(root, "ws", "go.work"))
+	if err != nil {
+		t.Fatalf("ReadGoWorkspace failed: %v", err)
+	}
+	var paths []string
+	for _, mod := range work.Modules {
+		paths = append(paths, mod.Path)
+	}
+	if len(paths) != 2 || paths[0] != "example.com/billing" || paths[1] != "example.com/orders" {
+		t.Errorf("modules = %v", paths)
+	}
+	if work.Modules[1].Dir != filepath.Join(root, "orders") {
+		t.Errorf("orders module dir = %s", work.Modules[1].Dir)
+	}
+
+	writeTree(t, root, map[string]string{"bad/go.work": "use ./missing\n"})
+	if _, err := archparser.ReadGoWorkspace(filepath.Join(root, "bad", "go.work")); err == nil {
+		t.Error("expected error for a use directive without go.mod")
+	}
+}
@@ -0,0 +1,91 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseOpenAPIDocument(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component\n" +
+			"type OrderService struct{}\n\n" +
+			"//diagram:component type=gateway\n" +
+			"type edge struct{}\n",
+		"api/orders.yaml": "openapi: 3.0.3\n" +
+			"info:\n  title: Orders API\n  description: Public order endpoints\n  version: '1'\n" +
+			"servers:\n  - url: https://edge:8443\n  - url: https://api.example.com\n" +
+			"tags:\n  - name: orders\n" +
+			"paths:\n  /orders:\n    get:\n      tags: [orders]\n      x-backend: OrderService\n",
+	})
+	spec := filepath.Join(dir, "api", "orders.yaml")
+
+	for _, tags := range []bool{false, true} {
+		p := archparser.New()
+		p.OpenAPITags = tags
+		diagram, err := p.Parse(dir+"/...", spec)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if diags := p.Diagnostics(); len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics %v", diags)
+		}
+
+		api := diagram.GetComponentByName("Orders API")
+		if api == nil || api.Description != "Public order endpoints" {
+			t.Fatalf("API component = %+v, want the described Orders API", api)
+		}
+
+		source := "Orders API"
+		if tags {
+			source = "Orders API/orders"
+		}
+		var edges []string
+		for _, conn := range diagram.Connections {
+			edges = append(edges, conn.Source+"->"+conn.Target)
+		}
+		// The edge server is declared in Go; api.example.com is not, so its
+		// connection is dropped.
+		got := strings.Join(edges, " ")
+		for _, want := range []string{source + "->OrderService", "Orders API->edge"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("tags=%v: connections %s lack %s", tags, got, want)
+			}
+		}
+		if strings.Contains(got, "api.example.com") {
+			t.Errorf("tags=%v: connections %s include an undeclared server", tags, got)
+		}
+	}
+}
+
+func TestParseOpenAPICacheKeysTags(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"api.yaml": "openapi: 3.0.3\ninfo:\n  title: API\ntags:\n  - name: users\n",
+	})
+	cacheDir := filepath.Join(dir, "cache")
+
+	counts := make(map[bool]int)
+	for _, tags := range []bool{false, true, false} {
+		p := archparser.New()
+		p.CacheDir = cacheDir
+		p.OpenAPITags = tags
+		diagram, err := p.Parse(filepath.Join(dir, "api.yaml"))
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		counts[tags] = len(diagram.Components)
+	}
+	if counts[false] != 1 || counts[true] != 2 {
+		t.Errorf("component counts = %v, want 1 without tags and 2 with them", counts)
+	}
+	if entries := cacheEntries(t, cacheDir); len(entries) != 2 {
+		t.Errorf("got %d cache entries, want one per tags setting", len(entries))
+	}
+}
@@ -0,0 +1,103 @@
+package archparser_test
+
+import (
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+	"diagram-gen/internal/model"
+)
+
+func TestParseMergesPartialDeclarations(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders.go": "package shop\n\n" +
+			"//diagram:component type=service,name=OrderService,description=Takes orders\n" +
+			"type OrderService struct{}\n",
+		"wiring.go": "package shop\n\n" +
+			"//diagram:component extends=OrderService,connectsTo=OrderDB,fillColor=#dae8fc\n" +
+			"type orderWiring struct{}\n\n" +
+			"type Extra struct {\n" +
+			"\tField string `diagram:\"name=OrderService,partial=true,connectsTo=Queue,description=Other\"`\n" +
+			"}\n",
+		"stores.go": "package shop\n\n" +
+			"//diagram:component type=database\n" +
+			"type OrderDB struct{}\n\n" +
+			"//diagram:component type=queue\n" +
+			"type Queue struct{}\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(dir)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	orders := diagram.GetComponentByName("OrderService")
+	if len(diagram.Components) != 3 || orders == nil {
+		t.Fatalf("expected OrderService, OrderDB and Queue once each, got %+v", diagram.Components)
+	}
+	if orders.Type != model.ComponentTypeService || orders.Description != "Takes orders" ||
+		orders.Style != "fillColor=#dae8fc" || orders.Partial {
+		t.Errorf("unexpected merged component %+v", *orders)
+	}
+	if len(diagram.Connections) != 2 {
+		t.Errorf("expected the union of both connections, got %+v", diagram.Connections)
+	}
+
+	diags := p.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected one conflict, got %v", diags)
+	}
+	d := diags[0]
+	if !strings.HasSuffix(d.File, "wiring.go") || d.Line != 7 || d.Severity != archparser.SeverityWarning ||
+		!strings.Contains(d.Message, `conflicting description for "OrderService": keeping "Takes orders", ignoring "Other"`) {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+}
+
+func TestParseAnnotationPartialKeys(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		tag         string
+		wantName    string
+		wantType    model.ComponentType
+		wantPartial bool
+		wantWarning string
+	}{
+		{"extends", "extends=OrderService,connectsTo=DB", "OrderService", "", true, ""},
+		{"partial", "name=OrderService,partial=true", "OrderService", "", true, ""},
+		{"partial false", "name=OrderService,partial=false", "OrderService", model.ComponentTypeService, false, ""},
+		{"partial keeps its type", "name=OrderService,partial=true,type=api", "OrderService", model.ComponentTypeAPI, true, ""},
+		{"extends wins over name", "name=Other,extends=OrderService", "OrderService", "", true, `ignoring name "Other"`},
+		{"invalid partial", "name=OrderService,partial=maybe", "OrderService", model.ComponentTypeService, false, `invalid partial "maybe"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ann, all := parseWithWarnings(t, tt.tag)
+			// Parsed alone, a partial is always reported as lacking its full declaration.
+			var warnings []string
+			for _, w := range all {
+				if !strings.Contains(w, "only has partial declarations") {
+					warnings = append(warnings, w)
+				}
+			}
+			if ann.Name != tt.wantName || ann.ComponentType != tt.wantType || ann.Partial != tt.wantPartial {
+				t.Errorf("got name %q type %q partial %v", ann.Name, ann.ComponentType, ann.Partial)
+			}
+			if tt.wantWarning == "" && len(warnings) > 0 {
+				t.Errorf("unexpected warnings %v", warnings)
+			}
+			if tt.wantWarning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.wantWarning)) {
+				t.Errorf("warnings = %v, want %q", warnings, tt.wantWarning)
+			}
+			if comp := ann.ToComponent(); comp.Partial != tt.wantPartial {
+				t.Errorf("ToComponent().Partial = %v", comp.Partial)
+			}
+		})
+	}
+}
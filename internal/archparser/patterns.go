@@ -0,0 +1,232 @@
+package archparser
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// recursiveSuffix marks a Go-style pattern that matches a directory and all of its subdirectories.
+const recursiveSuffix = "..."
+
+var skippedDirs = map[string]bool{
+	"vendor":   true,
+	"testdata": true,
+}
+
+// ResolvePatterns expands Go-style input patterns into the list of Go files to parse.
+//
+// A pattern may name a file, a directory (only the files directly inside it),
+// or a directory followed by "/..." (the directory and every subdirectory).
+// Walking skips vendor, testdata, hidden and underscore-prefixed directories,
+// and _test.go files are ignored unless a file is named explicitly. Model
+// files are found in directories when named like saas.diagram.yaml, and
+// Kubernetes manifests when Kubernetes is set. The Parser's Include and
+// Exclude globs are applied to paths relative to the pattern root. Files
+// found in directories must also match their build constraints when a
+// platform is selected, and SkipGenerated leaves out the generated ones.
+//
+// A go.work file stands for every module it uses and a go.mod file for its
+// module, each walked recursively up to the directories of nested modules.
+func (p *Parser) ResolvePatterns(patterns ...string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	var expanded []inputPattern
+	for _, pattern := range patterns {
+		modules, err := workspacePatterns(pattern)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, modules...)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	add := func(file string) {
+		if seen[file] {
+			return
+		}
+		seen[file] = true
+		files = append(files, file)
+	}
+
+	for _, in := range expanded {
+		pattern := in.pattern
+		root, recursive := splitPattern(pattern)
+
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access input path: %w", err)
+		}
+
+		if !info.IsDir() {
+			if recursive {
+				return nil, fmt.Errorf("pattern %s: %s is not a directory", pattern, root)
+			}
+			add(root)
+			continue
+		}
+
+		var matched []string
+		if recursive {
+			matched, err = p.walkGoFiles(root, in.module)
+		} else {
+			matched, err = p.listGoFiles(root)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range matched {
+			add(file)
+		}
+	}
+
+	return files, nil
+}
+
+func splitPattern(pattern string) (string, bool) {
+	if pattern != recursiveSuffix && !strings.HasSuffix(pattern, "/"+recursiveSuffix) {
+		return pattern, false
+	}
+
+	root := strings.TrimSuffix(strings.TrimSuffix(pattern, recursiveSuffix), "/")
+	if root == "" {
+		root = "."
+	}
+	return root, true
+}
+
+func (p *Parser) listGoFiles(dirPath string) ([]string, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	ctxt := p.buildContext()
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !p.isInputFile(entry.Name()) {
+			continue
+		}
+		if !p.matchFilters(entry.Name()) {
+			continue
+		}
+		file := filepath.Join(dirPath, entry.Name())
+		keep, err := p.keepFile(ctxt, file)
+		if err != nil {
+			return nil, err
+		}
+		if keep {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// walkGoFiles finds the input files below root. With module set, the
+// directories holding a go.mod of their own are skipped.
+func (p *Parser) walkGoFiles(root string, module bool) ([]string, error) {
+	ctxt := p.buildContext()
+	var files []string
+
+	err := filepath.WalkDir(root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, relErr := filepath.Rel(root, filePath)
+		if relErr != nil {
+			return fmt.Errorf("failed to resolve %s: %w", filePath, relErr)
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if filePath == root {
+				return nil
+			}
+			if isSkippedDir(d.Name()) || p.isExcluded(rel) {
+				return filepath.SkipDir
+			}
+			if module {
+				if _, err := os.Stat(filepath.Join(filePath, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+
+		if !p.isInputFile(d.Name()) || !p.matchFilters(rel) {
+			return nil
+		}
+		keep, err := p.keepFile(ctxt, filePath)
+		if keep {
+			files = append(files, filePath)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	return files, nil
+}
+
+func isGoSource(name string) bool {
+	return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+}
+
+func isSkippedDir(name string) bool {
+	return skippedDirs[name] || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func (p *Parser) matchFilters(rel string) bool {
+	if p.isExcluded(rel) {
+		return false
+	}
+	if len(p.Include) == 0 {
+		return true
+	}
+	for _, glob := range p.Include {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) isExcluded(rel string) bool {
+	for _, glob := range p.Exclude {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether a slash-separated relative path matches a glob.
+// The glob is tried against the full path, the base name and every leading
+// directory, so "legacy" or "internal/*" also exclude whole subtrees.
+func matchGlob(glob, rel string) bool {
+	glob = filepath.ToSlash(glob)
+	if ok, _ := path.Match(glob, rel); ok {
+		return true
+	}
+	if ok, _ := path.Match(glob, path.Base(rel)); ok {
+		return true
+	}
+
+	dir := path.Dir(rel)
+	for dir != "." && dir != "/" {
+		if ok, _ := path.Match(glob, dir); ok {
+			return true
+		}
+		dir = path.Dir(dir)
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package archparser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+}
+
+// tempTree writes files into a new temporary directory and returns it.
+func tempTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	writeTree(t, root, files)
+	return root
+}
+
+func annotatedSource(pkg, name string) string {
+	return "package " + pkg + "\n\ntype " + name + " struct {\n\tField string `diagram:\"type=service,name=" + name + "\"`\n}\n"
+}
+
+// patternTree holds packages at several depths and the directories that
+// walking skips.
+var patternTree = map[string]string{
+	"main.go":                          annotatedSource("main", "Root"),
+	"main_test.go":                     annotatedSource("main", "RootTest"),
+	"internal/orders/orders.go":        annotatedSource("orders", "Orders"),
+	"internal/orders/deep/deep.go":     annotatedSource("deep", "Deep"),
+	"internal/legacy/legacy.go":        annotatedSource("legacy", "Legacy"),
+	"internal/orders/testdata/fake.go": annotatedSource("fake", "Fake"),
+	"vendor/lib/lib.go":                annotatedSource("lib", "Vendored"),
+	".hidden/hidden.go":                annotatedSource("hidden", "Hidden"),
+}
+
+func componentNames(t *testing.T, p *archparser.Parser, patterns ...string) map[string]bool {
+	t.Helper()
+	diagram, err := p.Parse(patterns...)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, comp := range diagram.Components {
+		names[comp.Name] = true
+	}
+	return names
+}
+
+func TestParseRecursivePattern(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	names := componentNames(t, archparser.New(), root+"/...")
+
+	for _, want := range []string{"Root", "Orders", "Deep", "Legacy"} {
+		if !names[want] {
+			t.Errorf("expected component %s", want)
+		}
+	}
+	for _, skipped := range []string{"RootTest", "Fake", "Vendored", "Hidden"} {
+		if names[skipped] {
+			t.Errorf("component %s should have been skipped", skipped)
+		}
+	}
+}
+
+func TestParseNonRecursiveDirectory(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	names := componentNames(t, archparser.New(), root)
+
+	if len(names) != 1 || !names["Root"] {
+		t.Errorf("expected only Root, got %v", names)
+	}
+}
+
+func TestParseMultiplePatterns(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	names := componentNames(t, archparser.New(),
+		filepath.Join(root, "internal", "orders"),
+		filepath.Join(root, "internal", "legacy"),
+		filepath.Join(root, "internal", "orders"),
+	)
+
+	if len(names) != 2 || !names["Orders"] || !names["Legacy"] {
+		t.Errorf("expected Orders and Legacy, got %v", names)
+	}
+}
+
+func TestParseIncludeExclude(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "exclude directory",
+			exclude: []string{"internal/legacy"},
+			want:    []string{"Root", "Orders", "Deep"},
+		},
+		{
+			name:    "exclude by base name",
+			exclude: []string{"deep"},
+			want:    []string{"Root", "Orders", "Legacy"},
+		},
+		{
+			name:    "include glob",
+			include: []string{"internal/orders/*"},
+			want:    []string{"Orders", "Deep"},
+		},
+		{
+			name:    "include file name",
+			include: []string{"legacy.go"},
+			want:    []string{"Legacy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := archparser.New()
+			p.Include = tt.include
+			p.Exclude = tt.exclude
+
+			names := componentNames(t, p, root+"/...")
+			if len(names) != len(tt.want) {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+			for _, want := range tt.want {
+				if !names[want] {
+					t.Errorf("expected component %s in %v", want, names)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvePatternsExplicitTestFile(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	files, err := archparser.New().ResolvePatterns(filepath.Join(root, "main_test.go"))
+	if err != nil {
+		t.Fatalf("ResolvePatterns failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected explicitly named test file, got %v", files)
+	}
+}
+
+func TestResolvePatternsErrors(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, patternTree)
+
+	if _, err := archparser.New().ResolvePatterns(filepath.Join(root, "missing") + "/..."); err == nil {
+		t.Error("expected error for missing pattern root")
+	}
+	if _, err := archparser.New().ResolvePatterns(filepath.Join(root, "main.go") + "/..."); err == nil {
+		t.Error("expected error for recursive pattern on a file")
+	}
+}
+
+func TestResolvePatternsDefaultsToCurrentDirectory(t *testing.T) {
+	t.Parallel()
+
+	files, err := archparser.New().ResolvePatterns()
+	if err != nil {
+		t.Fatalf("ResolvePatterns failed: %v", err)
+	}
+	for _, file := range files {
+		if filepath.Dir(file) != "." {
+			t.Errorf("expected files in current directory, got %s", file)
+		}
+	}
+}
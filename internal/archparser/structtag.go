@@ -0,0 +1,138 @@
+package archparser
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// tagKey is the struct tag key holding diagram annotations.
+const tagKey = "diagram"
+
+// ParseStructTag extracts a value for a key from a struct tag literal.
+// The key may appear anywhere in the tag, alongside keys such as json or yaml.
+// It returns an empty string when the key is absent or the literal is malformed.
+func ParseStructTag(tagValue, key string) string {
+	value, _, _ := lookupStructTag(tagValue, key)
+	return value
+}
+
+// lookupStructTag unquotes a struct tag literal as written in source and looks
+// up key with reflect.StructTag semantics. A non-nil error reports a malformed
+// tag; found may still be true if the key was readable before the problem.
+func lookupStructTag(literal, key string) (string, bool, error) {
+	tag, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", false, fmt.Errorf("malformed struct tag literal %s", literal)
+	}
+
+	value, found := reflect.StructTag(tag).Lookup(key)
+	if err := validateStructTag(tag); err != nil {
+		return value, found, fmt.Errorf("malformed struct tag: %w", err)
+	}
+	return value, found, nil
+}
+
+// validateStructTag checks that a tag follows the conventional
+// key:"value" key:"value" format understood by reflect.StructTag.
+func validateStructTag(tag string) error {
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 {
+			return errors.New("bad syntax for struct tag key")
+		}
+		name := tag[:i]
+		if i >= len(tag) || tag[i] != ':' {
+			return fmt.Errorf("missing colon after key %q", name)
+		}
+		if i+1 >= len(tag) || tag[i+1] != '"' {
+			return fmt.Errorf("value of key %q is not quoted", name)
+		}
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return fmt.Errorf("unterminated value for key %q", name)
+		}
+		if _, err := strconv.Unquote(tag[:i+1]); err != nil {
+			return fmt.Errorf("bad syntax for value of key %q", name)
+		}
+		tag = tag[i+1:]
+	}
+	return nil
+}
+
+// structTagOffset maps an offset in the value of key to an offset in the
+// struct tag literal as written in source, accounting for escapes. It falls
+// back to 0, the start of the literal, when the literal is not a raw string.
+func structTagOffset(literal, key string, valueOffset int) int {
+	if len(literal) < 2 || literal[0] != '`' {
+		return 0
+	}
+
+	tag := literal[1 : len(literal)-1]
+	base := 1
+	for tag != "" {
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag, base = tag[i:], base+i
+
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' {
+			i++
+		}
+		if i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			return 0
+		}
+		name := tag[:i]
+		tag, base = tag[i+1:], base+i+1
+
+		// tag now starts with the quoted value.
+		i, out := 1, 0
+		for i < len(tag) && tag[i] != '"' {
+			if name == key && out >= valueOffset {
+				return base + i
+			}
+			value, multibyte, tail, err := strconv.UnquoteChar(tag[i:], '"')
+			if err != nil {
+				return 0
+			}
+			i = len(tag) - len(tail)
+			if multibyte {
+				out += utf8.RuneLen(value)
+			} else {
+				out++
+			}
+		}
+		if name == key {
+			return base + i
+		}
+		if i >= len(tag) {
+			return 0
+		}
+		tag, base = tag[i+1:], base+i+1
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseStructTagMultiKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		tagValue string
+		want     string
+	}{
+		{
+			name:     "after json",
+			tagValue: "`json:\"x\" diagram:\"type=service,name=A\"`",
+			want:     "type=service,name=A",
+		},
+		{
+			name:     "between keys",
+			tagValue: "`yaml:\"x,omitempty\" diagram:\"name=B\" gorm:\"column:b\"`",
+			want:     "name=B",
+		},
+		{
+			name:     "interpreted string literal",
+			tagValue: `"json:\"x\" diagram:\"name=C\""`,
+			want:     "name=C",
+		},
+		{
+			name:     "escaped quotes in value",
+			tagValue: "`diagram:\"name=D,description=say \\\"hi\\\"\"`",
+			want:     `name=D,description=say "hi"`,
+		},
+		{
+			name:     "prefix of another key",
+			tagValue: "`diagrams:\"name=E\"`",
+			want:     "",
+		},
+		{name: "empty literal", tagValue: "", want: ""},
+		{name: "single backtick", tagValue: "`", want: ""},
+		{name: "empty tag", tagValue: "``", want: ""},
+		{name: "unterminated value", tagValue: "`diagram:\"name=F`", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := archparser.ParseStructTag(tt.tagValue, "diagram")
+			if got != tt.want {
+				t.Errorf("ParseStructTag(%q) = %q, want %q", tt.tagValue, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileMalformedTags(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"tags.go": "package tags\n\n" +
+		"type A struct {\n" +
+		"\tField string `json:\"a\" diagram:\"name=A\"`\n" +
+		"\tBroken string `diagram:name=B`\n" +
+		"\tTrailing string `diagram:\"name=C\" json:x`\n" +
+		"\tOther string `json:x`\n" +
+		"\tEmpty string `diagram:\"\"`\n" +
+		"}\n"})
+
+	p := archparser.New()
+	diagram, err := p.ParseFile(filepath.Join(dir, "tags.go"))
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+
+	if len(diagram.Components) != 2 || diagram.Components[0].Name != "A" || diagram.Components[1].Name != "C" {
+		t.Errorf("expected components A and C, got %+v", diagram.Components)
+	}
+
+	diags := p.Diagnostics()
+	want := []struct {
+		line     int
+		severity archparser.Severity
+	}{
+		{5, archparser.SeverityError},
+		{6, archparser.SeverityWarning},
+		{8, archparser.SeverityWarning},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i, w := range want {
+		if diags[i].Line != w.line || diags[i].Severity != w.severity {
+			t.Errorf("diagnostic %d = %s, want line %d %s", i, diags[i], w.line, w.severity)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package archparser_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+func TestParseTextDiagrams(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"orders/orders.go": "package orders\n\n" +
+			"//diagram:component connectsTo=Ledger\n" +
+			"type OrderService struct{}\n",
+		"docs/flow.mmd":     "graph TD\n  OrderService --> Ledger[(Ledger)]\n",
+		"docs/billing.puml": "@startuml\nqueue Invoices\nLedger ..> Invoices : exports\n@enduml\n",
+		"docs/design.md":    "```mermaid\ngraph TD\n  Auditor --> Ledger\n```\n",
+	})
+
+	// Walking the tree does not read them.
+	p := archparser.New()
+	diagram, err := p.Parse(dir + "/...")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(diagram.Components) != 1 {
+		t.Fatalf("components = %+v, want only OrderService", diagram.Components)
+	}
+
+	p = archparser.New()
+	diagram, err = p.Parse(dir+"/...",
+		filepath.Join(dir, "docs", "flow.mmd"),
+		filepath.Join(dir, "docs", "billing.puml"),
+		filepath.Join(dir, "docs", "design.md"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// References merge with declarations in code or other files; a name
+	// nothing else declares, such as Auditor, is declared by its link.
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("diagnostics = %v, want none", diags)
+	}
+	for _, name := range []string{"OrderService", "Ledger", "Invoices", "Auditor"} {
+		if diagram.GetComponentByName(name) == nil {
+			t.Errorf("missing component %s in %+v", name, diagram.Components)
+		}
+	}
+	if ledger := diagram.GetComponentByName("Ledger"); ledger != nil && ledger.Type != "database" {
+		t.Errorf("Ledger type = %s, want database", ledger.Type)
+	}
+	if invoices := diagram.GetComponentByName("Invoices"); invoices != nil && invoices.Type != "queue" {
+		t.Errorf("Invoices type = %s, want queue", invoices.Type)
+	}
+
+	// The annotation and flow.mmd both declare OrderService --> Ledger.
+	var edges []string
+	for _, conn := range diagram.Connections {
+		edges = append(edges, conn.Source+"->"+conn.Target)
+	}
+	want := "OrderService->Ledger Ledger->Invoices Auditor->Ledger"
+	if got := strings.Join(edges, " "); got != want {
+		t.Errorf("connections = %s, want %s", got, want)
+	}
+}
+
+func TestParseLinkOnlyFlowchart(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"flow.mmd":  "graph TD; A-->B; B-->C\n",
+		"other.mmd": "graph TD; C-->D\n",
+	})
+
+	p := archparser.New()
+	diagram, err := p.Parse(filepath.Join(dir, "flow.mmd"), filepath.Join(dir, "other.mmd"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if diags := p.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("diagnostics = %v, want none", diags)
+	}
+	if len(diagram.Components) != 4 || len(diagram.Connections) != 3 {
+		t.Errorf("diagram = %+v, want 4 components and 3 connections", diagram)
+	}
+	for _, comp := range diagram.Components {
+		if comp.Partial || comp.Type != "service" {
+			t.Errorf("component %+v, want a full service", comp)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package archparser
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// workerCount returns how many files to parse at once for n files.
+func (p *Parser) workerCount(n int) int {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return max(1, min(workers, n))
+}
+
+// parseAll parses every file on a bounded pool of workers, records the
+// diagnostics, and returns the results of the files that could be parsed.
+// Results and diagnostics are collected in input order, so the output is the
+// same as a serial parse whatever the scheduling. When ctx is canceled no
+// new files are started and its error is returned.
+func (p *Parser) parseAll(ctx context.Context, files []string) ([]*fileResult, error) {
+	type outcome struct {
+		result *fileResult
+		err    error
+	}
+	outcomes := make([]outcome, len(files))
+
+	err := p.runPool(ctx, len(files), func(i int) {
+		result, err := p.parseFile(files[i])
+		outcomes[i] = outcome{result: result, err: err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*fileResult, 0, len(files))
+	for i, o := range outcomes {
+		if o.err != nil {
+			p.diagnostics = append(p.diagnostics, fileErrorDiagnostics(files[i], o.err)...)
+			continue
+		}
+
+		p.diagnostics = append(p.diagnostics, o.result.Diagnostics...)
+		results = append(results, o.result)
+	}
+	return results, nil
+}
+
+// runPool calls work with every index below n on a bounded pool of
+// workers. When ctx is canceled no new indexes are started and its error
+// is returned once the started ones finish.
+func (p *Parser) runPool(ctx context.Context, n int, work func(i int)) error {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range p.workerCount(n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				work(i)
+			}
+		}()
+	}
+
+feed:
+	for i := range n {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("parse canceled: %w", err)
+	}
+	return nil
+}
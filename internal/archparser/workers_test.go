@@ -0,0 +1,152 @@
+package archparser_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"diagram-gen/internal/archparser"
+)
+
+// writeCorpus generates n annotated files spread over packages of 100 files.
+// Every file declares a component that connects to the next one, and every
+// tenth file also has a rejected annotation so diagnostics are exercised.
+func writeCorpus(tb testing.TB, root string, n int) {
+	tb.Helper()
+	for i := range n {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%03d", i/100))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatalf("mkdir: %v", err)
+		}
+
+		src := fmt.Sprintf("package pkg%03d\n\n"+
+			"// Service%[2]d is generated.\n"+
+			"type Service%[2]d struct {\n"+
+			"\tField string `diagram:\"type=service,name=Service%[2]d,connectsTo=Service%[3]d,swimlane=Lane%[4]d\"`\n"+
+			"\tNext  *Service%[3]d\n"+
+			"}\n\n"+
+			"//diagram:component type=database,description='store %[2]d, generated'\n"+
+			"type Store%[2]d struct{}\n",
+			i/100, i, i+1, i%7)
+		if i%10 == 0 {
+			src += "\ntype Broken" + fmt.Sprint(i) + " struct {\n\tField string `diagram:\"type=service\"`\n}\n"
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%05d.go", i)), []byte(src), 0o644); err != nil {
+			tb.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestParseWorkersDeterministic(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeCorpus(t, root, 300)
+
+	parse := func(workers int) (any, archparser.Diagnostics) {
+		p := archparser.New()
+		p.Workers = workers
+		p.InferConnections = true
+		diagram, err := p.Parse(root + "/...")
+		if err != nil {
+			t.Fatalf("Parse with %d workers failed: %v", workers, err)
+		}
+		return diagram, p.Diagnostics()
+	}
+
+	serial, serialDiags := parse(1)
+	if len(serialDiags) != 30 {
+		t.Fatalf("expected 30 diagnostics, got %d", len(serialDiags))
+	}
+	for _, workers := range []int{2, 8, 64} {
+		diagram, diags := parse(workers)
+		if !reflect.DeepEqual(diagram, serial) {
+			t.Errorf("diagram with %d workers differs from the serial result", workers)
+		}
+		if !reflect.DeepEqual(diags, serialDiags) {
+			t.Errorf("diagnostics with %d workers differ from the serial result", workers)
+		}
+	}
+}
+
+func TestParseImportGraphWorkersDeterministic(t *testing.T) {
+	t.Parallel()
+	root := tempTree(t, moduleTree)
+
+	parse := func(workers int) any {
+		p := archparser.New()
+		p.Workers = workers
+		diagram, err := p.ParseImportGraph(root + "/...")
+		if err != nil {
+			t.Fatalf("ParseImportGraph with %d workers failed: %v", workers, err)
+		}
+		return diagram
+	}
+
+	serial := parse(1)
+	for _, workers := range []int{2, 8} {
+		if diagram := parse(workers); !reflect.DeepEqual(diagram, serial) {
+			t.Errorf("import graph with %d workers differs from the serial result", workers)
+		}
+	}
+}
+
+func TestParseContextCanceled(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	writeCorpus(t, root, 20)
+	writeTree(t, root, map[string]string{"go.mod": "module example.com/corpus\n"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := archparser.New()
+	_, err := p.ParseContext(ctx, root+"/...")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(p.Diagnostics()) != 0 {
+		t.Errorf("a canceled parse should not record diagnostics, got %d", len(p.Diagnostics()))
+	}
+
+	_, err = p.ParseFlowchartsContext(ctx, root+"/...")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from flowcharts, got %v", err)
+	}
+
+	_, err = p.ParseImportGraphContext(ctx, root+"/...")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from the import graph, got %v", err)
+	}
+}
+
+// BenchmarkParseCorpus compares a serial parse of 10,000 generated files with
+// the worker pool, e.g. go test -bench ParseCorpus -run ^$ ./internal/archparser
+func BenchmarkParseCorpus(b *testing.B) {
+	root := b.TempDir()
+	writeCorpus(b, root, 10000)
+
+	counts := []int{1}
+	for _, workers := range []int{4, runtime.GOMAXPROCS(0)} {
+		if workers > counts[len(counts)-1] {
+			counts = append(counts, workers)
+		}
+	}
+
+	for _, workers := range counts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				p := archparser.New()
+				p.Workers = workers
+				if _, err := p.Parse(root + "/..."); err != nil {
+					b.Fatalf("Parse failed: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package compose imports the services of docker-compose files as diagram
+// components.
+package compose
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"diagram-gen/internal/model"
+)
+
+// IsComposeFile reports whether a path names a compose file: compose.yaml,
+// docker-compose.yml and override files such as docker-compose.prod.yml.
+func IsComposeFile(path string) bool {
+	name := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(name)
+	if ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for _, prefix := range []string{"docker-compose", "compose"} {
+		if stem == prefix || strings.HasPrefix(stem, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// imageTypes maps words found in image names to component types. The first
+// match wins.
+var imageTypes = []struct {
+	word string
+	typ  model.ComponentType
+}{
+	{"postgres", model.ComponentTypeDatabase},
+	{"mysql", model.ComponentTypeDatabase},
+	{"mariadb", model.ComponentTypeDatabase},
+	{"mongo", model.ComponentTypeDatabase},
+	{"cassandra", model.ComponentTypeDatabase},
+	{"cockroach", model.ComponentTypeDatabase},
+	{"mssql", model.ComponentTypeDatabase},
+	{"redis", model.ComponentTypeCache},
+	{"valkey", model.ComponentTypeCache},
+	{"memcached", model.ComponentTypeCache},
+	{"rabbitmq", model.ComponentTypeQueue},
+	{"kafka", model.ComponentTypeQueue},
+	{"redpanda", model.ComponentTypeQueue},
+	{"nats", model.ComponentTypeQueue},
+	{"activemq", model.ComponentTypeQueue},
+	{"minio", model.ComponentTypeStorage},
+	{"nginx", model.ComponentTypeGateway},
+	{"traefik", model.ComponentTypeGateway},
+	{"haproxy", model.ComponentTypeGateway},
+	{"envoy", model.ComponentTypeGateway},
+}
+
+// GuessType returns the component type of a service running image, based on
+// the repository name without registry, namespace or tag. Images it does not
+// recognize, and services built from source, are services.
+func GuessType(image string) model.ComponentType {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	name, _, _ = strings.Cut(name, "@")
+	name, _, _ = strings.Cut(name, ":")
+	name = strings.ToLower(name)
+
+	for _, it := range imageTypes {
+		if strings.Contains(name, it.word) {
+			return it.typ
+		}
+	}
+	return model.ComponentTypeService
+}
+
+// file is the part of a compose file the importer reads.
+type file struct {
+	Services services `yaml:"services"`
+}
+
+// service is one entry of the services mapping.
+type service struct {
+	Name      string
+	Image     string   `yaml:"image"`
+	DependsOn keyList  `yaml:"depends_on"`
+	Links     []string `yaml:"links"`
+	Networks  keyList  `yaml:"networks"`
+}
+
+// services keeps the services in the order the file lists them.
+type services []service
+
+func (s *services) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: services must be a mapping", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var svc service
+		if err := node.Content[i+1].Decode(&svc); err != nil {
+			return err
+		}
+		svc.Name = node.Content[i].Value
+		*s = append(*s, svc)
+	}
+	return nil
+}
+
+// keyList reads the fields compose accepts either as a list of names or as
+// a mapping keyed by name, such as depends_on and networks.
+type keyList []string
+
+func (k *keyList) UnmarshalYAML(node *yaml.Node) error {
+	switch {
+	case node.Tag == "!!null":
+		return nil
+	case node.Kind == yaml.SequenceNode:
+		var names []string
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+		*k = names
+	case node.Kind == yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			*k = append(*k, node.Content[i].Value)
+		}
+	default:
+		return fmt.Errorf("line %d: expected a list or a mapping", node.Line)
+	}
+	return nil
+}
+
+// Load imports the services of a compose file. Each service becomes a
+// component whose type is guessed from its image, depends_on and links
+// become connections, and the first network a service joins becomes its
+// swimlane.
+func Load(path string, data []byte) (*model.Diagram, error) {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid compose file %s: %w", path, err)
+	}
+	if len(f.Services) == 0 {
+		return nil, fmt.Errorf("invalid compose file %s: no services", path)
+	}
+
+	diagram := &model.Diagram{}
+	for _, svc := range f.Services {
+		comp := model.Component{
+			Name:        svc.Name,
+			Type:        GuessType(svc.Image),
+			Description: svc.Image,
+		}
+		if len(svc.Networks) > 0 {
+			comp.Swimlane = svc.Networks[0]
+		}
+		diagram.AddComponent(comp)
+
+		seen := make(map[string]bool)
+		targets := append([]string(nil), svc.DependsOn...)
+		for _, link := range svc.Links {
+			target, _, _ := strings.Cut(link, ":")
+			targets = append(targets, target)
+		}
+		for _, target := range targets {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			// Unidirectional as with connectsTo, so that an annotation
+			// declaring the same dependency merges with it.
+			diagram.AddConnection(model.Connection{Source: svc.Name, Target: target,
+				Direction: model.ConnectionDirectionUnidirectional})
+		}
+	}
+	return diagram, nil
+}
@@ -0,0 +1,145 @@
+package compose_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/compose"
+	"diagram-gen/internal/model"
+)
+
+const shop = `services:
+  web:
+    image: nginx:1.27-alpine
+    depends_on:
+      - api
+    networks:
+      - frontend
+  api:
+    build: ./api
+    depends_on:
+      db:
+        condition: service_healthy
+      cache:
+        condition: service_started
+    links:
+      - broker:mq
+      - db
+    networks:
+      backend:
+      frontend:
+  db:
+    image: docker.io/library/postgres:16
+    networks: [backend]
+  cache:
+    image: bitnami/redis@sha256:0123
+  broker:
+    image: rabbitmq:3-management
+  worker:
+networks:
+  frontend:
+  backend:
+`
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	diagram, err := compose.Load("docker-compose.yml", []byte(shop))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	wantComponents := []model.Component{
+		{Name: "web", Type: model.ComponentTypeGateway, Description: "nginx:1.27-alpine", Swimlane: "frontend"},
+		{Name: "api", Type: model.ComponentTypeService, Swimlane: "backend"},
+		{Name: "db", Type: model.ComponentTypeDatabase, Description: "docker.io/library/postgres:16", Swimlane: "backend"},
+		{Name: "cache", Type: model.ComponentTypeCache, Description: "bitnami/redis@sha256:0123"},
+		{Name: "broker", Type: model.ComponentTypeQueue, Description: "rabbitmq:3-management"},
+		{Name: "worker", Type: model.ComponentTypeService},
+	}
+	if !reflect.DeepEqual(diagram.Components, wantComponents) {
+		t.Errorf("components = %+v\nwant %+v", diagram.Components, wantComponents)
+	}
+
+	wantConnections := []model.Connection{
+		{Source: "web", Target: "api", Direction: model.ConnectionDirectionUnidirectional},
+		{Source: "api", Target: "db", Direction: model.ConnectionDirectionUnidirectional},
+		{Source: "api", Target: "cache", Direction: model.ConnectionDirectionUnidirectional},
+		{Source: "api", Target: "broker", Direction: model.ConnectionDirectionUnidirectional},
+	}
+	if !reflect.DeepEqual(diagram.Connections, wantConnections) {
+		t.Errorf("connections = %+v\nwant %+v", diagram.Connections, wantConnections)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "syntax", data: "services: [\n", want: "invalid compose file"},
+		{name: "no services", data: "volumes:\n  data:\n", want: "no services"},
+		{name: "services list", data: "services:\n  - web\n", want: "services must be a mapping"},
+		{name: "depends_on scalar", data: "services:\n  web:\n    depends_on: api\n", want: "expected a list or a mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := compose.Load("compose.yaml", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuessType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		image string
+		want  model.ComponentType
+	}{
+		{"postgres", model.ComponentTypeDatabase},
+		{"bitnami/postgresql:15", model.ComponentTypeDatabase},
+		{"mysql:8", model.ComponentTypeDatabase},
+		{"mongo:7", model.ComponentTypeDatabase},
+		{"redis:7-alpine", model.ComponentTypeCache},
+		{"memcached", model.ComponentTypeCache},
+		{"rabbitmq:3-management", model.ComponentTypeQueue},
+		{"confluentinc/cp-kafka:7.6.0", model.ComponentTypeQueue},
+		{"bitnami/kafka", model.ComponentTypeQueue},
+		{"registry.example.com:5000/team/redis:7", model.ComponentTypeCache},
+		{"ghcr.io/acme/orders:latest", model.ComponentTypeService},
+		{"", model.ComponentTypeService},
+	}
+	for _, tt := range tests {
+		if got := compose.GuessType(tt.image); got != tt.want {
+			t.Errorf("GuessType(%q) = %s, want %s", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestIsComposeFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"docker-compose.yml", true},
+		{"deploy/docker-compose.yaml", true},
+		{"compose.yaml", true},
+		{"docker-compose.prod.yml", true},
+		{"compose.override.yml", true},
+		{"Docker-Compose.YML", true},
+		{"docker-compose.json", false},
+		{"my-compose.yml", false},
+		{"composer.yaml", false},
+	}
+	for _, tt := range tests {
+		if got := compose.IsComposeFile(tt.path); got != tt.want {
+			t.Errorf("IsComposeFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,620 @@
+// Package dot reads Graphviz DOT graphs.
+package dot
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/validator"
+)
+
+// IsDOTFile reports whether a path names a Graphviz DOT file.
+func IsDOTFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		return true
+	}
+	return false
+}
+
+// Parse reads the graphs of a DOT file. Nodes become components named by
+// their ID and edges connections labelled by their label; clusters become
+// swimlanes. A type attribute, as written by the DOT generator, sets the
+// component type, and otherwise the node shape does: cylinders are
+// databases, diamonds decisions, parallelograms queues, hexagons gateways,
+// ellipses users, notes externals, folders storage and the rest services.
+// Colors, pen widths and dashes that differ from those of the type are kept
+// in style. A file holding several graphs has a page per graph.
+func Parse(path string, data []byte) (*model.Diagram, error) {
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DOT file %s: %w", path, err)
+	}
+	p := &parser{tokens: tokens}
+
+	var graphs []*graph
+	for p.peek().kind != tokenEOF {
+		g, err := p.graph()
+		if err != nil {
+			return nil, fmt.Errorf("invalid DOT file %s: %w", path, err)
+		}
+		graphs = append(graphs, g)
+	}
+	if len(graphs) == 0 {
+		return nil, fmt.Errorf("invalid DOT file %s: no graphs", path)
+	}
+
+	diagram := &model.Diagram{Type: model.DiagramTypeArchitecture}
+	used := make(map[string]bool)
+	for i, g := range graphs {
+		page := ""
+		if len(graphs) > 1 {
+			page = g.id
+			switch page {
+			case generator.DefaultPageName:
+				page = ""
+			case "":
+				page = fmt.Sprintf("Graph %d", i+1)
+			}
+		}
+		g.build(diagram, page, used)
+	}
+	return diagram, nil
+}
+
+// attrs holds attribute values; HTML strings keep their angle brackets.
+type attrs map[string]string
+
+func (a attrs) copy() attrs {
+	c := make(attrs, len(a))
+	for k, v := range a {
+		c[k] = v
+	}
+	return c
+}
+
+type node struct {
+	id    string
+	attrs attrs
+	lane  *cluster
+}
+
+type cluster struct {
+	id    string
+	attrs attrs
+}
+
+// name returns the label of a cluster, or its ID without the cluster prefix.
+func (c *cluster) name() string {
+	if label := labelText(c.attrs["label"], c.id); label != "" {
+		return label
+	}
+	if name := strings.TrimLeft(strings.TrimPrefix(c.id, "cluster"), "_- "); name != "" {
+		return name
+	}
+	return c.id
+}
+
+type edge struct {
+	source, target string
+	attrs          attrs
+}
+
+type graph struct {
+	id       string
+	directed bool
+	nodes    []*node
+	byID     map[string]*node
+	edges    []edge
+}
+
+// scope holds the defaults of a graph or subgraph and the nodes it mentions.
+type scope struct {
+	node, edge attrs
+	cluster    *cluster
+	members    []string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	g      *graph
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isSymbol(text string) bool {
+	t := p.peek()
+	return t.kind == tokenSymbol && t.text == text
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokenID && !t.html && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) expect(text string) error {
+	if !p.isSymbol(text) {
+		return p.unexpected(fmt.Sprintf("%q", text))
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) unexpected(want string) error {
+	t := p.peek()
+	found := fmt.Sprintf("%q", t.text)
+	if t.kind == tokenEOF {
+		found = "end of file"
+	}
+	return fmt.Errorf("line %d: expected %s, found %s", t.line, want, found)
+}
+
+// graph reads [strict] (graph | digraph) [ID] { stmt_list }.
+func (p *parser) graph() (*graph, error) {
+	if p.isKeyword("strict") {
+		p.next()
+	}
+	g := &graph{byID: make(map[string]*node)}
+	switch {
+	case p.isKeyword("digraph"):
+		g.directed = true
+	case p.isKeyword("graph"):
+	default:
+		return nil, p.unexpected("graph or digraph")
+	}
+	p.next()
+	if p.peek().kind == tokenID {
+		g.id = p.next().text
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	p.g = g
+	if err := p.stmts(&scope{node: attrs{}, edge: attrs{}}); err != nil {
+		return nil, err
+	}
+	return g, p.expect("}")
+}
+
+// stmts reads statements up to the closing brace of a graph or subgraph.
+func (p *parser) stmts(sc *scope) error {
+	for !p.isSymbol("}") {
+		if p.peek().kind == tokenEOF {
+			return p.unexpected(`"}"`)
+		}
+		if err := p.stmt(sc); err != nil {
+			return err
+		}
+		if p.isSymbol(";") {
+			p.next()
+		}
+	}
+	return nil
+}
+
+func (p *parser) stmt(sc *scope) error {
+	t, after := p.peek(), p.tokens[p.pos+1]
+	if t.kind == tokenID && !t.html && after.kind == tokenSymbol && after.text == "[" {
+		var target attrs
+		switch strings.ToLower(t.text) {
+		case "node":
+			target = sc.node
+		case "edge":
+			target = sc.edge
+		case "graph":
+			// Only the attributes of clusters, such as their label, are kept.
+			target = attrs{}
+			if sc.cluster != nil {
+				target = sc.cluster.attrs
+			}
+		}
+		if target != nil {
+			p.next()
+			a, err := p.attrList()
+			if err != nil {
+				return err
+			}
+			for k, v := range a {
+				target[k] = v
+			}
+			return nil
+		}
+	}
+	if t.kind == tokenID && after.kind == tokenSymbol && after.text == "=" {
+		p.next()
+		p.next()
+		if p.peek().kind != tokenID {
+			return p.unexpected("a value for " + t.text)
+		}
+		value := p.next()
+		if sc.cluster != nil {
+			sc.cluster.attrs[t.text] = attrValue(value)
+		}
+		return nil
+	}
+
+	first, err := p.operand(sc)
+	if err != nil {
+		return err
+	}
+	if !p.isSymbol("->") && !p.isSymbol("--") {
+		if !p.isSubgraphStart(t) {
+			a, err := p.attrList()
+			if err != nil {
+				return err
+			}
+			for k, v := range a {
+				p.g.byID[first[0]].attrs[k] = v
+			}
+		}
+		return nil
+	}
+
+	operands := [][]string{first}
+	for p.isSymbol("->") || p.isSymbol("--") {
+		p.next()
+		ids, err := p.operand(sc)
+		if err != nil {
+			return err
+		}
+		operands = append(operands, ids)
+	}
+	a, err := p.attrList()
+	if err != nil {
+		return err
+	}
+	edgeAttrs := sc.edge.copy()
+	for k, v := range a {
+		edgeAttrs[k] = v
+	}
+	for i := 1; i < len(operands); i++ {
+		for _, source := range operands[i-1] {
+			for _, target := range operands[i] {
+				p.g.edges = append(p.g.edges, edge{source, target, edgeAttrs})
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) isSubgraphStart(t token) bool {
+	return t.kind == tokenSymbol && t.text == "{" || t.kind == tokenID && !t.html && strings.EqualFold(t.text, "subgraph")
+}
+
+// operand reads a node ID with an optional port, or a subgraph, and returns
+// the nodes it stands for.
+func (p *parser) operand(sc *scope) ([]string, error) {
+	if p.isSubgraphStart(p.peek()) {
+		return p.subgraph(sc)
+	}
+	if p.peek().kind != tokenID {
+		return nil, p.unexpected("a node ID")
+	}
+	t := p.next()
+	// Ports, as in node:port:n, are not kept.
+	for p.isSymbol(":") {
+		p.next()
+		if p.peek().kind != tokenID {
+			return nil, p.unexpected("a port")
+		}
+		p.next()
+	}
+	p.mention(sc, t.text)
+	return []string{t.text}, nil
+}
+
+// subgraph reads [subgraph [ID]] { stmt_list } and returns its nodes.
+func (p *parser) subgraph(parent *scope) ([]string, error) {
+	id := ""
+	if p.isKeyword("subgraph") {
+		p.next()
+		if p.peek().kind == tokenID {
+			id = p.next().text
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	sc := &scope{node: parent.node.copy(), edge: parent.edge.copy(), cluster: parent.cluster}
+	if strings.HasPrefix(id, "cluster") {
+		sc.cluster = &cluster{id: id, attrs: attrs{}}
+	}
+	if err := p.stmts(sc); err != nil {
+		return nil, err
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	parent.members = append(parent.members, sc.members...)
+	return sc.members, nil
+}
+
+// attrList reads any number of [a=b, c=d] lists.
+func (p *parser) attrList() (attrs, error) {
+	a := attrs{}
+	for p.isSymbol("[") {
+		p.next()
+		for !p.isSymbol("]") {
+			if p.peek().kind != tokenID {
+				return nil, p.unexpected("an attribute")
+			}
+			key := p.next()
+			value := token{kind: tokenID, text: "true"}
+			if p.isSymbol("=") {
+				p.next()
+				if p.peek().kind != tokenID {
+					return nil, p.unexpected("an attribute value")
+				}
+				value = p.next()
+			}
+			a[key.text] = attrValue(value)
+			if p.isSymbol(",") || p.isSymbol(";") {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return a, nil
+}
+
+func attrValue(t token) string {
+	if t.html {
+		return "<" + t.text + ">"
+	}
+	return t.text
+}
+
+// mention records a node named in a scope, creating it with the node
+// defaults of the scope the first time.
+func (p *parser) mention(sc *scope, id string) {
+	n, ok := p.g.byID[id]
+	if !ok {
+		n = &node{id: id, attrs: sc.node.copy()}
+		p.g.nodes = append(p.g.nodes, n)
+		p.g.byID[id] = n
+	}
+	if n.lane == nil {
+		n.lane = sc.cluster
+	}
+	sc.members = append(sc.members, id)
+}
+
+// shapeTypes maps node shapes to component types.
+var shapeTypes = map[string]model.ComponentType{
+	"cylinder":      model.ComponentTypeDatabase,
+	"diamond":       model.ComponentTypeDecision,
+	"parallelogram": model.ComponentTypeQueue,
+	"cds":           model.ComponentTypeQueue,
+	"hexagon":       model.ComponentTypeGateway,
+	"ellipse":       model.ComponentTypeUser,
+	"oval":          model.ComponentTypeUser,
+	"circle":        model.ComponentTypeUser,
+	"doublecircle":  model.ComponentTypeTerminal,
+	"Mcircle":       model.ComponentTypeTerminal,
+	"Msquare":       model.ComponentTypeTerminal,
+	"Mdiamond":      model.ComponentTypeTerminal,
+	"note":          model.ComponentTypeExternal,
+	"folder":        model.ComponentTypeStorage,
+	"tab":           model.ComponentTypeStorage,
+}
+
+// arrows maps Graphviz arrow shapes to draw.io arrows; normal is the default.
+var arrows = map[string]string{
+	"none":     generator.ArrowNone,
+	"vee":      generator.ArrowOpen,
+	"open":     generator.ArrowOpen,
+	"diamond":  generator.ArrowDiamond,
+	"odiamond": generator.ArrowDiamond,
+	"dot":      "oval",
+	"odot":     "oval",
+	"box":      "box",
+	"obox":     "box",
+}
+
+// build adds the nodes and edges of a graph to a diagram, numbering the
+// nodes whose ID an earlier graph used, as in "api (2)".
+func (g *graph) build(diagram *model.Diagram, page string, used map[string]bool) {
+	names := make(map[string]string, len(g.nodes))
+	for _, n := range g.nodes {
+		comp := n.component()
+		comp.Page = page
+		if used[comp.Name] {
+			if comp.Label == "" {
+				comp.Label = comp.Name
+			}
+			name := comp.Name
+			for i := 2; used[comp.Name]; i++ {
+				comp.Name = fmt.Sprintf("%s (%d)", name, i)
+			}
+		}
+		used[comp.Name] = true
+		names[n.id] = comp.Name
+		diagram.AddComponent(comp)
+	}
+	for _, e := range g.edges {
+		conn := e.connection(g.directed)
+		conn.Source, conn.Target = names[conn.Source], names[conn.Target]
+		conn.Page = page
+		diagram.AddConnection(conn)
+	}
+}
+
+// component returns the component a node stands for. Nodes that only edges
+// name are services like any other.
+func (n *node) component() model.Component {
+	a := n.attrs
+	comp := model.Component{
+		Name:        n.id,
+		Label:       labelText(a["label"], n.id),
+		Description: labelText(a["tooltip"], n.id),
+		Type:        model.ComponentTypeService,
+	}
+	if comp.Label == n.id {
+		comp.Label = ""
+	}
+	if n.lane != nil {
+		comp.Swimlane = n.lane.name()
+	}
+
+	styles := strings.Split(a["style"], ",")
+	has := func(style string) bool {
+		for _, s := range styles {
+			if strings.TrimSpace(s) == style {
+				return true
+			}
+		}
+		return false
+	}
+	typ := model.ComponentType(a["type"])
+	switch {
+	case typ != model.ComponentTypeUnknown && validator.ValidateComponentType(typ):
+		comp.Type = typ
+	case shapeTypes[a["shape"]] != "":
+		comp.Type = shapeTypes[a["shape"]]
+	}
+	shape := modelShape(a["shape"], has("rounded"))
+	if shape != "" && string(shape) != string(generator.GetDefaultShapeForComponentType(string(comp.Type))) {
+		comp.Shape = shape
+	}
+
+	// Style keeps what differs from the type's own draw.io style.
+	defaults := generator.ParseStyle(generator.NewDrawIOGenerator().BuildComponentStyle(comp))
+	var style []string
+	if has("filled") {
+		fill := a["fillcolor"]
+		if fill == "" {
+			fill = a["color"]
+		}
+		if isColor(fill) && fill != defaults.FillColor {
+			style = append(style, "fillColor="+fill)
+		}
+	}
+	if stroke := a["color"]; isColor(stroke) && stroke != defaults.StrokeColor {
+		style = append(style, "strokeColor="+stroke)
+	}
+	if font := a["fontcolor"]; isColor(font) && font != defaults.FontColor {
+		style = append(style, "fontColor="+font)
+	}
+	if width := penWidth(a["penwidth"]); width > 1 && width != defaults.StrokeWidth {
+		style = append(style, fmt.Sprintf("strokeWidth=%d", width))
+	}
+	if (has("dashed") || has("dotted")) && !defaults.Dashed {
+		style = append(style, "dashed=1")
+	}
+	comp.Style = strings.Join(style, ";")
+	return comp
+}
+
+// modelShape returns the model shape a node shape draws, if there is one.
+func modelShape(shape string, rounded bool) model.ShapeType {
+	switch shape {
+	case "box", "rect", "rectangle", "square":
+		if rounded {
+			return model.ShapeTypeRounded
+		}
+		return model.ShapeTypeRectangle
+	case "ellipse", "oval", "circle":
+		return model.ShapeTypeEllipse
+	case "cylinder":
+		return model.ShapeTypeCylinder
+	case "box3d":
+		return model.ShapeTypeIsoCube
+	}
+	return ""
+}
+
+// connection returns the connection an edge stands for.
+func (e edge) connection(directed bool) model.Connection {
+	a := e.attrs
+	// Edges are unidirectional unless dir=both, as with connectsTo, so that
+	// an edge repeating an annotation merges with it.
+	conn := model.Connection{Source: e.source, Target: e.target, Label: labelText(a["label"], ""),
+		Direction: model.ConnectionDirectionUnidirectional}
+	if conn.Label == "" {
+		conn.Label = labelText(a["xlabel"], "")
+	}
+
+	dir := a["dir"]
+	if dir == "" {
+		dir = "forward"
+		if !directed {
+			dir = "none"
+		}
+	}
+	head := arrows[a["arrowhead"]]
+	switch dir {
+	case "both":
+		conn.Direction = model.ConnectionDirectionBidirectional
+		if tail := arrows[a["arrowtail"]]; tail != "" {
+			conn.StartArrow = tail
+		}
+	case "back":
+		conn.Source, conn.Target = conn.Target, conn.Source
+		head = arrows[a["arrowtail"]]
+	case "none":
+		head = generator.ArrowNone
+	}
+	conn.EndArrow = head
+
+	var style []string
+	if s := a["style"]; strings.Contains(s, "dashed") || strings.Contains(s, "dotted") {
+		style = append(style, "dashed=1")
+	}
+	if color := a["color"]; isColor(color) {
+		style = append(style, "strokeColor="+color)
+	}
+	if width := penWidth(a["penwidth"]); width > 1 {
+		style = append(style, fmt.Sprintf("strokeWidth=%d", width))
+	}
+	conn.Style = strings.Join(style, ";")
+	return conn
+}
+
+// isColor reports whether a color value is a single color draw.io can
+// use: a name or an RGB value, not a list or an HSV triple.
+func isColor(value string) bool {
+	return value != "" && !strings.ContainsAny(value, ": ,")
+}
+
+func penWidth(value string) int {
+	width, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return int(width + 0.5)
+}
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+	escape    = regexp.MustCompile(`\\[nlr]`)
+)
+
+// labelText returns the text of a label: HTML labels lose their markup,
+// \N stands for the node ID and line breaks become spaces.
+func labelText(label, id string) string {
+	if strings.HasPrefix(label, "<") && strings.HasSuffix(label, ">") {
+		label = htmlBreak.ReplaceAllString(label[1:len(label)-1], " ")
+		label = html.UnescapeString(htmlTag.ReplaceAllString(label, ""))
+	} else {
+		label = strings.ReplaceAll(label, `\N`, id)
+		label = escape.ReplaceAllString(label, " ")
+		label = strings.ReplaceAll(label, `\\`, `\`)
+	}
+	return strings.Join(strings.Fields(label), " ")
+}
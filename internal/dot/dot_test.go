@@ -0,0 +1,180 @@
+package dot_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/dot"
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+const checkout = `/* The order path */
+strict digraph checkout {
+	rankdir=LR
+	node [shape=box, style="rounded"]
+	edge [color="#333333"]
+
+	customer [shape=ellipse, label="Customer"]
+	subgraph cluster_backend {
+		label = "Backend";
+		node [style="rounded,filled", fillcolor=lightblue]
+		web [label=<Web<br/><b>Shop</b>>]
+		api [label="API\nGateway", shape=hexagon, tooltip="Routes \"v1\" calls"]
+		db [shape=cylinder, style=filled, fillcolor="#ffe6cc"]
+	}
+	# preprocessor line
+	customer -> web:n [label="places " + "orders"]
+	web -> api -> {orders; payments} [style=dashed]
+	orders -> db [dir=both, penwidth=2.0]
+	db -> audit [dir=back, arrowtail=dot]
+	"go.uber.org/zap" -> audit [arrowhead=none, color="red:blue"]
+}
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	got, err := dot.Parse("checkout.dot", []byte(checkout))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "customer", Label: "Customer", Type: model.ComponentTypeUser},
+			{Name: "web", Label: "Web Shop", Type: model.ComponentTypeService, Swimlane: "Backend", Style: "fillColor=lightblue"},
+			{Name: "api", Label: "API Gateway", Description: `Routes "v1" calls`, Type: model.ComponentTypeGateway, Swimlane: "Backend", Style: "fillColor=lightblue"},
+			{Name: "db", Type: model.ComponentTypeDatabase, Swimlane: "Backend"},
+			{Name: "orders", Type: model.ComponentTypeService},
+			{Name: "payments", Type: model.ComponentTypeService},
+			{Name: "audit", Type: model.ComponentTypeService},
+			{Name: "go.uber.org/zap", Type: model.ComponentTypeService},
+		},
+		Connections: []model.Connection{
+			{Source: "customer", Target: "web", Direction: model.ConnectionDirectionUnidirectional, Label: "places orders", Style: "strokeColor=#333333"},
+			{Source: "web", Target: "api", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1;strokeColor=#333333"},
+			{Source: "api", Target: "orders", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1;strokeColor=#333333"},
+			{Source: "api", Target: "payments", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1;strokeColor=#333333"},
+			{Source: "orders", Target: "db", Direction: model.ConnectionDirectionBidirectional, Style: "strokeColor=#333333;strokeWidth=2"},
+			{Source: "audit", Target: "db", Direction: model.ConnectionDirectionUnidirectional, EndArrow: "oval", Style: "strokeColor=#333333"},
+			{Source: "go.uber.org/zap", Target: "audit", Direction: model.ConnectionDirectionUnidirectional, EndArrow: generator.ArrowNone},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseUndirected(t *testing.T) {
+	t.Parallel()
+	got, err := dot.Parse("net.gv", []byte("graph { a -- b; b -- c [dir=forward] }"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []model.Connection{
+		{Source: "a", Target: "b", Direction: model.ConnectionDirectionUnidirectional, EndArrow: generator.ArrowNone},
+		{Source: "b", Target: "c", Direction: model.ConnectionDirectionUnidirectional},
+	}
+	if !reflect.DeepEqual(got.Connections, want) {
+		t.Errorf("connections = %+v\nwant %+v", got.Connections, want)
+	}
+}
+
+func TestParseGenerated(t *testing.T) {
+	t.Parallel()
+	source := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend", Description: "Public API"},
+			{Name: "DB", Label: "Orders DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend"},
+			{Name: "Events", Type: model.ComponentTypeQueue},
+			{Name: "Sessions", Type: model.ComponentTypeCache, Style: "fillColor=#ffffff"},
+			{Name: "Check", Type: model.ComponentTypeDecision},
+			{Name: "Worker", Type: model.ComponentTypeService, Shape: model.ShapeTypeRectangle, Page: "Jobs"},
+		},
+		Connections: []model.Connection{
+			{Source: "API", Target: "DB", Label: "reads & writes", Direction: model.ConnectionDirectionBidirectional},
+			{Source: "API", Target: "Events", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1"},
+			{Source: "Events", Target: "Check", Direction: model.ConnectionDirectionUnidirectional, EndArrow: generator.ArrowNone},
+			{Source: "Worker", Target: "Worker", Direction: model.ConnectionDirectionUnidirectional, Page: "Jobs"},
+		},
+	}
+	data, err := generator.NewDOTGenerator().Generate(source)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	got, err := dot.Parse("arch.dot", data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, source) {
+		t.Errorf("Parse = %+v\nwant %+v\n%s", got, source, data)
+	}
+}
+
+func TestParseEdgeOnly(t *testing.T) {
+	t.Parallel()
+	got, err := dot.Parse("deps.dot", []byte("digraph { a -> b }"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "a", Type: model.ComponentTypeService},
+			{Name: "b", Type: model.ComponentTypeService},
+		},
+		Connections: []model.Connection{{Source: "a", Target: "b", Direction: model.ConnectionDirectionUnidirectional}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParsePages(t *testing.T) {
+	t.Parallel()
+	got, err := dot.Parse("pages.dot", []byte(`digraph "Architecture Diagram" { a -> b } digraph { a }`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []model.Component{
+		{Name: "a", Type: model.ComponentTypeService},
+		{Name: "b", Type: model.ComponentTypeService},
+		{Name: "a (2)", Label: "a", Type: model.ComponentTypeService, Page: "Graph 2"},
+	}
+	if !reflect.DeepEqual(got.Components, want) {
+		t.Errorf("components = %+v\nwant %+v", got.Components, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "empty", data: "// nothing\n", want: "no graphs"},
+		{name: "keyword", data: "flowchart { a }", want: `line 1: expected graph or digraph, found "flowchart"`},
+		{name: "brace", data: "digraph {\n a -> b\n", want: `line 3: expected "}", found end of file`},
+		{name: "edge", data: "digraph {\n a -> ;\n}", want: `line 2: expected a node ID, found ";"`},
+		{name: "attribute", data: "digraph { a [label=] }", want: `line 1: expected an attribute value, found "]"`},
+		{name: "string", data: "digraph { \"a }", want: "line 1: unterminated string"},
+		{name: "character", data: "digraph { a @ b }", want: `line 1: unexpected character '@'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := dot.Parse("a.dot", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid DOT file a.dot: ") {
+				t.Errorf("Parse error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package dot
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenID is a name, a number, a quoted string or an HTML string.
+	tokenID
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	// html marks an ID written as an HTML string, <...>.
+	html bool
+}
+
+// tokenize splits a DOT file into tokens. Quoted strings are unquoted and
+// joined when concatenated with +.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	lineStart := true
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' && lineStart:
+			// Lines starting with # are preprocessor output.
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		}
+		lineStart = false
+
+		switch {
+		case c == '"':
+			text, n, err := quoted(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			// "a" + "b" is one string.
+			if prev := len(tokens) - 2; prev >= 0 && tokens[prev+1].text == "+" && tokens[prev+1].kind == tokenSymbol && tokens[prev].kind == tokenID && !tokens[prev].html {
+				tokens[prev].text += text
+				tokens = tokens[:prev+1]
+			} else {
+				tokens = append(tokens, token{kind: tokenID, text: text, line: line})
+			}
+			line += strings.Count(src[i:i+n], "\n")
+			i += n
+		case c == '<':
+			n, err := htmlString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tokens = append(tokens, token{kind: tokenID, text: src[i+1 : i+n-1], line: line, html: true})
+			line += strings.Count(src[i:i+n], "\n")
+			i += n
+		case strings.HasPrefix(src[i:], "->") || strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, token{kind: tokenSymbol, text: src[i : i+2], line: line})
+			i += 2
+		case strings.ContainsRune("{}[];,=:+", rune(c)):
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(c), line: line})
+			i++
+		case isIDByte(c) || c == '-' || c == '.':
+			n := 1
+			for i+n < len(src) && (isIDByte(src[i+n]) || src[i+n] == '.') {
+				n++
+			}
+			tokens = append(tokens, token{kind: tokenID, text: src[i : i+n], line: line})
+			i += n
+		default:
+			return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, line: line}), nil
+}
+
+func isIDByte(c byte) bool {
+	return c == '_' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// quoted returns the text of a quoted string at the start of src and its
+// length. Only \" is an escape; other backslashes are kept for labels.
+func quoted(src string) (string, int, error) {
+	var sb strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch {
+		case src[i] == '\\' && i+1 < len(src) && src[i+1] == '"':
+			sb.WriteByte('"')
+			i++
+		case src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			i++
+		case src[i] == '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// htmlString returns the length of the HTML string, with balanced angle
+// brackets, at the start of src.
+func htmlString(src string) (int, error) {
+	depth := 0
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated HTML string")
+}
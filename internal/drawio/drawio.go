@@ -0,0 +1,553 @@
+// Package drawio reads draw.io files back into diagram models.
+package drawio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+// IsDrawIOFile reports whether a path names a .drawio file.
+func IsDrawIOFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".drawio")
+}
+
+// Read rebuilds the diagram drawn in a .drawio file: vertices become
+// components and edges between them connections. Pages may be plain XML or
+// compressed, by CompressXML or by draw.io itself. Every page but the
+// default one sets the page of its components and connections; labelled
+// containers become swimlanes. Unlabelled vertices, text and edges that do
+// not join two components are skipped.
+func Read(path string, data []byte) (*model.Diagram, error) {
+	pages, err := readPages(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid draw.io file %s: %w", path, err)
+	}
+
+	b := &builder{
+		diagram: &model.Diagram{Type: model.DiagramTypeArchitecture},
+		names:   make(map[string]int),
+	}
+	for i, p := range pages {
+		name := p.Name
+		switch {
+		case name == generator.DefaultPageName:
+			name = ""
+		case name == "":
+			name = fmt.Sprintf("Page-%d", i+1)
+		}
+		b.addPage(name, p.Model.Root.Cells)
+	}
+	return b.diagram, nil
+}
+
+type file struct {
+	Diagrams []page `xml:"diagram"`
+}
+
+type page struct {
+	Name  string      `xml:"name,attr"`
+	Model *graphModel `xml:"mxGraphModel"`
+	// Data holds the page when it is compressed.
+	Data string `xml:",chardata"`
+}
+
+type graphModel struct {
+	Root struct {
+		Cells []cell `xml:",any"`
+	} `xml:"root"`
+}
+
+// cell is an mxCell, or an object or UserObject element wrapping one.
+type cell struct {
+	XMLName  xml.Name
+	ID       string   `xml:"id,attr"`
+	Value    string   `xml:"value,attr"`
+	Label    string   `xml:"label,attr"`
+	Style    string   `xml:"style,attr"`
+	Vertex   string   `xml:"vertex,attr"`
+	Edge     string   `xml:"edge,attr"`
+	Parent   string   `xml:"parent,attr"`
+	Source   string   `xml:"source,attr"`
+	Target   string   `xml:"target,attr"`
+	Geometry geometry `xml:"mxGeometry"`
+	Cell     *cell    `xml:"mxCell"`
+}
+
+type geometry struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+// unwrap returns the mxCell of an object, which carries the id and label.
+func (c cell) unwrap() cell {
+	if c.Cell == nil {
+		return c
+	}
+	inner := *c.Cell
+	inner.ID, inner.Value = c.ID, c.Label
+	return inner
+}
+
+// readPages decodes an mxfile, or a bare mxGraphModel as one unnamed page.
+func readPages(data []byte) ([]page, error) {
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "mxGraphModel":
+		m := &graphModel{}
+		if err := xml.Unmarshal(data, m); err != nil {
+			return nil, err
+		}
+		return []page{{Model: m}}, nil
+	case "mxfile":
+		var f file
+		if err := xml.Unmarshal(data, &f); err != nil {
+			return nil, err
+		}
+		if len(f.Diagrams) == 0 {
+			return nil, errors.New("no diagram pages")
+		}
+		for i := range f.Diagrams {
+			if err := f.Diagrams[i].decompress(); err != nil {
+				return nil, err
+			}
+		}
+		return f.Diagrams, nil
+	}
+	return nil, fmt.Errorf("unexpected root element <%s>, want <mxfile>", root)
+}
+
+// decompress decodes the graph model of a compressed page.
+func (p *page) decompress() error {
+	if p.Model != nil {
+		return nil
+	}
+	p.Model = &graphModel{}
+	if strings.TrimSpace(p.Data) == "" {
+		return nil
+	}
+
+	data, err := generator.DecompressXML([]byte(p.Data))
+	if err != nil {
+		return fmt.Errorf("page %q: %w", p.Name, err)
+	}
+	root, err := rootElement(data)
+	if err != nil {
+		return fmt.Errorf("page %q: %w", p.Name, err)
+	}
+
+	switch root {
+	case "mxGraphModel":
+		err = xml.Unmarshal(data, p.Model)
+	case "diagram":
+		// CompressXML pages of this tool hold the whole diagram element.
+		var inner page
+		if err = xml.Unmarshal(data, &inner); err == nil && inner.Model != nil {
+			p.Model = inner.Model
+		}
+	default:
+		err = fmt.Errorf("unexpected element <%s>, want <mxGraphModel>", root)
+	}
+	if err != nil {
+		return fmt.Errorf("page %q: %w", p.Name, err)
+	}
+	return nil
+}
+
+// rootElement returns the name of the first element of an XML document.
+func rootElement(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no XML element")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// builder adds the pages of a file to a diagram.
+type builder struct {
+	diagram *model.Diagram
+	// names counts the components labelled alike, which get unique names.
+	names map[string]int
+	gen   generator.DrawIOGenerator
+}
+
+// uniqueName returns the label, numbered from its second use.
+func (b *builder) uniqueName(label string) string {
+	b.names[label]++
+	if n := b.names[label]; n > 1 {
+		return fmt.Sprintf("%s (%d)", label, n)
+	}
+	return label
+}
+
+// addPage adds the components and connections drawn on a page.
+func (b *builder) addPage(pageName string, raw []cell) {
+	pc := newPageCells(raw)
+
+	names := make(map[string]string)
+	for i := range pc.cells {
+		c := &pc.cells[i]
+		if c.Vertex != "1" || pc.isContainer(c) || pc.isEdge(c.Parent) {
+			continue
+		}
+		st := parseStyle(c.Style)
+		label := labelText(c.Value, st)
+		if label == "" || st.name == "text" || st.name == "edgeLabel" {
+			continue
+		}
+
+		comp := b.component(st)
+		comp.Name = b.uniqueName(label)
+		if comp.Name != label {
+			comp.Label = label
+		}
+		comp.Page = pageName
+		comp.Swimlane = pc.swimlane(c)
+		x, y := pc.origin(c)
+		comp.X, comp.Y = int(math.Round(x)), int(math.Round(y))
+		names[c.ID] = comp.Name
+		b.diagram.AddComponent(comp)
+	}
+
+	for i := range pc.cells {
+		c := &pc.cells[i]
+		source, ok1 := names[c.Source]
+		target, ok2 := names[c.Target]
+		if c.Edge != "1" || !ok1 || !ok2 {
+			continue
+		}
+		st := parseStyle(c.Style)
+		conn := b.connection(st)
+		conn.Source, conn.Target, conn.Page = source, target, pageName
+		if conn.Label = labelText(c.Value, st); conn.Label == "" {
+			conn.Label = pc.edgeLabel(c)
+		}
+		b.diagram.AddConnection(conn)
+	}
+}
+
+// pageCells indexes the cells of a page.
+type pageCells struct {
+	cells []cell
+	byID  map[string]*cell
+	// parents holds the cells other vertices are nested in.
+	parents map[string]bool
+	// lanes are the labelled containers, by cell id.
+	lanes map[string]lane
+}
+
+// lane is a swimlane with its absolute bounds.
+type lane struct {
+	name                string
+	x, y, width, height float64
+}
+
+func newPageCells(raw []cell) *pageCells {
+	pc := &pageCells{
+		cells:   make([]cell, 0, len(raw)),
+		byID:    make(map[string]*cell),
+		parents: make(map[string]bool),
+		lanes:   make(map[string]lane),
+	}
+	for _, c := range raw {
+		pc.cells = append(pc.cells, c.unwrap())
+	}
+	for i := range pc.cells {
+		pc.byID[pc.cells[i].ID] = &pc.cells[i]
+		if pc.cells[i].Vertex == "1" {
+			pc.parents[pc.cells[i].Parent] = true
+		}
+	}
+
+	// Labelled containers are swimlanes; groups only nest their members.
+	for i := range pc.cells {
+		c := &pc.cells[i]
+		if c.Vertex != "1" || !pc.isContainer(c) {
+			continue
+		}
+		st := parseStyle(c.Style)
+		if label := labelText(c.Value, st); label != "" && st.name != "group" {
+			x, y := pc.origin(c)
+			pc.lanes[c.ID] = lane{label, x, y, c.Geometry.Width, c.Geometry.Height}
+		}
+	}
+	return pc
+}
+
+func (pc *pageCells) isVertex(id string) bool {
+	c, ok := pc.byID[id]
+	return ok && c.Vertex == "1"
+}
+
+func (pc *pageCells) isEdge(id string) bool {
+	c, ok := pc.byID[id]
+	return ok && c.Edge == "1"
+}
+
+// isContainer reports whether a vertex holds others rather than being a
+// component itself.
+func (pc *pageCells) isContainer(c *cell) bool {
+	st := parseStyle(c.Style)
+	return pc.parents[c.ID] || st.name == "swimlane" || st.name == "group" ||
+		st.get("shape") == "swimlane" || st.get("container") == "1"
+}
+
+// ancestors returns the vertices a vertex is nested in, innermost first.
+func (pc *pageCells) ancestors(c *cell) []*cell {
+	var list []*cell
+	for id := c.Parent; pc.isVertex(id) && len(list) < len(pc.cells); id = pc.byID[id].Parent {
+		list = append(list, pc.byID[id])
+	}
+	return list
+}
+
+// origin returns the absolute position of a vertex, whose coordinates are
+// relative to the vertex it is nested in.
+func (pc *pageCells) origin(c *cell) (float64, float64) {
+	x, y := c.Geometry.X, c.Geometry.Y
+	for _, p := range pc.ancestors(c) {
+		x, y = x+p.Geometry.X, y+p.Geometry.Y
+	}
+	return x, y
+}
+
+// swimlane returns the name of the innermost swimlane holding a vertex.
+// Swimlanes may also be drawn behind components without holding them, as
+// this tool writes them; then the smallest one around its center wins.
+func (pc *pageCells) swimlane(c *cell) string {
+	for _, p := range pc.ancestors(c) {
+		if l, ok := pc.lanes[p.ID]; ok {
+			return l.name
+		}
+	}
+
+	x, y := pc.origin(c)
+	x, y = x+c.Geometry.Width/2, y+c.Geometry.Height/2
+	name, area := "", math.Inf(1)
+	for _, cell := range pc.cells {
+		l, ok := pc.lanes[cell.ID]
+		if ok && x >= l.x && x <= l.x+l.width && y >= l.y && y <= l.y+l.height && l.width*l.height < area {
+			name, area = l.name, l.width*l.height
+		}
+	}
+	return name
+}
+
+// edgeLabel returns the text of the first label vertex nested in an edge.
+func (pc *pageCells) edgeLabel(edge *cell) string {
+	for _, c := range pc.cells {
+		if c.Vertex == "1" && c.Parent == edge.ID {
+			if label := labelText(c.Value, parseStyle(c.Style)); label != "" {
+				return label
+			}
+		}
+	}
+	return ""
+}
+
+// shapeTypes gives the component type drawn by a draw.io shape.
+var shapeTypes = map[string]model.ComponentType{
+	"rounded":       model.ComponentTypeService,
+	"rectangle":     model.ComponentTypeService,
+	"cylinder":      model.ComponentTypeDatabase,
+	"cylinder3":     model.ComponentTypeDatabase,
+	"datastore":     model.ComponentTypeDatabase,
+	"parallelogram": model.ComponentTypeQueue,
+	"ellipse":       model.ComponentTypeUser,
+	"actor":         model.ComponentTypeUser,
+	"umlActor":      model.ComponentTypeUser,
+	"rhombus":       model.ComponentTypeDecision,
+	"document":      model.ComponentTypeExternal,
+	"cloud":         model.ComponentTypeExternal,
+}
+
+// isometricShapes gives the model shape and component type of the
+// isometric shapes this tool draws.
+var isometricShapes = map[string]struct {
+	shape model.ShapeType
+	typ   model.ComponentType
+}{
+	string(generator.ShapeIsoServer):    {model.ShapeTypeIsoServer, model.ComponentTypeService},
+	string(generator.ShapeIsoDatabase):  {model.ShapeTypeIsoDatabase, model.ComponentTypeDatabase},
+	string(generator.ShapeIsoContainer): {model.ShapeTypeIsoContainer, model.ComponentTypeService},
+	string(generator.ShapeIsoCloud):     {model.ShapeTypeIsoCloud, model.ComponentTypeExternal},
+	string(generator.ShapeIsoNetwork):   {model.ShapeTypeIsoNetwork, model.ComponentTypeGateway},
+	string(generator.ShapeIsoCube):      {model.ShapeTypeIsoCube, model.ComponentTypeStorage},
+}
+
+// component infers the type and shape of a vertex from its style, keeping
+// the style settings that differ from those the type is drawn with.
+func (b *builder) component(st style) model.Component {
+	shape := st.shape()
+	comp := model.Component{Type: model.ComponentTypeService}
+	if iso, ok := isometricShapes[shape]; ok {
+		comp.Type, comp.Shape = iso.typ, iso.shape
+	} else if typ, ok := shapeTypes[shape]; ok {
+		comp.Type = typ
+	}
+
+	// Caches and flowchart terminals share their shape with other types and
+	// are told apart by the colors they are drawn with.
+	colors := strings.ToLower(st.get("fillColor") + " " + st.get("strokeColor"))
+	switch {
+	case comp.Type == model.ComponentTypeService && shape == "rounded" &&
+		(strings.Contains(colors, "#f8cecc") || strings.Contains(colors, "#b85450")):
+		comp.Type = model.ComponentTypeCache
+	case comp.Type == model.ComponentTypeUser &&
+		(strings.Contains(colors, "#d5e8d4") || strings.Contains(colors, "#82b366")):
+		comp.Type = model.ComponentTypeTerminal
+	}
+
+	base := parseStyle(b.gen.BuildComponentStyle(comp))
+	var overrides []string
+	if base.shape() != shape {
+		overrides = append(overrides, "shape="+shape)
+	}
+	comp.Style = st.overrides(base, overrides, "shape", "rounded", "edgeStyle", "startArrow", "endArrow")
+	return comp
+}
+
+// connection reads the direction, arrows and routing of an edge, keeping
+// the other style settings that differ from those it is drawn with.
+func (b *builder) connection(st style) model.Connection {
+	conn := model.Connection{EdgeStyle: st.get("edgeStyle")}
+
+	// Without a startArrow, draw.io draws none; without an endArrow, a classic one.
+	switch start := st.get("startArrow"); start {
+	case "", generator.ArrowNone:
+	case generator.ArrowClassic:
+		conn.Direction = model.ConnectionDirectionBidirectional
+	default:
+		conn.StartArrow = start
+	}
+	if end := st.get("endArrow"); end != "" && end != generator.ArrowClassic {
+		conn.EndArrow = end
+	}
+
+	base := parseStyle(b.gen.BuildEdgeStyle(conn))
+	conn.Style = st.overrides(base, nil, "edgeStyle", "startArrow", "endArrow")
+	return conn
+}
+
+// styleKeys are the style settings the generator understands.
+var styleKeys = map[string]bool{
+	"shape": true, "fillColor": true, "strokeColor": true, "strokeWidth": true,
+	"opacity": true, "gradientColor": true, "gradientDirection": true,
+	"fontSize": true, "fontFamily": true, "fontColor": true, "fontStyle": true,
+	"rounded": true, "dashed": true, "dashPattern": true, "shadow": true,
+	"glass": true, "align": true, "verticalAlign": true, "image": true,
+	"imageWidth": true, "imageHeight": true, "imageAspect": true,
+	"edgeStyle": true, "startArrow": true, "endArrow": true, "curved": true,
+	"elbow": true, "orthogonal": true,
+}
+
+// style is a parsed draw.io style string. Its name is the leading setting
+// without a value, such as ellipse in "ellipse;whiteSpace=wrap".
+type style struct {
+	name  string
+	attrs [][2]string
+}
+
+func parseStyle(s string) style {
+	var st style
+	for i, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		key, value, ok := strings.Cut(part, "=")
+		switch {
+		case ok:
+			st.attrs = append(st.attrs, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+		case i == 0:
+			st.name = part
+		}
+	}
+	return st
+}
+
+func (s style) get(key string) string {
+	for _, attr := range s.attrs {
+		if attr[0] == key {
+			return attr[1]
+		}
+	}
+	return ""
+}
+
+// shape returns the shape drawn, rectangles being the default.
+func (s style) shape() string {
+	switch {
+	case s.get("shape") != "":
+		return s.get("shape")
+	case s.name != "":
+		return s.name
+	case s.get("rounded") == "1":
+		return "rounded"
+	}
+	return "rectangle"
+}
+
+// overrides returns the settings the generator understands whose values
+// differ from those of base, after the given ones. Unset flags count as 0.
+func (s style) overrides(base style, overrides []string, skip ...string) string {
+	for _, attr := range s.attrs {
+		key, value := attr[0], attr[1]
+		if !styleKeys[key] || contains(skip, key) {
+			continue
+		}
+		want := base.get(key)
+		if want == "" {
+			want = "0"
+		}
+		if value != want {
+			overrides = append(overrides, key+"="+value)
+		}
+	}
+	return strings.Join(overrides, ";")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li)>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// labelText returns the text of a cell value, without the markup of HTML
+// labels and with whitespace collapsed.
+func labelText(value string, st style) string {
+	if st.get("html") == "1" {
+		value = htmlBreak.ReplaceAllString(value, " ")
+		value = html.UnescapeString(htmlTag.ReplaceAllString(value, ""))
+	}
+	return strings.Join(strings.Fields(value), " ")
+}
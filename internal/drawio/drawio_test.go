@@ -0,0 +1,198 @@
+package drawio_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/drawio"
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+func TestReadGenerated(t *testing.T) {
+	t.Parallel()
+	source := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend", X: 100, Y: 80},
+			{Name: "DB", Type: model.ComponentTypeDatabase, Label: "Orders DB", Swimlane: "Backend", X: 100, Y: 240},
+			{Name: "Events", Type: model.ComponentTypeQueue, X: 400, Y: 80},
+			{Name: "Sessions", Type: model.ComponentTypeCache, Style: "fillColor=#ffffff", X: 400, Y: 240},
+			{Name: "Worker", Type: model.ComponentTypeService, Shape: model.ShapeTypeIsoServer, Page: "Ops", X: 60, Y: 60},
+		},
+		Connections: []model.Connection{
+			{Source: "API", Target: "DB", Label: "reads & writes", Direction: model.ConnectionDirectionBidirectional},
+			{Source: "API", Target: "Events", EdgeStyle: generator.EdgeStyleOrthogonal, Style: "dashed=1"},
+		},
+	}
+
+	for _, compress := range []bool{false, true} {
+		gen := generator.NewDrawIOGenerator()
+		gen.Compress = compress
+		data, err := gen.Generate(source)
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+
+		got, err := drawio.Read("arch.drawio", data)
+		if err != nil {
+			t.Fatalf("compress=%v: Read failed: %v", compress, err)
+		}
+
+		want := map[string]model.Component{
+			"API":       {Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend", X: 100, Y: 80},
+			"Orders DB": {Name: "Orders DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend", X: 100, Y: 240},
+			"Events":    {Name: "Events", Type: model.ComponentTypeQueue, X: 400, Y: 80},
+			"Sessions":  {Name: "Sessions", Type: model.ComponentTypeCache, Style: "fillColor=#ffffff", X: 400, Y: 240},
+			"Worker":    {Name: "Worker", Type: model.ComponentTypeService, Shape: model.ShapeTypeIsoServer, Page: "Ops", X: 60, Y: 60},
+		}
+		if len(got.Components) != len(want) {
+			t.Fatalf("compress=%v: got %d components, want %d: %+v", compress, len(got.Components), len(want), got.Components)
+		}
+		for _, comp := range got.Components {
+			if comp != want[comp.Name] {
+				t.Errorf("compress=%v: component %+v\nwant %+v", compress, comp, want[comp.Name])
+			}
+		}
+
+		wantConns := []model.Connection{
+			{Source: "API", Target: "Orders DB", Label: "reads & writes", Direction: model.ConnectionDirectionBidirectional},
+			{Source: "API", Target: "Events", EdgeStyle: generator.EdgeStyleOrthogonal, Style: "dashed=1"},
+		}
+		if !reflect.DeepEqual(got.Connections, wantConns) {
+			t.Errorf("compress=%v: connections = %+v\nwant %+v", compress, got.Connections, wantConns)
+		}
+	}
+}
+
+// handDrawn is a page as draw.io saves it, with nested containers, HTML
+// labels, data objects and annotations.
+const handDrawn = `<mxGraphModel dx="1000" dy="600" grid="1">
+  <root>
+    <mxCell id="0" />
+    <mxCell id="1" parent="0" />
+    <mxCell id="vpc" value="VPC" style="swimlane;whiteSpace=wrap;html=1;" vertex="1" parent="1">
+      <mxGeometry x="40" y="40" width="400" height="300" as="geometry" />
+    </mxCell>
+    <mxCell id="grp" value="" style="group" vertex="1" connectable="0" parent="vpc">
+      <mxGeometry x="20" y="40" width="300" height="200" as="geometry" />
+    </mxCell>
+    <object label="Order&lt;br&gt;Service" owner="checkout" id="svc">
+      <mxCell style="rounded=1;whiteSpace=wrap;html=1;fillColor=#d5e8d4;" vertex="1" parent="grp">
+        <mxGeometry x="10" y="10" width="120" height="60" as="geometry" />
+      </mxCell>
+    </object>
+    <mxCell id="db" value="Orders" style="shape=cylinder3;whiteSpace=wrap;html=1;boundedLbl=1;size=15;" vertex="1" parent="vpc">
+      <mxGeometry x="200" y="200" width="60" height="80" as="geometry" />
+    </mxCell>
+    <mxCell id="user" value="Customer" style="ellipse;whiteSpace=wrap;html=1;" vertex="1" parent="1">
+      <mxGeometry x="600" y="100" width="80" height="80" as="geometry" />
+    </mxCell>
+    <mxCell id="dup" value="Orders" style="shape=document;whiteSpace=wrap;html=1;" vertex="1" parent="1">
+      <mxGeometry x="600" y="300" width="120" height="80" as="geometry" />
+    </mxCell>
+    <mxCell id="note" value="Drawn by hand" style="text;html=1;" vertex="1" parent="1">
+      <mxGeometry x="600" y="20" width="100" height="20" as="geometry" />
+    </mxCell>
+    <mxCell id="blank" value="" style="rounded=0;" vertex="1" parent="1">
+      <mxGeometry x="800" y="20" width="10" height="10" as="geometry" />
+    </mxCell>
+    <mxCell id="e1" style="edgeStyle=orthogonalEdgeStyle;rounded=0;html=1;endArrow=block;strokeColor=#FF0000;" edge="1" parent="1" source="user" target="svc">
+      <mxGeometry relative="1" as="geometry" />
+    </mxCell>
+    <mxCell id="e1-label" value="places orders" style="edgeLabel;html=1;" vertex="1" connectable="0" parent="e1">
+      <mxGeometry x="-0.2" relative="1" as="geometry" />
+    </mxCell>
+    <mxCell id="e2" value="SQL" style="html=1;startArrow=classic;" edge="1" parent="vpc" source="svc" target="db">
+      <mxGeometry relative="1" as="geometry" />
+    </mxCell>
+    <mxCell id="e3" value="to the lane" edge="1" parent="1" source="user" target="vpc">
+      <mxGeometry relative="1" as="geometry" />
+    </mxCell>
+    <mxCell id="e4" edge="1" parent="1" source="svc" target="blank">
+      <mxGeometry relative="1" as="geometry" />
+    </mxCell>
+  </root>
+</mxGraphModel>`
+
+// deflate compresses a page the way draw.io does.
+func deflate(t *testing.T, xml string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(url.PathEscape(xml))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestReadHandDrawn(t *testing.T) {
+	t.Parallel()
+	data := `<mxfile host="Electron" version="24.0.0">
+  <diagram id="a1" name="Checkout">` + deflate(t, handDrawn) + `</diagram>
+  <diagram id="a2" name="Page-2"><mxGraphModel><root><mxCell id="0" /></root></mxGraphModel></diagram>
+</mxfile>`
+
+	got, err := drawio.Read("checkout.drawio", []byte(data))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "Order Service", Type: model.ComponentTypeService, Page: "Checkout", Swimlane: "VPC", Style: "fillColor=#d5e8d4", X: 70, Y: 90},
+			{Name: "Orders", Type: model.ComponentTypeDatabase, Page: "Checkout", Swimlane: "VPC", Style: "shape=cylinder3", X: 240, Y: 240},
+			{Name: "Customer", Type: model.ComponentTypeUser, Page: "Checkout", X: 600, Y: 100},
+			{Name: "Orders (2)", Label: "Orders", Type: model.ComponentTypeExternal, Page: "Checkout", X: 600, Y: 300},
+		},
+		Connections: []model.Connection{
+			{Source: "Customer", Target: "Order Service", Label: "places orders", Page: "Checkout",
+				EdgeStyle: generator.EdgeStyleOrthogonal, EndArrow: generator.ArrowBlock, Style: "strokeColor=#FF0000"},
+			{Source: "Order Service", Target: "Orders", Label: "SQL", Page: "Checkout",
+				Direction: model.ConnectionDirectionBidirectional},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "empty", data: "", want: "no XML element"},
+		{name: "syntax", data: "<mxfile><diagram>", want: "XML syntax error"},
+		{name: "root", data: "<svg/>", want: "unexpected root element <svg>"},
+		{name: "pages", data: "<mxfile></mxfile>", want: "no diagram pages"},
+		{name: "base64", data: `<mxfile><diagram name="P">%%%</diagram></mxfile>`, want: `page "P": failed to decode base64`},
+		{name: "deflate", data: `<mxfile><diagram name="P">aGVsbG8=</diagram></mxfile>`, want: `page "P": failed to decompress xml`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := drawio.Read("a.drawio", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Read error = %v, want %q", err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid draw.io file a.drawio: ") {
+				t.Errorf("Read error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
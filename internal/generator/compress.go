@@ -3,10 +3,13 @@ package generator
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/zlib"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
 )
 
 // CompressionLevel defines zlib compression levels.
@@ -107,3 +110,37 @@ func CompressAndEncodeWithLevel(xmlData []byte, level int) (string, error) {
 	}
 	return string(compressed), nil
 }
+
+// DecompressXML reverses CompressXML. It also reads the pages draw.io
+// compresses itself: raw deflate streams of URL-encoded XML.
+func DecompressXML(encoded []byte) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encoded)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	data, err := inflate(zlib.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		if data, err = inflate(flate.NewReader(bytes.NewReader(compressed)), nil); err != nil {
+			return nil, fmt.Errorf("failed to decompress xml: %w", err)
+		}
+	}
+
+	if bytes.HasPrefix(data, []byte("%")) {
+		unescaped, err := url.PathUnescape(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode URL-encoded xml: %w", err)
+		}
+		data = []byte(unescaped)
+	}
+	return data, nil
+}
+
+// inflate reads a whole decompressed stream.
+func inflate(r io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
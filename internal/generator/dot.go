@@ -0,0 +1,224 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+// DOTGenerator generates Graphviz DOT diagrams.
+type DOTGenerator struct {
+	// RankDir is the direction graphs are laid out in: TB, LR, BT or RL.
+	RankDir string
+}
+
+// NewDOTGenerator creates a new DOTGenerator with default settings.
+func NewDOTGenerator() *DOTGenerator {
+	return &DOTGenerator{RankDir: "TB"}
+}
+
+// Format returns the output format name.
+func (g *DOTGenerator) Format() string {
+	return "dot"
+}
+
+// Generate writes a digraph per page, named after it, which Graphviz renders
+// one after the other. Swimlanes become clusters, and components keep the
+// colors and shapes of their draw.io style along with a type attribute so
+// that the graph reads back into the same components.
+func (g *DOTGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
+	pages := SplitPages(diagram)
+	var sb strings.Builder
+	written := 0
+	for i, page := range pages {
+		if len(page.Components) == 0 && len(page.Connections) == 0 && (written > 0 || i < len(pages)-1) {
+			continue
+		}
+		if written > 0 {
+			sb.WriteString("\n")
+		}
+		g.writePage(&sb, page)
+		written++
+	}
+	return []byte(sb.String()), nil
+}
+
+func (g *DOTGenerator) writePage(sb *strings.Builder, page model.Page) {
+	fmt.Fprintf(sb, "digraph %s {\n", dotID(page.Name))
+	if g.RankDir != "" {
+		fmt.Fprintf(sb, "\trankdir=%s;\n", g.RankDir)
+	}
+	sb.WriteString("\tnode [fontname=\"Helvetica\", fontsize=12];\n")
+	sb.WriteString("\tedge [fontname=\"Helvetica\", fontsize=10];\n")
+
+	var lanes []string
+	byLane := make(map[string][]model.Component)
+	for _, comp := range page.Components {
+		if _, seen := byLane[comp.Swimlane]; !seen && comp.Swimlane != "" {
+			lanes = append(lanes, comp.Swimlane)
+		}
+		byLane[comp.Swimlane] = append(byLane[comp.Swimlane], comp)
+	}
+
+	if len(page.Components) > 0 {
+		sb.WriteString("\n")
+	}
+	for i, lane := range lanes {
+		fmt.Fprintf(sb, "\tsubgraph %s {\n", dotID(fmt.Sprintf("cluster_%d", i)))
+		fmt.Fprintf(sb, "\t\tlabel=%s;\n", dotID(lane))
+		for _, comp := range byLane[lane] {
+			fmt.Fprintf(sb, "\t\t%s [%s];\n", dotID(comp.Name), strings.Join(dotNodeAttrs(comp), ", "))
+		}
+		sb.WriteString("\t}\n")
+	}
+	for _, comp := range byLane[""] {
+		fmt.Fprintf(sb, "\t%s [%s];\n", dotID(comp.Name), strings.Join(dotNodeAttrs(comp), ", "))
+	}
+
+	if len(page.Connections) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, conn := range page.Connections {
+		fmt.Fprintf(sb, "\t%s -> %s", dotID(conn.Source), dotID(conn.Target))
+		if attrs := dotEdgeAttrs(conn); len(attrs) > 0 {
+			fmt.Fprintf(sb, " [%s]", strings.Join(attrs, ", "))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+}
+
+// dotShapes maps draw.io shapes to Graphviz node shapes.
+var dotShapes = map[string]string{
+	string(ShapeRectangle):     "box",
+	string(ShapeRounded):       "box",
+	string(ShapeEllipse):       "ellipse",
+	string(ShapeRhombus):       "diamond",
+	string(ShapeParallelogram): "parallelogram",
+	string(ShapeCylinder):      "cylinder",
+	string(ShapeDocument):      "note",
+	string(ShapeHexagon):       "hexagon",
+	string(ShapeTriangle):      "triangle",
+	string(ShapeFolder):        "folder",
+	string(ShapeCloud):         "ellipse",
+	string(ShapeIsoDatabase):   "cylinder",
+	string(ShapeIsoCylinder):   "cylinder",
+}
+
+// dotNodeAttrs returns the attributes of a component's node.
+func dotNodeAttrs(comp model.Component) []string {
+	style := ParseStyle(NewDrawIOGenerator().BuildComponentStyle(comp))
+
+	shape, ok := dotShapes[style.Shape]
+	if !ok {
+		shape = "box3d"
+		if !ShapeType(style.Shape).IsIsometric() {
+			shape = "box"
+		}
+	}
+
+	var attrs []string
+	if comp.Label != "" {
+		attrs = append(attrs, "label="+dotID(comp.Label))
+	}
+	attrs = append(attrs, "shape="+shape)
+
+	var styles []string
+	if style.Shape == string(ShapeRounded) || style.Rounded {
+		styles = append(styles, "rounded")
+	}
+	if style.FillColor != "" {
+		styles = append(styles, "filled")
+	}
+	if style.Dashed {
+		styles = append(styles, "dashed")
+	}
+	if len(styles) > 0 {
+		attrs = append(attrs, "style="+dotID(strings.Join(styles, ",")))
+	}
+	if style.FillColor != "" {
+		attrs = append(attrs, "fillcolor="+dotID(style.FillColor))
+	}
+	if style.StrokeColor != "" {
+		attrs = append(attrs, "color="+dotID(style.StrokeColor))
+	}
+	if style.FontColor != "" {
+		attrs = append(attrs, "fontcolor="+dotID(style.FontColor))
+	}
+	if style.StrokeWidth > 0 {
+		attrs = append(attrs, fmt.Sprintf("penwidth=%d", style.StrokeWidth))
+	}
+	if style.FontStyle&FontStyleBold != 0 {
+		attrs = append(attrs, `fontname="Helvetica-Bold"`)
+	}
+	if comp.Description != "" {
+		attrs = append(attrs, "tooltip="+dotID(comp.Description))
+	}
+	return append(attrs, "type="+dotID(string(comp.Type)))
+}
+
+// dotArrows maps draw.io arrows to Graphviz arrow shapes.
+var dotArrows = map[string]string{
+	ArrowClassic: "normal",
+	ArrowBlock:   "normal",
+	ArrowOpen:    "vee",
+	ArrowDiamond: "diamond",
+	ArrowNone:    "none",
+	"oval":       "dot",
+	"box":        "box",
+}
+
+// dotEdgeAttrs returns the attributes of a connection's edge.
+func dotEdgeAttrs(conn model.Connection) []string {
+	style := ParseStyle(NewDrawIOGenerator().BuildEdgeStyle(conn))
+	head, tail := dotArrow(style.EndArrow), dotArrow(style.StartArrow)
+
+	var attrs []string
+	if conn.Label != "" {
+		attrs = append(attrs, "label="+dotID(conn.Label))
+	}
+	switch {
+	case tail != "none" && head == "none":
+		attrs = append(attrs, "dir=back")
+	case tail != "none":
+		attrs = append(attrs, "dir=both")
+	case head == "none":
+		attrs = append(attrs, "dir=none")
+	}
+	if tail != "none" && tail != "normal" {
+		attrs = append(attrs, "arrowtail="+tail)
+	}
+	if head != "none" && head != "normal" {
+		attrs = append(attrs, "arrowhead="+head)
+	}
+	if style.Dashed {
+		attrs = append(attrs, "style=dashed")
+	}
+	if style.StrokeColor != "" {
+		attrs = append(attrs, "color="+dotID(style.StrokeColor))
+	}
+	if style.StrokeWidth > 0 {
+		attrs = append(attrs, fmt.Sprintf("penwidth=%d", style.StrokeWidth))
+	}
+	return attrs
+}
+
+// dotArrow returns the Graphviz arrow for a draw.io arrow; no arrow is "none".
+func dotArrow(arrow string) string {
+	if arrow == "" {
+		return ArrowNone
+	}
+	if shape, ok := dotArrows[arrow]; ok {
+		return shape
+	}
+	return "normal"
+}
+
+// dotID quotes a DOT identifier.
+func dotID(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,86 @@
+package generator_test
+
+import (
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+func TestDOTGenerator(t *testing.T) {
+	t.Parallel()
+	gen := generator.NewDOTGenerator()
+	if gen.Format() != "dot" {
+		t.Errorf("Format() = %q, want dot", gen.Format())
+	}
+
+	diagram := &model.Diagram{
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend", Description: "Public \"v2\" API"},
+			{Name: "DB", Label: "Orders DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend"},
+			{Name: "User", Type: model.ComponentTypeUser, Style: "fillColor=#ffffff;strokeWidth=2"},
+			{Name: "Worker", Type: model.ComponentTypeService, Page: "Jobs"},
+		},
+		Connections: []model.Connection{
+			{Source: "User", Target: "API", Label: "calls"},
+			{Source: "API", Target: "DB", Direction: model.ConnectionDirectionBidirectional, Style: "dashed=1"},
+			{Source: "API", Target: "User", EndArrow: generator.ArrowNone},
+			{Source: "API", Target: "User", StartArrow: generator.ArrowDiamond, EndArrow: generator.ArrowNone},
+		},
+	}
+	data, err := gen.Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	out := string(data)
+
+	want := []string{
+		"digraph \"Architecture Diagram\" {\n\trankdir=TB;",
+		"\tsubgraph \"cluster_0\" {\n\t\tlabel=\"Backend\";\n\t\t\"API\" [shape=box, style=\"rounded,filled\", fillcolor=\"#dae8fc\", color=\"#6c8ebf\", fontname=\"Helvetica-Bold\", tooltip=\"Public \\\"v2\\\" API\", type=\"service\"];",
+		"\t\t\"DB\" [label=\"Orders DB\", shape=cylinder, style=\"filled\", fillcolor=\"#ffe6cc\", color=\"#d79b00\", type=\"database\"];\n\t}",
+		"\t\"User\" [shape=ellipse, style=\"filled\", fillcolor=\"#ffffff\", color=\"#9673a6\", penwidth=2, type=\"user\"];",
+		"\t\"User\" -> \"API\" [label=\"calls\"];",
+		"\t\"API\" -> \"DB\" [dir=both, style=dashed];",
+		"\t\"API\" -> \"User\" [dir=none];",
+		"\t\"API\" -> \"User\" [dir=back, arrowtail=diamond];",
+		"}\n\ndigraph \"Jobs\" {",
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("expected %q in:\n%s", w, out)
+		}
+	}
+	if strings.Index(out, "Architecture Diagram") > strings.Index(out, "Jobs") {
+		t.Errorf("expected the default page first:\n%s", out)
+	}
+
+	// A diagram with only named pages has no empty default graph.
+	data, err = gen.Generate(&model.Diagram{Components: []model.Component{{Name: "A", Type: model.ComponentTypeService, Page: "Only"}}})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if got := strings.Count(string(data), "digraph"); got != 1 {
+		t.Errorf("got %d graphs, want 1:\n%s", got, data)
+	}
+}
+
+func TestSplitPagesOrder(t *testing.T) {
+	t.Parallel()
+	diagram := &model.Diagram{
+		Components: []model.Component{
+			{Name: "C", Page: "Zeta"},
+			{Name: "A"},
+			{Name: "B", Page: "Alpha"},
+		},
+		Connections: []model.Connection{{Source: "A", Target: "D", Page: "Mid"}},
+	}
+	var names []string
+	for _, page := range generator.SplitPages(diagram) {
+		names = append(names, page.Name)
+	}
+	want := []string{generator.DefaultPageName, "Zeta", "Alpha", "Mid"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("pages = %v, want %v", names, want)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"diagram-gen/internal/model"
 )
 
+// DefaultPageName names the page holding the components without a page.
+const DefaultPageName = "Architecture Diagram"
+
 // DrawIOGenerator generates draw.io compatible diagrams.
 type DrawIOGenerator struct {
 	LayoutType string
@@ -44,20 +47,8 @@ func (g *DrawIOGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
 		layoutType = g.LayoutType
 	}
 
-	layoutEngine := layout.NewLayout(layoutType)
-	positions := layoutEngine.Calculate(diagram.Components, diagram.Connections)
-
-	intPositions := make(map[string]Position)
-	for name, pos := range positions {
-		intPositions[name] = Position{
-			X: int(pos.X),
-			Y: int(pos.Y),
-		}
-	}
-
-	swimlanes := BuildSwimlanes(diagram.Components, intPositions)
-
 	pages := g.BuildPages(diagram)
+	layoutOf := pageLayouts(diagram, layoutType)
 
 	var sb strings.Builder
 
@@ -66,7 +57,7 @@ func (g *DrawIOGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
 <mxfile host="app.diagrams.net">
 `)
 		for _, page := range pages {
-			pageXML := g.GeneratePageXML(page, swimlanes, intPositions)
+			pageXML := g.renderPage(diagram.Type, page, layoutOf)
 			var compressed []byte
 			var err error
 			if g.testMode {
@@ -80,7 +71,7 @@ func (g *DrawIOGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
 			fmt.Fprintf(&sb, `  <diagram name="%s">
     %s
   </diagram>
-`, page.Name, compressed)
+`, EscapeXML(page.Name), compressed)
 		}
 		sb.WriteString(`</mxfile>`)
 	} else {
@@ -88,8 +79,7 @@ func (g *DrawIOGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
 <mxfile host="app.diagrams.net">
 `)
 		for _, page := range pages {
-			pageXML := g.GeneratePageXML(page, swimlanes, intPositions)
-			sb.WriteString(pageXML)
+			sb.WriteString(g.renderPage(diagram.Type, page, layoutOf))
 		}
 		sb.WriteString(`</mxfile>`)
 	}
@@ -97,44 +87,96 @@ func (g *DrawIOGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
 	return []byte(sb.String()), nil
 }
 
-// BuildPages constructs pages from the diagram or uses pre-built pages.
-func (g *DrawIOGenerator) BuildPages(diagram *model.Diagram) []model.Page {
-	if len(diagram.Pages) > 0 {
-		return diagram.Pages
+// pageLayout returns the swimlanes and component positions of a page.
+type pageLayout func(page model.Page) ([]Swimlane, map[string]Position)
+
+// pageLayouts returns how the pages of a diagram are laid out. Pre-built
+// pages, such as the functions of a flowchart, are laid out on their own.
+// Otherwise every page shares the positions of one layout of the whole
+// diagram, so that a component sits at the same place whichever page it is
+// drawn on, and only the swimlanes are built per page.
+func pageLayouts(diagram *model.Diagram, layoutType string) pageLayout {
+	if diagram.Type == model.DiagramTypeFlowchart || len(diagram.Pages) > 0 {
+		return func(page model.Page) ([]Swimlane, map[string]Position) {
+			return LayoutPage(layoutType, page)
+		}
 	}
+	_, positions := LayoutPage(layoutType, model.Page{
+		Components:  diagram.Components,
+		Connections: diagram.Connections,
+	})
+	return func(page model.Page) ([]Swimlane, map[string]Position) {
+		return BuildSwimlanes(page.Components, positions), positions
+	}
+}
 
-	pageMap := make(map[string]*model.Page)
-	pageMap["default"] = &model.Page{Name: "Architecture Diagram"}
+// renderPage renders one page in the style of the diagram type. Network
+// diagrams group components into zones and subnets; every other type is laid
+// out by layoutOf.
+func (g *DrawIOGenerator) renderPage(diagramType model.DiagramType, page model.Page, layoutOf pageLayout) string {
+	if diagramType == model.DiagramTypeNetwork {
+		return g.GenerateNetworkPageXML(page)
+	}
+	swimlanes, positions := layoutOf(page)
+	return g.GeneratePageXML(page, swimlanes, positions)
+}
 
-	for _, comp := range diagram.Components {
-		pageName := comp.Page
-		if pageName == "" {
-			pageName = "default"
-		}
+// LayoutPage positions the components of a single page and builds its
+// swimlanes. Components with an explicit X or Y, such as those read from a
+// .drawio file, keep their position.
+func LayoutPage(layoutType string, page model.Page) ([]Swimlane, map[string]Position) {
+	layoutEngine := layout.NewLayout(layoutType)
+	positions := layoutEngine.Calculate(page.Components, page.Connections)
 
-		if _, exists := pageMap[pageName]; !exists {
-			pageMap[pageName] = &model.Page{Name: pageName}
+	intPositions := make(map[string]Position)
+	for name, pos := range positions {
+		intPositions[name] = Position{
+			X: int(pos.X),
+			Y: int(pos.Y),
 		}
-		pageMap[pageName].Components = append(pageMap[pageName].Components, comp)
 	}
-
-	for _, conn := range diagram.Connections {
-		pageName := conn.Page
-		if pageName == "" {
-			pageName = "default"
+	for _, comp := range page.Components {
+		if comp.X != 0 || comp.Y != 0 {
+			intPositions[comp.Name] = Position{X: comp.X, Y: comp.Y}
 		}
+	}
 
-		if _, exists := pageMap[pageName]; !exists {
-			pageMap[pageName] = &model.Page{Name: pageName}
-		}
-		pageMap[pageName].Connections = append(pageMap[pageName].Connections, conn)
+	return BuildSwimlanes(page.Components, intPositions), intPositions
+}
+
+// BuildPages constructs pages from the diagram or uses pre-built pages.
+func (g *DrawIOGenerator) BuildPages(diagram *model.Diagram) []model.Page {
+	return SplitPages(diagram)
+}
+
+// SplitPages returns the pre-built pages of a diagram, or else a page per
+// page name of its components and connections. The default page comes first
+// and the others follow in the order they first appear.
+func SplitPages(diagram *model.Diagram) []model.Page {
+	if len(diagram.Pages) > 0 {
+		return diagram.Pages
 	}
 
-	pages := make([]model.Page, 0, len(pageMap))
-	for _, page := range pageMap {
-		pages = append(pages, *page)
+	pages := []model.Page{{Name: DefaultPageName}}
+	index := map[string]int{"": 0}
+	page := func(name string) *model.Page {
+		i, exists := index[name]
+		if !exists {
+			i = len(pages)
+			index[name] = i
+			pages = append(pages, model.Page{Name: name})
+		}
+		return &pages[i]
 	}
 
+	for _, comp := range diagram.Components {
+		p := page(comp.Page)
+		p.Components = append(p.Components, comp)
+	}
+	for _, conn := range diagram.Connections {
+		p := page(conn.Page)
+		p.Connections = append(p.Connections, conn)
+	}
 	return pages
 }
 
@@ -150,7 +192,7 @@ func (g *DrawIOGenerator) GeneratePageXML(page model.Page, swimlanes []Swimlane,
       <root>
         <mxCell id="0" />
         <mxCell id="1" parent="0" />
-`, page.Name)
+`, EscapeXML(page.Name))
 
 	cellID := 2
 
@@ -175,7 +217,7 @@ func (g *DrawIOGenerator) GeneratePageXML(page model.Page, swimlanes []Swimlane,
 		fmt.Fprintf(&sb, `        <mxCell id="%d" value="%s" style="%s" vertex="1" parent="1">
           <mxGeometry x="%d" y="%d" width="%d" height="%d" as="geometry" />
         </mxCell>
-`, cellID, EscapeXML(comp.Name), shapeStyle, pos.X, pos.Y, width, height)
+`, cellID, EscapeXML(componentLabel(comp)), shapeStyle, pos.X, pos.Y, width, height)
 		cellID++
 	}
 
@@ -195,10 +237,10 @@ func (g *DrawIOGenerator) GeneratePageXML(page model.Page, swimlanes []Swimlane,
 		}
 
 		edgeStyle := g.BuildEdgeStyle(conn)
-		fmt.Fprintf(&sb, `        <mxCell id="%d" style="%s" edge="1" parent="1" source="%d" target="%d">
-          <mxGeometry as="geometry" />
+		fmt.Fprintf(&sb, `        <mxCell id="%d" value="%s" style="%s" edge="1" parent="1" source="%d" target="%d">
+          <mxGeometry relative="1" as="geometry" />
         </mxCell>
-`, cellID, edgeStyle, sourceID, targetID)
+`, cellID, EscapeXML(conn.Label), edgeStyle, sourceID, targetID)
 		cellID++
 	}
 
@@ -210,6 +252,14 @@ func (g *DrawIOGenerator) GeneratePageXML(page model.Page, swimlanes []Swimlane,
 	return sb.String()
 }
 
+// componentLabel returns the text displayed for a component.
+func componentLabel(comp model.Component) string {
+	if comp.Label != "" {
+		return comp.Label
+	}
+	return comp.Name
+}
+
 // BuildComponentStyle returns the draw.io style string for a component.
 func (g *DrawIOGenerator) BuildComponentStyle(comp model.Component) string {
 	var style Style
@@ -243,6 +293,15 @@ func (g *DrawIOGenerator) BuildComponentStyle(comp model.Component) string {
 	case model.ComponentTypeExternal:
 		style.FillColor = "#f5f5f5"
 		style.StrokeColor = "#666666"
+	case model.ComponentTypeProcess:
+		style.FillColor = "#dae8fc"
+		style.StrokeColor = "#6c8ebf"
+	case model.ComponentTypeDecision:
+		style.FillColor = "#fff2cc"
+		style.StrokeColor = "#d6b656"
+	case model.ComponentTypeTerminal:
+		style.FillColor = "#d5e8d4"
+		style.StrokeColor = "#82b366"
 	default:
 		style.FillColor = "#ffffff"
 		style.StrokeColor = "#000000"
@@ -272,6 +331,10 @@ func (g *DrawIOGenerator) BuildEdgeStyle(conn model.Connection) string {
 		style.EdgeStyle = conn.EdgeStyle
 	}
 
+	if conn.Inferred {
+		style.Dashed = true
+	}
+
 	if conn.StartArrow != "" {
 		style.StartArrow = conn.StartArrow
 	} else if conn.Direction == model.ConnectionDirectionBidirectional {
@@ -284,6 +347,10 @@ func (g *DrawIOGenerator) BuildEdgeStyle(conn model.Connection) string {
 		style.EndArrow = ArrowClassic
 	}
 
+	if conn.Style != "" {
+		style = MergeStyles(style, ParseStyle(conn.Style))
+	}
+
 	return style.String()
 }
 
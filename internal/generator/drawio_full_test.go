@@ -1,6 +1,7 @@
 package generator_test
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -85,6 +86,78 @@ func TestCalculateLayout(t *testing.T) {
 	}
 }
 
+func TestLayoutPageKeepsExplicitPositions(t *testing.T) {
+	t.Parallel()
+	page := model.Page{Components: []model.Component{
+		{Type: model.ComponentTypeService, Name: "Placed", Swimlane: "Lane", X: 640, Y: 480},
+		{Type: model.ComponentTypeService, Name: "Free", Swimlane: "Lane"},
+	}}
+
+	swimlanes, positions := generator.LayoutPage("grid", page)
+	if got := positions["Placed"]; got != (generator.Position{X: 640, Y: 480}) {
+		t.Errorf("Placed at %v, want {640 480}", got)
+	}
+	if got := positions["Free"]; got.X <= 0 || got.Y <= 0 {
+		t.Errorf("Free at %v, want a laid out position", got)
+	}
+	if len(swimlanes) != 1 || swimlanes[0].X+swimlanes[0].Width < 640 {
+		t.Errorf("swimlanes = %+v, want one around the placed component", swimlanes)
+	}
+}
+
+func TestGeneratePagePositions(t *testing.T) {
+	t.Parallel()
+	components := []model.Component{
+		{Type: model.ComponentTypeService, Name: "API"},
+		{Type: model.ComponentTypeService, Name: "Worker"},
+		{Type: model.ComponentTypeDatabase, Name: "Store", Page: "Data"},
+	}
+	connections := []model.Connection{
+		{Source: "API", Target: "Worker"},
+		{Source: "Worker", Target: "Store"},
+	}
+	page := model.Page{Name: "Steps", Components: components[:2], Connections: connections[:1]}
+
+	tests := []struct {
+		name    string
+		diagram *model.Diagram
+		// layout is the page whose layout places comp.
+		layout model.Page
+		comp   model.Component
+	}{
+		{
+			name:    "architecture pages share the whole layout",
+			diagram: &model.Diagram{Type: model.DiagramTypeArchitecture, Components: components, Connections: connections},
+			layout:  model.Page{Components: components, Connections: connections},
+			comp:    components[2],
+		},
+		{
+			name:    "flowchart pages are laid out alone",
+			diagram: &model.Diagram{Type: model.DiagramTypeFlowchart, Pages: []model.Page{page}},
+			layout:  page,
+			comp:    components[1],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g := generator.NewDrawIOGenerator()
+			out, err := g.Generate(tt.diagram)
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			_, positions := generator.LayoutPage(g.LayoutType, tt.layout)
+			pos := positions[tt.comp.Name]
+			want := fmt.Sprintf(`value="%s" style="%s" vertex="1" parent="1">
+          <mxGeometry x="%d" y="%d"`, tt.comp.Name, g.BuildComponentStyle(tt.comp), pos.X, pos.Y)
+			if !strings.Contains(string(out), want) {
+				t.Errorf("expected %s at %d,%d, got:\n%s", tt.comp.Name, pos.X, pos.Y, out)
+			}
+		})
+	}
+}
+
 func TestEscapeXML(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -260,3 +333,88 @@ func TestCalculateLayoutEdgeCases(t *testing.T) {
 		t.Errorf("layout length = %d, want 5", len(posMap))
 	}
 }
+
+func TestBuildEdgeStyleInferred(t *testing.T) {
+	t.Parallel()
+	g := generator.NewDrawIOGenerator()
+
+	declared := g.BuildEdgeStyle(model.Connection{Source: "A", Target: "B"})
+	if strings.Contains(declared, "dashed=1") {
+		t.Errorf("declared edge should not be dashed: %q", declared)
+	}
+
+	inferred := g.BuildEdgeStyle(model.Connection{Source: "A", Target: "B", Inferred: true})
+	if !strings.Contains(inferred, "dashed=1") {
+		t.Errorf("inferred edge should be dashed: %q", inferred)
+	}
+}
+
+func TestGenerateFlowchartLabels(t *testing.T) {
+	t.Parallel()
+	g := generator.NewDrawIOGenerator()
+	page := model.Page{
+		Name: "Handler",
+		Components: []model.Component{
+			{Name: "H/1", Label: "err != nil?", Type: model.ComponentTypeDecision},
+			{Name: "H/2", Label: "return err", Type: model.ComponentTypeTerminal},
+			{Name: "H/3", Label: "save(x)", Type: model.ComponentTypeProcess},
+		},
+		Connections: []model.Connection{
+			{Source: "H/1", Target: "H/2", Label: "yes"},
+			{Source: "H/1", Target: "H/3", Label: "no"},
+		},
+	}
+	diagram := &model.Diagram{
+		Type:        model.DiagramTypeFlowchart,
+		Components:  page.Components,
+		Connections: page.Connections,
+		Pages:       []model.Page{page},
+	}
+
+	data, err := g.Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	out := string(data)
+
+	for _, want := range []string{
+		`value="err != nil?" style="shape=rhombus;`,
+		`value="return err" style="shape=ellipse;`,
+		`value="save(x)" style="shape=rectangle;`,
+		`value="yes" style=`,
+		`value="no" style=`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	if strings.Contains(out, `value="H/1"`) {
+		t.Error("expected labels to replace component names")
+	}
+}
+
+func TestGenerateConnectionAttributes(t *testing.T) {
+	t.Parallel()
+	g := generator.NewDrawIOGenerator()
+
+	style := g.BuildEdgeStyle(model.Connection{Source: "A", Target: "B", Style: "dashed=1;strokeColor=#ff0000;curved=1"})
+	for _, want := range []string{"dashed=1", "strokeColor=#ff0000", "curved=1", "endArrow=classic"} {
+		if !strings.Contains(style, want) {
+			t.Errorf("edge style %q should contain %s", style, want)
+		}
+	}
+
+	diagram := &model.Diagram{
+		Components: []model.Component{{Name: "Orders"}, {Name: "OrderDB"}},
+		Connections: []model.Connection{
+			{Source: "Orders", Target: "OrderDB", Label: "SQL & more", Style: "dashed=1"},
+		},
+	}
+	data, err := g.Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(string(data), `value="SQL &amp; more" style="dashed=1;`) {
+		t.Errorf("expected the escaped label on the edge, got:\n%s", data)
+	}
+}
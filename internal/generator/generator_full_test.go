@@ -346,6 +346,37 @@ func TestCompressAndEncode(t *testing.T) {
 	}
 }
 
+func TestDecompressXML(t *testing.T) {
+	t.Parallel()
+	lockCompression(t)
+	xml := []byte(`<mxGraphModel><root><mxCell id="0" /></root></mxGraphModel>`)
+
+	compressed, err := generator.CompressXML(xml)
+	if err != nil {
+		t.Fatalf("CompressXML failed: %v", err)
+	}
+	got, err := generator.DecompressXML(compressed)
+	if err != nil {
+		t.Fatalf("DecompressXML failed: %v", err)
+	}
+	if !bytes.Equal(got, xml) {
+		t.Errorf("DecompressXML = %s, want %s", got, xml)
+	}
+
+	// draw.io deflates URL-encoded XML without a zlib header.
+	drawn := "UzV2zq1wL0osyPDNT0nNUTV2VTV2LsrPL4GwciucU3NyVI0MMlNUjV1UjYwMgFjVyA0iC2QglAI5aCYBAA=="
+	if got, err = generator.DecompressXML([]byte(drawn)); err != nil {
+		t.Fatalf("DecompressXML of a draw.io page failed: %v", err)
+	}
+	if !bytes.HasPrefix(got, []byte("<mxGraphModel")) {
+		t.Errorf("DecompressXML = %q, want an mxGraphModel", got)
+	}
+
+	if _, err := generator.DecompressXML([]byte("not base64!")); err == nil {
+		t.Error("expected an error for invalid base64")
+	}
+}
+
 func TestGetShapeStyle(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -403,6 +434,11 @@ func TestGetDefaultShapeForComponentType(t *testing.T) {
 		{"cache", generator.ShapeRounded},
 		{"user", generator.ShapeEllipse},
 		{"external", generator.ShapeDocument},
+		{"process", generator.ShapeRectangle},
+		{"decision", generator.ShapeRhombus},
+		{"terminal", generator.ShapeEllipse},
+		{"rhombus", generator.ShapeRhombus},
+		{"ellipse", generator.ShapeEllipse},
 		{"iso:server", generator.ShapeIsoServer},
 		{"iso:database", generator.ShapeIsoDatabase},
 		{"iso:container", generator.ShapeIsoContainer},
@@ -0,0 +1,292 @@
+package generator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"diagram-gen/internal/model"
+)
+
+// MermaidGenerator generates Mermaid flowcharts.
+type MermaidGenerator struct {
+	// Direction is the direction flowcharts are laid out in: TB, LR, BT or RL.
+	Direction string
+	// Markdown wraps each page in a ```mermaid block of a Markdown document.
+	Markdown bool
+}
+
+// NewMermaidGenerator creates a new MermaidGenerator with default settings.
+func NewMermaidGenerator() *MermaidGenerator {
+	return &MermaidGenerator{Direction: "TB"}
+}
+
+// Format returns the output format name.
+func (g *MermaidGenerator) Format() string {
+	return "mermaid"
+}
+
+// Generate writes a flowchart document per page, titled after it. Swimlanes
+// become subgraphs, component types pick the node shapes and custom colors
+// become style statements.
+func (g *MermaidGenerator) Generate(diagram *model.Diagram) ([]byte, error) {
+	pages := SplitPages(diagram)
+	var sb strings.Builder
+	written := 0
+	for i, page := range pages {
+		if len(page.Components) == 0 && len(page.Connections) == 0 && (written > 0 || i < len(pages)-1) {
+			continue
+		}
+		if written > 0 {
+			sb.WriteString("\n")
+		}
+		if g.Markdown {
+			sb.WriteString("```mermaid\n")
+		}
+		g.writePage(&sb, page)
+		if g.Markdown {
+			sb.WriteString("```\n")
+		}
+		written++
+	}
+	return []byte(sb.String()), nil
+}
+
+func (g *MermaidGenerator) writePage(sb *strings.Builder, page model.Page) {
+	fmt.Fprintf(sb, "---\ntitle: %s\n---\n", strconv.Quote(page.Name))
+	direction := g.Direction
+	if direction == "" {
+		direction = "TB"
+	}
+	fmt.Fprintf(sb, "flowchart %s\n", direction)
+
+	ids := newMermaidIDs()
+	var lanes []string
+	byLane := make(map[string][]model.Component)
+	for _, comp := range page.Components {
+		ids.of(comp.Name)
+		if _, seen := byLane[comp.Swimlane]; !seen && comp.Swimlane != "" {
+			lanes = append(lanes, comp.Swimlane)
+		}
+		byLane[comp.Swimlane] = append(byLane[comp.Swimlane], comp)
+	}
+
+	for _, lane := range lanes {
+		fmt.Fprintf(sb, "    subgraph %s [%s]\n", ids.unique(lane), mermaidText(lane))
+		for _, comp := range byLane[lane] {
+			fmt.Fprintf(sb, "        %s\n", mermaidNode(ids.of(comp.Name), comp))
+		}
+		sb.WriteString("    end\n")
+	}
+	for _, comp := range byLane[""] {
+		fmt.Fprintf(sb, "    %s\n", mermaidNode(ids.of(comp.Name), comp))
+	}
+
+	var linkStyles []string
+	for i, conn := range page.Connections {
+		source, target, link, color := mermaidLink(conn)
+		fmt.Fprintf(sb, "    %s %s", ids.of(source), link)
+		if conn.Label != "" {
+			fmt.Fprintf(sb, "|%s|", mermaidText(conn.Label))
+		}
+		fmt.Fprintf(sb, " %s\n", ids.of(target))
+		if color != "" {
+			linkStyles = append(linkStyles, fmt.Sprintf("    linkStyle %d stroke:%s\n", i, color))
+		}
+	}
+
+	for _, comp := range page.Components {
+		if props := mermaidStyle(comp.Style); props != "" {
+			fmt.Fprintf(sb, "    style %s %s\n", ids.of(comp.Name), props)
+		}
+	}
+	for _, line := range linkStyles {
+		sb.WriteString(line)
+	}
+}
+
+// mermaidShapes maps component types to the opening and closing of their
+// node shapes. Types sharing a shape with another also get a class named
+// after them, so that the flowchart reads back into the same types.
+var mermaidShapes = map[model.ComponentType][2]string{
+	model.ComponentTypeService:  {"([", "])"},
+	model.ComponentTypeDatabase: {"[(", ")]"},
+	model.ComponentTypeQueue:    {"[/", "/]"},
+	model.ComponentTypeCache:    {"[(", ")]"},
+	model.ComponentTypeAPI:      {"(", ")"},
+	model.ComponentTypeUser:     {"((", "))"},
+	model.ComponentTypeExternal: {">", "]"},
+	model.ComponentTypeStorage:  {"[(", ")]"},
+	model.ComponentTypeGateway:  {"{{", "}}"},
+	model.ComponentTypeProcess:  {"[[", "]]"},
+	model.ComponentTypeDecision: {"{", "}"},
+	model.ComponentTypeTerminal: {"(((", ")))"},
+}
+
+// mermaidClassed lists the types whose shape alone reads back as another type.
+var mermaidClassed = map[model.ComponentType]bool{
+	model.ComponentTypeService:  true,
+	model.ComponentTypeCache:    true,
+	model.ComponentTypeAPI:      true,
+	model.ComponentTypeExternal: true,
+	model.ComponentTypeStorage:  true,
+}
+
+// mermaidNode returns the declaration of a component's node.
+func mermaidNode(id string, comp model.Component) string {
+	label := comp.Label
+	if label == "" {
+		label = comp.Name
+	}
+	shape, ok := mermaidShapes[comp.Type]
+	if !ok {
+		shape = [2]string{"[", "]"}
+	}
+	node := id + shape[0] + mermaidText(label) + shape[1]
+	if mermaidClassed[comp.Type] {
+		node += ":::" + string(comp.Type)
+	}
+	return node
+}
+
+// mermaidLink returns the ends of a connection, the link drawn between them
+// and the color of its line. A connection with only a start arrow is drawn
+// from its target, since Mermaid links cannot point backwards alone.
+func mermaidLink(conn model.Connection) (source, target, link, color string) {
+	style := ParseStyle(NewDrawIOGenerator().BuildEdgeStyle(conn))
+	source, target = conn.Source, conn.Target
+	head, tail := style.EndArrow, style.StartArrow
+	if head == "" {
+		head = ArrowNone
+	}
+	if tail == "" {
+		tail = ArrowNone
+	}
+	if head == ArrowNone && tail != ArrowNone {
+		source, target, head, tail = target, source, tail, ArrowNone
+	}
+
+	var start, end string
+	switch tail {
+	case ArrowNone:
+	case "oval":
+		start = "o"
+	case "cross":
+		start = "x"
+	default:
+		start = "<"
+	}
+	switch head {
+	case ArrowNone:
+	case "oval":
+		end = "o"
+	case "cross":
+		end = "x"
+	default:
+		end = ">"
+	}
+	// Both ends of a Mermaid link have the same kind of arrow.
+	if mirror := strings.NewReplacer("<", ">").Replace(start); start != "" && mirror != end {
+		start = ""
+	}
+
+	body := "--"
+	switch {
+	case style.Dashed:
+		body = "-.-"
+	case style.StrokeWidth >= 3:
+		body = "=="
+	}
+	if end == "" && body != "-.-" {
+		body += body[:1]
+	}
+	return source, target, start + body + end, style.StrokeColor
+}
+
+// mermaidStyle translates the colors, line width and dashes of a draw.io
+// style into the CSS properties of a style statement.
+func mermaidStyle(style string) string {
+	var props []string
+	for _, setting := range strings.Split(style, ";") {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok || value == "" || strings.ContainsAny(value, ",;") {
+			continue
+		}
+		switch key {
+		case "fillColor":
+			props = append(props, "fill:"+value)
+		case "strokeColor":
+			props = append(props, "stroke:"+value)
+		case "fontColor":
+			props = append(props, "color:"+value)
+		case "strokeWidth":
+			props = append(props, "stroke-width:"+value+"px")
+		case "fontSize":
+			props = append(props, "font-size:"+value+"px")
+		case "dashed":
+			if value == "1" {
+				props = append(props, "stroke-dasharray:5 5")
+			}
+		}
+	}
+	return strings.Join(props, ",")
+}
+
+// mermaidText quotes a label, writing quotes, pipes and line breaks as
+// Mermaid entity codes and HTML breaks.
+func mermaidText(s string) string {
+	s = strings.ReplaceAll(s, "#", "#35;")
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "|", "#124;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
+
+// mermaidKeywords lists the words that cannot be node ids.
+var mermaidKeywords = map[string]bool{
+	"end": true, "graph": true, "flowchart": true, "subgraph": true,
+	"style": true, "class": true, "classDef": true, "linkStyle": true,
+	"click": true, "direction": true, "default": true,
+}
+
+// mermaidIDs hands out node and subgraph ids. Component names made of
+// letters, digits and underscores are their own ids; others have the runes
+// Mermaid cannot read replaced and are numbered when they collide.
+type mermaidIDs struct {
+	byName map[string]string
+	used   map[string]bool
+}
+
+func newMermaidIDs() *mermaidIDs {
+	return &mermaidIDs{byName: make(map[string]string), used: make(map[string]bool)}
+}
+
+// of returns the id of a component name.
+func (m *mermaidIDs) of(name string) string {
+	if id, ok := m.byName[name]; ok {
+		return id
+	}
+	id := m.unique(name)
+	m.byName[name] = id
+	return id
+}
+
+// unique returns a new id for a name.
+func (m *mermaidIDs) unique(name string) string {
+	base := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if base == "" || mermaidKeywords[base] {
+		base = "n_" + base
+	}
+	id := base
+	for i := 2; m.used[id]; i++ {
+		id = fmt.Sprintf("%s_%d", base, i)
+	}
+	m.used[id] = true
+	return id
+}
@@ -0,0 +1,70 @@
+package generator_test
+
+import (
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+func TestMermaidGenerator(t *testing.T) {
+	t.Parallel()
+	gen := generator.NewMermaidGenerator()
+	if gen.Format() != "mermaid" {
+		t.Errorf("Format() = %q, want mermaid", gen.Format())
+	}
+
+	diagram := &model.Diagram{
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend"},
+			{Name: "DB", Label: "Orders \"main\" DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend"},
+			{Name: "Sessions", Type: model.ComponentTypeCache, Style: "fillColor=#ffffff;strokeWidth=2"},
+			{Name: "end", Type: model.ComponentTypeUser},
+			{Name: "billing.v1", Type: model.ComponentTypeExternal},
+			{Name: "Worker", Type: model.ComponentTypeProcess, Page: "Jobs"},
+		},
+		Connections: []model.Connection{
+			{Source: "end", Target: "API", Label: "calls | retries"},
+			{Source: "API", Target: "DB", Direction: model.ConnectionDirectionBidirectional, Style: "dashed=1"},
+			{Source: "API", Target: "Sessions", EndArrow: generator.ArrowNone, Style: "strokeWidth=3"},
+			{Source: "billing.v1", Target: "API", StartArrow: generator.ArrowClassic, EndArrow: generator.ArrowNone, Style: "strokeColor=#ff0000"},
+			{Source: "API", Target: "billing.v1", EndArrow: "oval"},
+		},
+	}
+	data, err := gen.Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	out := string(data)
+
+	want := []string{
+		"---\ntitle: \"Architecture Diagram\"\n---\nflowchart TB\n",
+		"    subgraph Backend [\"Backend\"]\n        API([\"API\"]):::service\n        DB[(\"Orders #quot;main#quot; DB\")]\n    end\n",
+		"    Sessions[(\"Sessions\")]:::cache\n",
+		"    n_end((\"end\"))\n",
+		"    billing_v1>\"billing.v1\"]:::external\n",
+		"    n_end -->|\"calls #124; retries\"| API\n",
+		"    API <-.-> DB\n",
+		"    API === Sessions\n",
+		"    API --> billing_v1\n",
+		"    API --o billing_v1\n",
+		"    style Sessions fill:#ffffff,stroke-width:2px\n",
+		"    linkStyle 3 stroke:#ff0000\n",
+		"\n---\ntitle: \"Jobs\"\n---\nflowchart TB\n    Worker[[\"Worker\"]]\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("expected %q in:\n%s", w, out)
+		}
+	}
+
+	gen.Markdown = true
+	data, err = gen.Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if got := strings.Count(string(data), "```mermaid\n---\ntitle:"); got != 2 {
+		t.Errorf("got %d Mermaid blocks, want one per page:\n%s", got, data)
+	}
+}
@@ -0,0 +1,272 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"diagram-gen/internal/model"
+)
+
+const (
+	networkNodeWidth  = 120
+	networkNodeHeight = 80
+	networkCellWidth  = 160
+	networkCellHeight = 130
+	networkColumns    = 3
+	networkPadding    = 20
+	networkHeader     = 30
+	networkMargin     = 40
+)
+
+// networkContainer is a zone or subnet drawn around its components.
+// Zones may contain nested zones and subnets; the root holds everything
+// that is not in a zone and is not drawn itself.
+type networkContainer struct {
+	Name       string
+	CIDR       string
+	Subnet     bool
+	Components []model.Component
+	Children   []*networkContainer
+
+	// Horizontal lays children out side by side instead of stacked.
+	Horizontal bool
+	X, Y       int
+	Width      int
+	Height     int
+}
+
+func (c *networkContainer) child(name string, subnet bool) *networkContainer {
+	for _, child := range c.Children {
+		if child.Name == name && child.Subnet == subnet {
+			return child
+		}
+	}
+	child := &networkContainer{Name: name, Subnet: subnet}
+	c.Children = append(c.Children, child)
+	return child
+}
+
+// label is the container title, with its address range when one is known.
+func (c *networkContainer) label() string {
+	if c.CIDR == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s (%s)", c.Name, c.CIDR)
+}
+
+// buildNetworkZones nests the components of a network page into zones and
+// subnets. A zone such as "cloud/dmz" becomes a dmz container inside a cloud
+// container. Containers keep the order their first component appeared in.
+func buildNetworkZones(components []model.Component) *networkContainer {
+	root := &networkContainer{Horizontal: true}
+
+	for _, comp := range components {
+		container := root
+		for _, zone := range strings.Split(comp.Zone, "/") {
+			if zone = strings.TrimSpace(zone); zone != "" {
+				container = container.child(zone, false)
+			}
+		}
+		if comp.Subnet != "" {
+			container = container.child(comp.Subnet, true)
+		}
+		if container.CIDR == "" && container != root {
+			container.CIDR = comp.CIDR
+		}
+		container.Components = append(container.Components, comp)
+	}
+
+	root.measure()
+	return root
+}
+
+// measure sizes a container to fit its component grid above its children.
+func (c *networkContainer) measure() {
+	gridWidth, gridHeight := 0, 0
+	if n := len(c.Components); n > 0 {
+		cols := min(n, networkColumns)
+		rows := (n + networkColumns - 1) / networkColumns
+		gridWidth = cols*networkCellWidth - (networkCellWidth - networkNodeWidth)
+		gridHeight = rows*networkCellHeight - (networkCellHeight - networkNodeHeight)
+	}
+
+	childWidth, childHeight := 0, 0
+	for i, child := range c.Children {
+		child.measure()
+		if c.Horizontal {
+			if i > 0 {
+				childWidth += networkMargin
+			}
+			childWidth += child.Width
+			childHeight = max(childHeight, child.Height)
+		} else {
+			if i > 0 {
+				childHeight += networkPadding
+			}
+			childHeight += child.Height
+			childWidth = max(childWidth, child.Width)
+		}
+	}
+
+	gap := 0
+	if gridHeight > 0 && childHeight > 0 {
+		gap = networkMargin
+	}
+	c.Width = max(gridWidth, childWidth) + 2*networkPadding
+	c.Height = networkHeader + gridHeight + gap + childHeight + 2*networkPadding
+
+	offsetX, offsetY := networkPadding, networkHeader+networkPadding+gridHeight+gap
+	for _, child := range c.Children {
+		child.X, child.Y = offsetX, offsetY
+		if c.Horizontal {
+			offsetX += child.Width + networkMargin
+		} else {
+			offsetY += child.Height + networkPadding
+		}
+	}
+}
+
+// componentPosition returns the position of the i-th component relative to its container.
+func (c *networkContainer) componentPosition(i int) Position {
+	return Position{
+		X: networkPadding + (i%networkColumns)*networkCellWidth,
+		Y: networkHeader + networkPadding + (i/networkColumns)*networkCellHeight,
+	}
+}
+
+// GenerateNetworkPageXML renders a network page with zones and subnets as
+// nested containers, isometric device shapes and protocol:port edge labels.
+func (g *DrawIOGenerator) GenerateNetworkPageXML(page model.Page) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, `  <diagram name="%s">
+    <mxGraphModel dx="1200" dy="800" grid="1" gridSize="10" guides="1" tooltips="1" connect="1" arrows="1" fold="1" page="1" pageScale="1" pageWidth="1200" pageHeight="900" math="0" shadow="0">
+      <root>
+        <mxCell id="0" />
+        <mxCell id="1" parent="0" />
+`, EscapeXML(page.Name))
+
+	root := buildNetworkZones(page.Components)
+	root.X, root.Y = networkMargin-networkPadding, networkMargin-networkHeader-networkPadding
+
+	cellID := 2
+	compIDs := make(map[string]int)
+	byName := make(map[string]model.Component)
+	g.writeNetworkContainer(&sb, root, 1, &cellID, compIDs, byName)
+
+	for _, conn := range page.Connections {
+		sourceID, ok1 := compIDs[conn.Source]
+		targetID, ok2 := compIDs[conn.Target]
+		if !ok1 || !ok2 {
+			continue
+		}
+
+		fmt.Fprintf(&sb, `        <mxCell id="%d" value="%s" style="%s" edge="1" parent="1" source="%d" target="%d">
+          <mxGeometry relative="1" as="geometry" />
+        </mxCell>
+`, cellID, EscapeXML(NetworkEdgeLabel(conn, byName[conn.Target])), g.BuildEdgeStyle(conn), sourceID, targetID)
+		cellID++
+	}
+
+	sb.WriteString(`      </root>
+    </mxGraphModel>
+  </diagram>
+`)
+
+	return sb.String()
+}
+
+// writeNetworkContainer writes a container cell, then its components and
+// nested containers with coordinates relative to it. The root is not drawn
+// and places its contents directly on the page.
+func (g *DrawIOGenerator) writeNetworkContainer(sb *strings.Builder, c *networkContainer, parent int, cellID *int, compIDs map[string]int, byName map[string]model.Component) {
+	id := parent
+	if c.Name != "" {
+		id = *cellID
+		*cellID++
+		fmt.Fprintf(sb, `        <mxCell id="%d" value="%s" style="%s" vertex="1" parent="%d">
+          <mxGeometry x="%d" y="%d" width="%d" height="%d" as="geometry" />
+        </mxCell>
+`, id, EscapeXML(c.label()), networkContainerStyle(c.Subnet), parent, c.X, c.Y, c.Width, c.Height)
+	}
+
+	for i, comp := range c.Components {
+		pos := c.componentPosition(i)
+		if c.Name == "" {
+			pos.X += c.X
+			pos.Y += c.Y
+		}
+
+		compIDs[comp.Name] = *cellID
+		byName[comp.Name] = comp
+		fmt.Fprintf(sb, `        <mxCell id="%d" value="%s" style="%s" vertex="1" parent="%d">
+          <mxGeometry x="%d" y="%d" width="%d" height="%d" as="geometry" />
+        </mxCell>
+`, *cellID, EscapeXML(componentLabel(comp)), g.BuildComponentStyle(networkComponent(comp)), id, pos.X, pos.Y, networkNodeWidth, networkNodeHeight)
+		*cellID++
+	}
+
+	for _, child := range c.Children {
+		if c.Name == "" {
+			child.X += c.X
+			child.Y += c.Y
+		}
+		g.writeNetworkContainer(sb, child, id, cellID, compIDs, byName)
+	}
+}
+
+func networkContainerStyle(subnet bool) string {
+	if subnet {
+		return "swimlane;startSize=30;horizontal=1;whiteSpace=wrap;html=1;container=1;collapsible=0;dashed=1;fillColor=#dae8fc;strokeColor=#6c8ebf;"
+	}
+	return "swimlane;startSize=30;horizontal=1;whiteSpace=wrap;html=1;container=1;collapsible=0;fillColor=#f5f5f5;strokeColor=#666666;fontStyle=1;"
+}
+
+// networkComponent gives a component without an explicit shape the
+// isometric shape that fits its type in a network diagram.
+func networkComponent(comp model.Component) model.Component {
+	if comp.Shape != "" {
+		return comp
+	}
+
+	switch comp.Type {
+	case model.ComponentTypeService, model.ComponentTypeAPI:
+		comp.Shape = model.ShapeTypeIsoServer
+	case model.ComponentTypeDatabase, model.ComponentTypeStorage:
+		comp.Shape = model.ShapeTypeIsoDatabase
+	case model.ComponentTypeQueue, model.ComponentTypeCache:
+		comp.Shape = model.ShapeTypeIsoCube
+	case model.ComponentTypeUser, model.ComponentTypeExternal:
+		comp.Shape = model.ShapeTypeIsoCloud
+	default:
+		comp.Shape = model.ShapeTypeIsoNetwork
+	}
+	return comp
+}
+
+// NetworkEdgeLabel returns the protocol:port label of a connection. The
+// connection's own port and protocol win over those the target listens on,
+// and a declared label is kept in front of them.
+func NetworkEdgeLabel(conn model.Connection, target model.Component) string {
+	protocol, port := conn.Protocol, conn.Port
+	if protocol == "" && port == "" {
+		protocol, port = target.Protocol, target.Port
+	}
+
+	endpoint := protocol
+	if port != "" {
+		if endpoint != "" {
+			endpoint += ":"
+		}
+		endpoint += port
+	}
+
+	switch {
+	case conn.Label == "":
+		return endpoint
+	case endpoint == "":
+		return conn.Label
+	default:
+		return fmt.Sprintf("%s (%s)", conn.Label, endpoint)
+	}
+}
@@ -0,0 +1,134 @@
+package generator_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/model"
+)
+
+type mxCell struct {
+	ID     string `xml:"id,attr"`
+	Value  string `xml:"value,attr"`
+	Style  string `xml:"style,attr"`
+	Parent string `xml:"parent,attr"`
+	Source string `xml:"source,attr"`
+	Target string `xml:"target,attr"`
+}
+
+func networkCells(t *testing.T, data []byte) map[string]mxCell {
+	t.Helper()
+	var file struct {
+		Cells []mxCell `xml:"diagram>mxGraphModel>root>mxCell"`
+	}
+	if err := xml.Unmarshal(data, &file); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+
+	byValue := make(map[string]mxCell)
+	for _, cell := range file.Cells {
+		byValue[cell.Value] = cell
+	}
+	return byValue
+}
+
+func TestGenerateNetworkZones(t *testing.T) {
+	t.Parallel()
+	diagram := &model.Diagram{
+		Type: model.DiagramTypeNetwork,
+		Components: []model.Component{
+			{Name: "Internet", Type: model.ComponentTypeExternal},
+			{Name: "LB", Type: model.ComponentTypeGateway, Zone: "vpc/dmz", CIDR: "10.0.0.0/24", Port: "443", Protocol: "https"},
+			{Name: "API", Type: model.ComponentTypeService, Zone: "vpc/private", Subnet: "app", CIDR: "10.0.1.0/24", Port: "8080"},
+			{Name: "OrderDB", Type: model.ComponentTypeDatabase, Zone: "vpc/private", Subnet: "data", Port: "5432", Protocol: "tcp"},
+		},
+		Connections: []model.Connection{
+			{Source: "Internet", Target: "LB"},
+			{Source: "LB", Target: "API"},
+			{Source: "API", Target: "OrderDB", Label: "SQL"},
+			{Source: "API", Target: "OrderDB", Protocol: "tcp", Port: "6432"},
+		},
+	}
+
+	data, err := generator.NewDrawIOGenerator().Generate(diagram)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	cells := networkCells(t, data)
+
+	parents := map[string]string{
+		"Internet":          "1",
+		"vpc":               "1",
+		"dmz (10.0.0.0/24)": cells["vpc"].ID,
+		"private":           cells["vpc"].ID,
+		"app (10.0.1.0/24)": cells["private"].ID,
+		"data":              cells["private"].ID,
+		"LB":                cells["dmz (10.0.0.0/24)"].ID,
+		"API":               cells["app (10.0.1.0/24)"].ID,
+		"OrderDB":           cells["data"].ID,
+		"https:443":         "1",
+		"8080":              "1",
+		"SQL (tcp:5432)":    "1",
+		"tcp:6432":          "1",
+	}
+	for value, parent := range parents {
+		cell, ok := cells[value]
+		if !ok {
+			t.Errorf("expected a cell with value %q", value)
+			continue
+		}
+		if cell.Parent != parent {
+			t.Errorf("%q has parent %s, want %s", value, cell.Parent, parent)
+		}
+	}
+
+	if !strings.Contains(cells["private"].Style, "container=1") {
+		t.Errorf("zones should be containers: %q", cells["private"].Style)
+	}
+	if !strings.Contains(cells["data"].Style, "dashed=1") {
+		t.Errorf("subnets should be dashed: %q", cells["data"].Style)
+	}
+
+	wantShapes := map[string]string{
+		"Internet": string(generator.ShapeIsoCloud),
+		"LB":       string(generator.ShapeIsoNetwork),
+		"API":      string(generator.ShapeIsoServer),
+		"OrderDB":  string(generator.ShapeIsoDatabase),
+	}
+	for name, shape := range wantShapes {
+		if !strings.Contains(cells[name].Style, "shape="+shape+";") {
+			t.Errorf("%s style = %q, want shape %s", name, cells[name].Style, shape)
+		}
+	}
+}
+
+func TestNetworkEdgeLabel(t *testing.T) {
+	t.Parallel()
+	target := model.Component{Name: "DB", Port: "5432", Protocol: "tcp"}
+
+	tests := []struct {
+		name   string
+		conn   model.Connection
+		target model.Component
+		want   string
+	}{
+		{"from target", model.Connection{}, target, "tcp:5432"},
+		{"connection wins", model.Connection{Protocol: "udp", Port: "53"}, target, "udp:53"},
+		{"port only", model.Connection{Port: "9000"}, target, "9000"},
+		{"protocol only", model.Connection{Protocol: "grpc"}, model.Component{}, "grpc"},
+		{"with label", model.Connection{Label: "reads"}, target, "reads (tcp:5432)"},
+		{"label only", model.Connection{Label: "reads"}, model.Component{}, "reads"},
+		{"nothing", model.Connection{}, model.Component{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := generator.NetworkEdgeLabel(tt.conn, tt.target); got != tt.want {
+				t.Errorf("NetworkEdgeLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
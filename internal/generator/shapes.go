@@ -138,6 +138,16 @@ func GetDefaultShapeForComponentType(compType string) ShapeType {
 		return ShapeEllipse
 	case "external":
 		return ShapeDocument
+	case "process":
+		return ShapeRectangle
+	case "decision", "rhombus":
+		return ShapeRhombus
+	case "terminal", "ellipse":
+		return ShapeEllipse
+	case "rounded":
+		return ShapeRounded
+	case "cylinder":
+		return ShapeCylinder
 	case "iso:server":
 		return ShapeIsoServer
 	case "iso:database":
@@ -339,6 +339,18 @@ func MergeStyles(base, override Style) Style {
 	if override.VerticalAlign != "" {
 		base.VerticalAlign = override.VerticalAlign
 	}
+	if override.EdgeStyle != "" {
+		base.EdgeStyle = override.EdgeStyle
+	}
+	if override.StartArrow != "" {
+		base.StartArrow = override.StartArrow
+	}
+	if override.EndArrow != "" {
+		base.EndArrow = override.EndArrow
+	}
+	if override.Curved {
+		base.Curved = true
+	}
 
 	return base
 }
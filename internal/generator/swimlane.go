@@ -96,7 +96,7 @@ func GenerateSwimlaneXML(swimlanes []Swimlane, cellID *int) string {
 		fmt.Fprintf(&sb, `        <mxCell id="%d" value="%s" style="shape=swimlane;horizontal=1;whiteSpace=wrap;html=1;fillColor=#f5f5f5;strokeColor=#666666;" vertex="1" parent="1">
           <mxGeometry x="%d" y="%d" width="%d" height="%d" as="geometry"/>
         </mxCell>
-`, *cellID, sl.Name, sl.X, sl.Y, sl.Width, sl.Height)
+`, *cellID, EscapeXML(sl.Name), sl.X, sl.Y, sl.Width, sl.Height)
 		*cellID++
 	}
 
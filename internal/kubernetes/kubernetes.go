@@ -0,0 +1,413 @@
+// Package kubernetes imports the deployed topology described by Kubernetes
+// manifests as diagram components.
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"diagram-gen/internal/model"
+)
+
+// Kinds of the resources that become components. Other kinds are skipped.
+const (
+	KindDeployment  = "Deployment"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+	KindService     = "Service"
+	KindIngress     = "Ingress"
+	KindConfigMap   = "ConfigMap"
+)
+
+// DefaultNamespace is the namespace of resources that do not name one.
+const DefaultNamespace = "default"
+
+// Resource is the part of a manifest the diagram is built from. Resources
+// are read one file at a time and resolved against each other by Build.
+type Resource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Line is where the resource starts in its file.
+	Line int `json:"line,omitempty"`
+	// Selector holds the pod labels a Service routes to.
+	Selector map[string]string `json:"selector,omitempty"`
+	// PodLabels holds the labels of the pods a workload runs.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+	// Claims reports whether a workload mounts persistent volume claims.
+	Claims bool `json:"claims,omitempty"`
+	// Services lists the Services an Ingress sends traffic to.
+	Services []string `json:"services,omitempty"`
+	// ConfigMaps lists the ConfigMaps a workload reads.
+	ConfigMaps []string `json:"configMaps,omitempty"`
+}
+
+// ComponentName returns the name of the component of a resource, such as
+// shop/deployment/orders. Kubernetes names are only unique per kind and
+// namespace, and a Deployment usually shares its name with its Service.
+func ComponentName(namespace, kind, name string) string {
+	return namespace + "/" + strings.ToLower(kind) + "/" + name
+}
+
+// IsManifestFile reports whether a path names a YAML file, the format
+// manifests are read in.
+func IsManifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// IsManifest reports whether data holds a Kubernetes manifest: a YAML
+// document with apiVersion and kind.
+func IsManifest(data []byte) bool {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var header struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+		}
+		if err := dec.Decode(&header); err != nil {
+			return false
+		}
+		if header.APIVersion != "" && header.Kind != "" {
+			return true
+		}
+	}
+}
+
+// object is a manifest document.
+type object struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec  yaml.Node   `yaml:"spec"`
+	Items []yaml.Node `yaml:"items"`
+}
+
+type workloadSpec struct {
+	Template struct {
+		Metadata struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"metadata"`
+		Spec podSpec `yaml:"spec"`
+	} `yaml:"template"`
+	VolumeClaimTemplates []yaml.Node `yaml:"volumeClaimTemplates"`
+}
+
+type podSpec struct {
+	Volumes []struct {
+		ConfigMap *struct {
+			Name string `yaml:"name"`
+		} `yaml:"configMap"`
+		PersistentVolumeClaim *yaml.Node `yaml:"persistentVolumeClaim"`
+	} `yaml:"volumes"`
+	Containers     []container `yaml:"containers"`
+	InitContainers []container `yaml:"initContainers"`
+}
+
+type container struct {
+	EnvFrom []struct {
+		ConfigMapRef *struct {
+			Name string `yaml:"name"`
+		} `yaml:"configMapRef"`
+	} `yaml:"envFrom"`
+	Env []struct {
+		ValueFrom *struct {
+			ConfigMapKeyRef *struct {
+				Name string `yaml:"name"`
+			} `yaml:"configMapKeyRef"`
+		} `yaml:"valueFrom"`
+	} `yaml:"env"`
+}
+
+type serviceSpec struct {
+	Selector map[string]string `yaml:"selector"`
+}
+
+type ingressSpec struct {
+	DefaultBackend backend `yaml:"defaultBackend"`
+	Backend        backend `yaml:"backend"`
+	Rules          []struct {
+		HTTP *struct {
+			Paths []struct {
+				Backend backend `yaml:"backend"`
+			} `yaml:"paths"`
+		} `yaml:"http"`
+	} `yaml:"rules"`
+}
+
+// backend is an Ingress backend in the networking.k8s.io/v1 form or the
+// serviceName form of older API versions.
+type backend struct {
+	Service *struct {
+		Name string `yaml:"name"`
+	} `yaml:"service"`
+	ServiceName string `yaml:"serviceName"`
+}
+
+func (b backend) service() string {
+	if b.Service != nil {
+		return b.Service.Name
+	}
+	return b.ServiceName
+}
+
+// Load reads the resources of a manifest file, which may hold several
+// documents separated by --- and List objects.
+func Load(path string, data []byte) ([]Resource, error) {
+	var resources []Resource
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+		}
+
+		found, err := readObject(&doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+		}
+		resources = append(resources, found...)
+	}
+}
+
+// readObject reads the resources of a document, recursing into List items.
+func readObject(node *yaml.Node) ([]Resource, error) {
+	var obj object
+	if err := node.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(obj.Kind, "List") {
+		var resources []Resource
+		for i := range obj.Items {
+			found, err := readObject(&obj.Items[i])
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, found...)
+		}
+		return resources, nil
+	}
+
+	res := Resource{
+		Kind:      obj.Kind,
+		Namespace: obj.Metadata.Namespace,
+		Name:      obj.Metadata.Name,
+		Line:      node.Line,
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		res.Line = node.Content[0].Line
+	}
+	if res.Namespace == "" {
+		res.Namespace = DefaultNamespace
+	}
+
+	var err error
+	switch obj.Kind {
+	case KindDeployment, KindStatefulSet, KindDaemonSet:
+		err = res.readWorkload(&obj.Spec)
+	case KindService:
+		var spec serviceSpec
+		err = decodeSpec(&obj.Spec, &spec)
+		res.Selector = spec.Selector
+	case KindIngress:
+		err = res.readIngress(&obj.Spec)
+	case KindConfigMap:
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %s %s: %w", res.Line, obj.Kind, res.Name, err)
+	}
+	if res.Name == "" {
+		return nil, fmt.Errorf("line %d: %s has no metadata.name", res.Line, obj.Kind)
+	}
+	return []Resource{res}, nil
+}
+
+// decodeSpec decodes a spec, which may be missing.
+func decodeSpec(node *yaml.Node, spec any) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	return node.Decode(spec)
+}
+
+func (r *Resource) readWorkload(node *yaml.Node) error {
+	var spec workloadSpec
+	if err := decodeSpec(node, &spec); err != nil {
+		return err
+	}
+	pod := spec.Template.Spec
+
+	r.PodLabels = spec.Template.Metadata.Labels
+	r.Claims = len(spec.VolumeClaimTemplates) > 0
+	for _, volume := range pod.Volumes {
+		if volume.ConfigMap != nil {
+			r.addConfigMap(volume.ConfigMap.Name)
+		}
+		if volume.PersistentVolumeClaim != nil {
+			r.Claims = true
+		}
+	}
+	for _, c := range append(pod.InitContainers, pod.Containers...) {
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				r.addConfigMap(from.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				r.addConfigMap(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Resource) readIngress(node *yaml.Node) error {
+	var spec ingressSpec
+	if err := decodeSpec(node, &spec); err != nil {
+		return err
+	}
+
+	r.addService(spec.DefaultBackend.service())
+	r.addService(spec.Backend.service())
+	for _, rule := range spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			r.addService(path.Backend.service())
+		}
+	}
+	return nil
+}
+
+func (r *Resource) addConfigMap(name string) {
+	r.ConfigMaps = appendUnique(r.ConfigMaps, name)
+}
+
+func (r *Resource) addService(name string) {
+	r.Services = appendUnique(r.Services, name)
+}
+
+func appendUnique(names []string, name string) []string {
+	if name == "" {
+		return names
+	}
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// Build turns resources into one component each, in order, with
+// namespaces as swimlanes. Ingresses connect to their Services, Services to
+// the workloads whose pods they select, and workloads to the ConfigMaps
+// they read. References to resources that are not in the list are dropped.
+func Build(resources []Resource) *model.Diagram {
+	diagram := &model.Diagram{}
+	exists := make(map[string]bool)
+	for _, res := range resources {
+		diagram.AddComponent(res.component())
+		exists[res.componentName()] = true
+	}
+
+	seen := make(map[model.Connection]bool)
+	connect := func(source Resource, kind, target string) {
+		conn := model.Connection{
+			Source: source.componentName(),
+			Target: ComponentName(source.Namespace, kind, target),
+		}
+		if !exists[conn.Target] || seen[conn] {
+			return
+		}
+		seen[conn] = true
+		diagram.AddConnection(conn)
+	}
+
+	for _, res := range resources {
+		switch res.Kind {
+		case KindIngress:
+			for _, service := range res.Services {
+				connect(res, KindService, service)
+			}
+		case KindService:
+			if len(res.Selector) == 0 {
+				continue
+			}
+			for _, workload := range resources {
+				if workload.Namespace == res.Namespace && workload.isWorkload() && selects(res.Selector, workload.PodLabels) {
+					connect(res, workload.Kind, workload.Name)
+				}
+			}
+		default:
+			for _, configMap := range res.ConfigMaps {
+				connect(res, KindConfigMap, configMap)
+			}
+		}
+	}
+	return diagram
+}
+
+func (r Resource) componentName() string {
+	return ComponentName(r.Namespace, r.Kind, r.Name)
+}
+
+func (r Resource) isWorkload() bool {
+	return r.Kind == KindDeployment || r.Kind == KindStatefulSet || r.Kind == KindDaemonSet
+}
+
+// component returns the component of a resource. StatefulSets that keep
+// state in persistent volume claims are storage.
+func (r Resource) component() model.Component {
+	comp := model.Component{
+		Name:        r.componentName(),
+		Label:       r.Name,
+		Description: r.Kind,
+		Swimlane:    r.Namespace,
+	}
+	switch {
+	case r.Kind == KindIngress:
+		comp.Type, comp.Shape = model.ComponentTypeGateway, model.ShapeTypeIsoCloud
+	case r.Kind == KindService:
+		comp.Type, comp.Shape = model.ComponentTypeAPI, model.ShapeTypeIsoNetwork
+	case r.Kind == KindConfigMap:
+		comp.Type, comp.Shape = model.ComponentTypeStorage, model.ShapeTypeIsoCube
+	case r.Kind == KindStatefulSet && r.Claims:
+		comp.Type, comp.Shape = model.ComponentTypeStorage, model.ShapeTypeIsoDatabase
+	case r.Kind == KindStatefulSet:
+		comp.Type, comp.Shape = model.ComponentTypeService, model.ShapeTypeIsoServer
+	default:
+		comp.Type, comp.Shape = model.ComponentTypeService, model.ShapeTypeIsoContainer
+	}
+	return comp
+}
+
+// selects reports whether every key of a selector matches the labels.
+func selects(selector, labels map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,241 @@
+package kubernetes_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/kubernetes"
+	"diagram-gen/internal/model"
+)
+
+const shop = `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: shop
+  namespace: shop
+spec:
+  defaultBackend:
+    service:
+      name: web
+      port:
+        number: 80
+  rules:
+    - host: shop.example.com
+      http:
+        paths:
+          - path: /api
+            pathType: Prefix
+            backend:
+              service:
+                name: orders
+                port:
+                  number: 8080
+          - path: /
+            pathType: Prefix
+            backend:
+              service:
+                name: web
+                port:
+                  number: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: orders
+  namespace: shop
+spec:
+  selector:
+    app: orders
+  ports:
+    - port: 8080
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: orders
+  namespace: shop
+spec:
+  selector:
+    matchLabels:
+      app: orders
+  template:
+    metadata:
+      labels:
+        app: orders
+        tier: backend
+    spec:
+      containers:
+        - name: orders
+          image: ghcr.io/acme/orders:1.4
+          envFrom:
+            - configMapRef:
+                name: orders-config
+          env:
+            - name: FEATURES
+              valueFrom:
+                configMapKeyRef:
+                  name: features
+                  key: flags
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: orders-config
+  namespace: shop
+data:
+  LOG_LEVEL: info
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: orders-db
+  namespace: shop
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: postgres
+    spec:
+      selector:
+        app: postgres
+  - apiVersion: apps/v1
+    kind: StatefulSet
+    metadata:
+      name: postgres
+    spec:
+      template:
+        metadata:
+          labels:
+            app: postgres
+      volumeClaimTemplates:
+        - metadata:
+            name: data
+`
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	resources, err := kubernetes.Load("shop.yaml", []byte(shop))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := []kubernetes.Resource{
+		{Kind: "Ingress", Namespace: "shop", Name: "shop", Line: 1, Services: []string{"web", "orders"}},
+		{Kind: "Service", Namespace: "shop", Name: "orders", Line: 31, Selector: map[string]string{"app": "orders"}},
+		{Kind: "Deployment", Namespace: "shop", Name: "orders", Line: 42,
+			PodLabels: map[string]string{"app": "orders", "tier": "backend"}, ConfigMaps: []string{"orders-config", "features"}},
+		{Kind: "ConfigMap", Namespace: "shop", Name: "orders-config", Line: 70},
+		{Kind: "Service", Namespace: "default", Name: "postgres", Line: 87, Selector: map[string]string{"app": "postgres"}},
+		{Kind: "StatefulSet", Namespace: "default", Name: "postgres", Line: 94,
+			PodLabels: map[string]string{"app": "postgres"}, Claims: true},
+	}
+	if !reflect.DeepEqual(resources, want) {
+		t.Errorf("Load = %+v\nwant %+v", resources, want)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+	resources, err := kubernetes.Load("shop.yaml", []byte(shop))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	diagram := kubernetes.Build(resources)
+
+	wantComponents := []model.Component{
+		{Name: "shop/ingress/shop", Label: "shop", Description: "Ingress", Swimlane: "shop",
+			Type: model.ComponentTypeGateway, Shape: model.ShapeTypeIsoCloud},
+		{Name: "shop/service/orders", Label: "orders", Description: "Service", Swimlane: "shop",
+			Type: model.ComponentTypeAPI, Shape: model.ShapeTypeIsoNetwork},
+		{Name: "shop/deployment/orders", Label: "orders", Description: "Deployment", Swimlane: "shop",
+			Type: model.ComponentTypeService, Shape: model.ShapeTypeIsoContainer},
+		{Name: "shop/configmap/orders-config", Label: "orders-config", Description: "ConfigMap", Swimlane: "shop",
+			Type: model.ComponentTypeStorage, Shape: model.ShapeTypeIsoCube},
+		{Name: "default/service/postgres", Label: "postgres", Description: "Service", Swimlane: "default",
+			Type: model.ComponentTypeAPI, Shape: model.ShapeTypeIsoNetwork},
+		{Name: "default/statefulset/postgres", Label: "postgres", Description: "StatefulSet", Swimlane: "default",
+			Type: model.ComponentTypeStorage, Shape: model.ShapeTypeIsoDatabase},
+	}
+	if !reflect.DeepEqual(diagram.Components, wantComponents) {
+		t.Errorf("components = %+v\nwant %+v", diagram.Components, wantComponents)
+	}
+
+	// The web Service and the features ConfigMap are not declared, so the
+	// references to them are dropped.
+	wantConnections := []model.Connection{
+		{Source: "shop/ingress/shop", Target: "shop/service/orders"},
+		{Source: "shop/service/orders", Target: "shop/deployment/orders"},
+		{Source: "shop/deployment/orders", Target: "shop/configmap/orders-config"},
+		{Source: "default/service/postgres", Target: "default/statefulset/postgres"},
+	}
+	if !reflect.DeepEqual(diagram.Connections, wantConnections) {
+		t.Errorf("connections = %+v\nwant %+v", diagram.Connections, wantConnections)
+	}
+}
+
+func TestBuildSelectors(t *testing.T) {
+	t.Parallel()
+	resources := []kubernetes.Resource{
+		{Kind: "Service", Namespace: "a", Name: "api", Selector: map[string]string{"app": "api", "track": "stable"}},
+		{Kind: "Service", Namespace: "a", Name: "headless"},
+		{Kind: "Deployment", Namespace: "a", Name: "stable", PodLabels: map[string]string{"app": "api", "track": "stable"}},
+		{Kind: "Deployment", Namespace: "a", Name: "canary", PodLabels: map[string]string{"app": "api", "track": "canary"}},
+		{Kind: "Deployment", Namespace: "b", Name: "stable", PodLabels: map[string]string{"app": "api", "track": "stable"}},
+		{Kind: "DaemonSet", Namespace: "a", Name: "agent", PodLabels: map[string]string{"app": "api", "track": "stable", "node": "all"}},
+	}
+	got := kubernetes.Build(resources).Connections
+	want := []model.Connection{
+		{Source: "a/service/api", Target: "a/deployment/stable"},
+		{Source: "a/service/api", Target: "a/daemonset/agent"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("connections = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "syntax", data: "kind: [\n", want: "invalid manifest"},
+		{name: "no name", data: "apiVersion: v1\nkind: ConfigMap\nmetadata: {}\n", want: "ConfigMap has no metadata.name"},
+		{name: "bad spec", data: "apiVersion: v1\nkind: Service\nmetadata:\n  name: api\nspec:\n  selector: [app]\n", want: "line 1: Service api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := kubernetes.Load("bad.yaml", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsManifest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{name: "deployment", data: "apiVersion: apps/v1\nkind: Deployment\n", want: true},
+		{name: "second document", data: "# generated\n---\napiVersion: v1\nkind: Service\n", want: true},
+		{name: "no apiVersion", data: "kind: Deployment\n", want: false},
+		{name: "compose", data: "services:\n  web:\n    image: nginx\n", want: false},
+		{name: "list document", data: "- a\n- b\n", want: false},
+		{name: "empty", data: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := kubernetes.IsManifest([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: IsManifest = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package markdown reads the Mermaid and PlantUML diagrams fenced in
+// Markdown documents.
+package markdown
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"diagram-gen/internal/mermaid"
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/plantuml"
+)
+
+// IsMarkdownFile reports whether a path names a Markdown file.
+func IsMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// Block is a fenced code block holding a diagram.
+type Block struct {
+	// Lang is "mermaid" or "plantuml".
+	Lang string
+	// Line is the line of the opening fence.
+	Line int
+	// Source is the content of the block, preceded by blank lines so that
+	// line numbers match the document.
+	Source string
+}
+
+// langs maps the info strings of fenced blocks to the languages read.
+var langs = map[string]string{
+	"mermaid":  "mermaid",
+	"plantuml": "plantuml",
+	"puml":     "plantuml",
+	"uml":      "plantuml",
+}
+
+// Extract returns the Mermaid and PlantUML blocks of a document, fenced with
+// backticks or tildes.
+func Extract(data []byte) []Block {
+	var blocks []Block
+	var open *Block
+	var fence string
+	var body strings.Builder
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if open == nil {
+			marker := fenceOf(trimmed)
+			if marker == "" {
+				continue
+			}
+			fields := strings.Fields(strings.TrimLeft(trimmed, marker[:1]))
+			fence = marker
+			open = &Block{Line: i + 1}
+			if len(fields) > 0 {
+				open.Lang = langs[strings.ToLower(fields[0])]
+			}
+			body.Reset()
+			body.WriteString(strings.Repeat("\n", i+1))
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			if open.Lang != "" {
+				open.Source = body.String()
+				blocks = append(blocks, *open)
+			}
+			open = nil
+			continue
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	return blocks
+}
+
+// fenceOf returns the opening fence of a line, three or more backticks or
+// tildes, or "".
+func fenceOf(line string) string {
+	for _, c := range "`~" {
+		n := len(line) - len(strings.TrimLeft(line, string(c)))
+		if n >= 3 {
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+// Load reads the diagrams of a document into one diagram, so blocks can
+// show the same components from different angles. A component declared in
+// several blocks keeps its first declaration; a partial reference gives way
+// to a later declaration. Mermaid blocks other than flowcharts are skipped.
+func Load(path string, data []byte) (*model.Diagram, error) {
+	diagram := &model.Diagram{Type: model.DiagramTypeArchitecture}
+	found := false
+	for _, block := range Extract(data) {
+		var part *model.Diagram
+		var err error
+		switch block.Lang {
+		case "mermaid":
+			if !mermaid.IsFlowchart([]byte(block.Source)) {
+				continue
+			}
+			part, err = mermaid.Parse(path, []byte(block.Source))
+		case "plantuml":
+			part, err = plantuml.Parse(path, []byte(block.Source))
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		for _, comp := range part.Components {
+			switch existing := diagram.GetComponentByName(comp.Name); {
+			case existing == nil:
+				diagram.AddComponent(comp)
+			case existing.Partial && !comp.Partial:
+				*existing = comp
+			}
+		}
+		for _, conn := range part.Connections {
+			if !hasConnection(diagram, conn) {
+				diagram.AddConnection(conn)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid Markdown file %s: no Mermaid flowchart or PlantUML block", path)
+	}
+	return diagram, nil
+}
+
+func hasConnection(diagram *model.Diagram, conn model.Connection) bool {
+	for _, c := range diagram.Connections {
+		if c == conn {
+			return true
+		}
+	}
+	return false
+}
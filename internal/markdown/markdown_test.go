@@ -0,0 +1,93 @@
+package markdown_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/markdown"
+	"diagram-gen/internal/model"
+)
+
+const design = "# Checkout\n" +
+	"\n" +
+	"```mermaid\n" +
+	"flowchart LR\n" +
+	"  web[Web Shop] --> orders\n" +
+	"```\n" +
+	"\n" +
+	"```go\n" +
+	"```mermaid\n" +
+	"```\n" +
+	"\n" +
+	"~~~~ plantuml\n" +
+	"[Order Service] as orders\n" +
+	"orders --> db\n" +
+	"database db\n" +
+	"~~~~\n" +
+	"\n" +
+	"```mermaid\n" +
+	"sequenceDiagram\n" +
+	"  web->>orders: place\n" +
+	"```\n"
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+	blocks := markdown.Extract([]byte(design))
+	var got []string
+	for _, b := range blocks {
+		got = append(got, b.Lang)
+	}
+	want := []string{"mermaid", "plantuml", "mermaid"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Extract languages = %v, want %v", got, want)
+	}
+	if blocks[1].Line != 12 || strings.Split(blocks[1].Source, "\n")[12] != "[Order Service] as orders" {
+		t.Errorf("plantuml block at line %d does not keep document line numbers: %q", blocks[1].Line, blocks[1].Source)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	got, err := markdown.Load("design.md", []byte(design))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "web", Label: "Web Shop", Type: model.ComponentTypeService},
+			{Name: "orders", Label: "Order Service", Type: model.ComponentTypeService},
+			{Name: "db", Type: model.ComponentTypeDatabase},
+		},
+		Connections: []model.Connection{
+			{Source: "web", Target: "orders", Direction: model.ConnectionDirectionUnidirectional},
+			{Source: "orders", Target: "db", Direction: model.ConnectionDirectionUnidirectional},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "none", data: "# Notes\n", want: "invalid Markdown file doc.md: no Mermaid flowchart or PlantUML block"},
+		{name: "block", data: "Intro\n\n```mermaid\ngraph TD\nA -->\n```\n", want: `invalid Mermaid diagram doc.md: line 5: expected a node, found ""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := markdown.Load("doc.md", []byte(tt.data))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
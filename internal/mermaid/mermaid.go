@@ -0,0 +1,589 @@
+// Package mermaid reads Mermaid flowcharts.
+package mermaid
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/validator"
+)
+
+// IsMermaidFile reports whether a path names a Mermaid file.
+func IsMermaidFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmd", ".mermaid":
+		return true
+	}
+	return false
+}
+
+var header = regexp.MustCompile(`^(flowchart|graph)(\s+(TB|TD|BT|RL|LR))?$`)
+
+// IsFlowchart reports whether Mermaid source declares a flowchart, the only
+// kind of Mermaid diagram that is read.
+func IsFlowchart(data []byte) bool {
+	all := lines(string(data))
+	if len(all) == 0 {
+		return false
+	}
+	first, _, _ := strings.Cut(all[0].text, ";")
+	return header.MatchString(strings.TrimSpace(first))
+}
+
+// Parse reads a flowchart. Nodes become components named by their id and
+// labelled by their text, typed after their shape; links become
+// connections and subgraphs swimlanes. style, classDef and class statements
+// set the fill, stroke and font colors of components, and a class naming a
+// component type, such as cache, sets the type. Nodes written without
+// a shape are references: they are partial and have no type, so they merge
+// with a declaration found elsewhere.
+func Parse(path string, data []byte) (*model.Diagram, error) {
+	p := &parser{
+		byID:      make(map[string]*node),
+		subgraphs: make(map[string]bool),
+		classDefs: make(map[string][]string),
+	}
+	if err := p.parse(string(data)); err != nil {
+		return nil, fmt.Errorf("invalid Mermaid diagram %s: %w", path, err)
+	}
+	return p.diagram(), nil
+}
+
+// line is a line of source without its comments.
+type line struct {
+	number int
+	text   string
+}
+
+// lines returns the non-blank lines of Mermaid source, without comments,
+// directives, YAML front matter and accessibility descriptions.
+func lines(src string) []line {
+	var out []line
+	inFrontMatter, inDescr := false, false
+	for i, text := range strings.Split(src, "\n") {
+		text = strings.TrimSpace(text)
+		switch {
+		case text == "---" && len(out) == 0:
+			inFrontMatter = !inFrontMatter
+		case inFrontMatter:
+		case inDescr:
+			inDescr = text != "}"
+		case strings.HasPrefix(text, "accDescr") && strings.HasSuffix(text, "{"):
+			inDescr = true
+		case text == "" || strings.HasPrefix(text, "%%"):
+		default:
+			out = append(out, line{i + 1, text})
+		}
+	}
+	return out
+}
+
+type node struct {
+	comp    model.Component
+	classes []string
+	style   []string
+}
+
+type parser struct {
+	nodes []*node
+	byID  map[string]*node
+	conns []model.Connection
+	// lanes holds the titles of the open subgraphs, innermost last.
+	lanes []string
+	// subgraphs holds the ids of subgraphs, which are not components.
+	subgraphs map[string]bool
+	classDefs map[string][]string
+}
+
+func (p *parser) parse(src string) error {
+	all := lines(src)
+	if len(all) == 0 {
+		return fmt.Errorf("expected a flowchart or graph header, found nothing")
+	}
+
+	for i, l := range all {
+		for j, stmt := range splitStatements(l.text) {
+			if i == 0 && j == 0 {
+				if !header.MatchString(stmt) {
+					return fmt.Errorf("line %d: expected a flowchart or graph header, found %q", l.number, stmt)
+				}
+				continue
+			}
+			if err := p.statement(stmt); err != nil {
+				return fmt.Errorf("line %d: %w", l.number, err)
+			}
+		}
+	}
+	if len(p.lanes) > 0 {
+		return fmt.Errorf("subgraph %q is not closed", p.lanes[len(p.lanes)-1])
+	}
+	return nil
+}
+
+// splitStatements splits a line at the semicolons outside quotes.
+func splitStatements(text string) []string {
+	var stmts []string
+	quoted, start := false, 0
+	for i, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ';' && !quoted:
+			stmts = append(stmts, text[start:i])
+			start = i + 1
+		}
+	}
+	stmts = append(stmts, text[start:])
+
+	out := stmts[:0]
+	for _, stmt := range stmts {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+func (p *parser) statement(stmt string) error {
+	keyword, rest, _ := strings.Cut(stmt, " ")
+	rest = strings.TrimSpace(rest)
+	switch keyword {
+	case "subgraph":
+		return p.subgraph(rest)
+	case "end":
+		if len(p.lanes) == 0 {
+			return fmt.Errorf("end without subgraph")
+		}
+		p.lanes = p.lanes[:len(p.lanes)-1]
+		return nil
+	case "classDef":
+		names, props, _ := strings.Cut(rest, " ")
+		for _, name := range strings.Split(names, ",") {
+			p.classDefs[strings.TrimSpace(name)] = styleOf(props)
+		}
+		return nil
+	case "class":
+		ids, class, _ := strings.Cut(rest, " ")
+		for _, id := range strings.Split(ids, ",") {
+			n := p.node(strings.TrimSpace(id))
+			n.classes = append(n.classes, strings.TrimSpace(class))
+		}
+		return nil
+	case "style":
+		id, props, _ := strings.Cut(rest, " ")
+		n := p.node(id)
+		n.style = append(n.style, styleOf(props)...)
+		return nil
+	case "direction", "linkStyle", "click", "accTitle:", "accDescr:":
+		return nil
+	}
+	return p.chain(&scanner{s: stmt})
+}
+
+var subgraphTitle = regexp.MustCompile(`^([\p{L}\p{N}_-]+)\s*\[(.*)\]$`)
+
+// subgraph opens a subgraph declared as id, id [title] or "title".
+func (p *parser) subgraph(rest string) error {
+	if rest == "" {
+		return fmt.Errorf("subgraph needs a title")
+	}
+	id, title := rest, rest
+	if m := subgraphTitle.FindStringSubmatch(rest); m != nil {
+		id, title = m[1], m[2]
+	}
+	p.subgraphs[id] = true
+	p.lanes = append(p.lanes, labelText(title))
+	return nil
+}
+
+// node returns the node with an id, creating it in the innermost open subgraph.
+func (p *parser) node(id string) *node {
+	if n, ok := p.byID[id]; ok {
+		if n.comp.Swimlane == "" && len(p.lanes) > 0 {
+			n.comp.Swimlane = p.lanes[len(p.lanes)-1]
+		}
+		return n
+	}
+	n := &node{comp: model.Component{Name: id, Partial: true}}
+	if len(p.lanes) > 0 {
+		n.comp.Swimlane = p.lanes[len(p.lanes)-1]
+	}
+	p.nodes = append(p.nodes, n)
+	p.byID[id] = n
+	return n
+}
+
+// chain reads nodes joined by links, as in A & B --> C -.-> D.
+func (p *parser) chain(sc *scanner) error {
+	left, err := p.nodeGroup(sc)
+	if err != nil {
+		return err
+	}
+	for {
+		sc.skipSpace()
+		if sc.done() {
+			return nil
+		}
+		l, ok := sc.link()
+		if !ok {
+			return fmt.Errorf("expected a link, found %q", sc.rest())
+		}
+		right, err := p.nodeGroup(sc)
+		if err != nil {
+			return err
+		}
+		if !l.invisible {
+			for _, source := range left {
+				for _, target := range right {
+					p.conns = append(p.conns, l.connection(source, target))
+				}
+			}
+		}
+		left = right
+	}
+}
+
+// nodeGroup reads nodes joined by &.
+func (p *parser) nodeGroup(sc *scanner) ([]string, error) {
+	var ids []string
+	for {
+		id, err := p.nodeRef(sc)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		sc.skipSpace()
+		if !sc.consume("&") {
+			return ids, nil
+		}
+	}
+}
+
+// nodeRef reads a node id with its optional shape, label and class.
+func (p *parser) nodeRef(sc *scanner) (string, error) {
+	sc.skipSpace()
+	id := sc.ident()
+	if id == "" {
+		return "", fmt.Errorf("expected a node, found %q", sc.rest())
+	}
+	n := p.node(id)
+
+	typ, label, ok, err := sc.shape()
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		n.comp.Type, n.comp.Partial = typ, false
+		n.comp.Label = ""
+		if label != id {
+			n.comp.Label = label
+		}
+	}
+	if sc.consume(":::") {
+		n.classes = append(n.classes, sc.ident())
+	}
+	return id, nil
+}
+
+// diagram returns the components and connections read, leaving out
+// subgraphs used as link ends.
+func (p *parser) diagram() *model.Diagram {
+	diagram := &model.Diagram{Type: model.DiagramTypeArchitecture}
+	for _, n := range p.nodes {
+		if p.subgraphs[n.comp.Name] {
+			continue
+		}
+		var attrs []string
+		attrs = append(attrs, p.classDefs["default"]...)
+		for _, class := range n.classes {
+			attrs = append(attrs, p.classDefs[class]...)
+			if typ := model.ComponentType(class); typ != model.ComponentTypeUnknown && validator.ValidateComponentType(typ) {
+				n.comp.Type = typ
+			}
+		}
+		attrs = append(attrs, n.style...)
+		n.comp.Style = mergeStyle(attrs)
+		diagram.AddComponent(n.comp)
+	}
+	for _, conn := range p.conns {
+		if !p.subgraphs[conn.Source] && !p.subgraphs[conn.Target] {
+			diagram.AddConnection(conn)
+		}
+	}
+	return diagram
+}
+
+// shapes lists the node shapes, longer openings first.
+var shapes = []struct {
+	open   string
+	closes []string
+	typ    model.ComponentType
+}{
+	{"(((", []string{")))"}, model.ComponentTypeTerminal},
+	{"([", []string{"])"}, model.ComponentTypeTerminal},
+	{"[[", []string{"]]"}, model.ComponentTypeProcess},
+	{"[(", []string{")]"}, model.ComponentTypeDatabase},
+	{"((", []string{"))"}, model.ComponentTypeUser},
+	{"{{", []string{"}}"}, model.ComponentTypeGateway},
+	{"[/", []string{"/]"}, model.ComponentTypeQueue},
+	{`[\`, []string{`\]`}, model.ComponentTypeQueue},
+	{"[/", []string{`\]`}, model.ComponentTypeProcess},
+	{`[\`, []string{"/]"}, model.ComponentTypeProcess},
+	{"[", []string{"]"}, model.ComponentTypeService},
+	{"(", []string{")"}, model.ComponentTypeService},
+	{"{", []string{"}"}, model.ComponentTypeDecision},
+	{">", []string{"]"}, model.ComponentTypeQueue},
+}
+
+type scanner struct {
+	s   string
+	pos int
+}
+
+func (sc *scanner) rest() string { return sc.s[sc.pos:] }
+
+func (sc *scanner) done() bool { return sc.pos >= len(sc.s) }
+
+func (sc *scanner) skipSpace() {
+	for !sc.done() && (sc.s[sc.pos] == ' ' || sc.s[sc.pos] == '\t') {
+		sc.pos++
+	}
+}
+
+func (sc *scanner) consume(prefix string) bool {
+	if strings.HasPrefix(sc.rest(), prefix) {
+		sc.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+// ident reads a node id. Dashes belong to it only when a letter or digit
+// follows, so A-->B reads as a link.
+func (sc *scanner) ident() string {
+	start := sc.pos
+	for i, r := range sc.rest() {
+		if r == '-' {
+			next := []rune(sc.rest()[i+1:])
+			if len(next) > 0 && isIdentRune(next[0]) && i > 0 {
+				continue
+			}
+			break
+		}
+		if !isIdentRune(r) {
+			break
+		}
+		sc.pos = start + i + len(string(r))
+	}
+	return sc.s[start:sc.pos]
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// shape reads the shape and label following a node id, if any.
+func (sc *scanner) shape() (model.ComponentType, string, bool, error) {
+	rest := sc.rest()
+	for _, shape := range shapes {
+		if !strings.HasPrefix(rest, shape.open) {
+			continue
+		}
+		body := rest[len(shape.open):]
+		for _, closing := range shape.closes {
+			text, n, ok := labelBody(body, closing)
+			if ok {
+				sc.pos += len(shape.open) + n
+				return shape.typ, labelText(text), true, nil
+			}
+		}
+		if len(shape.closes) == 1 && !strings.HasPrefix(rest, "[/") && !strings.HasPrefix(rest, `[\`) {
+			return "", "", false, fmt.Errorf("node label %q is not closed by %q", rest, shape.closes[0])
+		}
+	}
+	return "", "", false, nil
+}
+
+// labelBody returns the label up to a closing delimiter and the length
+// read, quotes included.
+func labelBody(body, closing string) (string, int, bool) {
+	if strings.HasPrefix(body, `"`) {
+		end := strings.Index(body[1:], `"`)
+		if end < 0 || !strings.HasPrefix(body[end+2:], closing) {
+			return "", 0, false
+		}
+		return body[1 : end+1], end + 2 + len(closing), true
+	}
+	end := strings.Index(body, closing)
+	if end < 0 {
+		return "", 0, false
+	}
+	return body[:end], end + len(closing), true
+}
+
+// link is an arrow between nodes.
+type link struct {
+	start, head string
+	// line is "-" for normal, "=" for thick and "." for dotted links.
+	line      string
+	label     string
+	invisible bool
+}
+
+var (
+	// plainLink matches -->, ---, ==>, -.->, <-->, --o and ~~~.
+	plainLink = regexp.MustCompile(`^([<ox])?(-{2,}|={2,}|-\.+-|~{3,})([>ox])?`)
+	// textLink matches links with their text inside, as in -- text -->.
+	textLink = regexp.MustCompile(`^([<ox])?(--|==|-\.)\s*(.+?)\s*(-{2,}|={2,}|\.+-)([>ox])?`)
+	// pipeLabel matches the text following a link, as in -->|text|.
+	pipeLabel = regexp.MustCompile(`^\s*\|([^|]*)\|`)
+)
+
+// link reads a link and its label.
+func (sc *scanner) link() (link, bool) {
+	rest := sc.rest()
+	var l link
+	if m := plainLink.FindStringSubmatchIndex(rest); m != nil {
+		l.start, l.head = group(rest, m, 1), group(rest, m, 3)
+		body, end := group(rest, m, 2), m[1]
+		if (l.head == "o" || l.head == "x") && end < len(rest) && isIdentRune(rune(rest[end])) {
+			l.head, end = "", end-1
+		}
+		if l.head != "" || len(body) > 2 || strings.Contains(body, ".") || strings.HasPrefix(body, "~") {
+			l.line, l.invisible = body[:1], body[0] == '~'
+			if strings.Contains(body, ".") {
+				l.line = "."
+			}
+			sc.pos += end
+			if m := pipeLabel.FindStringSubmatch(sc.rest()); m != nil {
+				l.label = labelText(m[1])
+				sc.pos += len(m[0])
+			}
+			return l, true
+		}
+	}
+
+	if m := textLink.FindStringSubmatch(rest); m != nil {
+		l.start, l.head = m[1], m[5]
+		l.line = m[2][:1]
+		if strings.Contains(m[2], ".") {
+			l.line = "."
+		}
+		l.label = labelText(m[3])
+		sc.pos += len(m[0])
+		return l, true
+	}
+	return link{}, false
+}
+
+func group(s string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+	return s[m[2*i]:m[2*i+1]]
+}
+
+var arrowHeads = map[string]string{"o": "oval", "x": "cross"}
+
+// connection returns the connection a link draws between two nodes.
+func (l link) connection(source, target string) model.Connection {
+	// Links are unidirectional unless both ends have arrows, as with
+	// connectsTo, so that a link repeating an annotation merges with it.
+	conn := model.Connection{Source: source, Target: target, Label: l.label,
+		Direction: model.ConnectionDirectionUnidirectional}
+	switch {
+	case l.start == "<" && l.head == ">":
+		conn.Direction = model.ConnectionDirectionBidirectional
+	case l.start != "" && l.start != "<":
+		conn.StartArrow = arrowHeads[l.start]
+	}
+	switch l.head {
+	case "":
+		conn.EndArrow = "none"
+	case "o", "x":
+		conn.EndArrow = arrowHeads[l.head]
+	}
+	switch l.line {
+	case "=":
+		conn.Style = "strokeWidth=3"
+	case ".":
+		conn.Style = "dashed=1"
+	}
+	return conn
+}
+
+// styleOf translates CSS properties such as fill:#f9f,stroke-width:2px
+// into draw.io style settings.
+func styleOf(props string) []string {
+	var attrs []string
+	for _, prop := range strings.Split(props, ",") {
+		key, value, ok := strings.Cut(prop, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "fill":
+			attrs = append(attrs, "fillColor="+value)
+		case "stroke":
+			attrs = append(attrs, "strokeColor="+value)
+		case "color":
+			attrs = append(attrs, "fontColor="+value)
+		case "stroke-width":
+			attrs = append(attrs, "strokeWidth="+strings.TrimSuffix(value, "px"))
+		case "font-size":
+			attrs = append(attrs, "fontSize="+strings.TrimSuffix(value, "px"))
+		case "stroke-dasharray":
+			attrs = append(attrs, "dashed=1")
+		}
+	}
+	return attrs
+}
+
+// mergeStyle joins style settings, later ones replacing earlier ones with
+// the same key.
+func mergeStyle(attrs []string) string {
+	var keys []string
+	values := make(map[string]string)
+	for _, attr := range attrs {
+		key, _, _ := strings.Cut(attr, "=")
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = attr
+	}
+	merged := make([]string, len(keys))
+	for i, key := range keys {
+		merged[i] = values[key]
+	}
+	return strings.Join(merged, ";")
+}
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+	// entityCode matches Mermaid entity codes, such as #quot; and #35;.
+	entityCode = regexp.MustCompile(`#(\w+);`)
+)
+
+// labelText returns the text of a label without quotes, markup, entity
+// codes and repeated whitespace.
+func labelText(s string) string {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	s = strings.Trim(s, "`")
+	s = htmlBreak.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = entityCode.ReplaceAllStringFunc(s, func(code string) string {
+		if code[1] >= '0' && code[1] <= '9' {
+			return "&#" + code[1:]
+		}
+		return "&" + code[1:]
+	})
+	s = html.UnescapeString(s)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,147 @@
+package mermaid_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/generator"
+	"diagram-gen/internal/mermaid"
+	"diagram-gen/internal/model"
+)
+
+const checkout = `---
+title: Checkout
+---
+%% The order path
+flowchart LR
+    user((Customer)) -->|places orders| web[Web Shop]
+    subgraph backend [Backend services]
+        direction TB
+        web --> api{{"API Gateway"}}
+        api -- routes --> orders([Orders<br/>Service]):::hot
+        orders <--> db[(Orders DB)]
+    end
+    orders -.-> events[/Order Events/]
+    events ==> mailer & audit[[Audit log]]:::storage
+    mailer --- smtp>"SMTP #quot;relay#quot; #35;1"]; mailer ~~~ audit
+    orders --x backend
+    classDef hot fill:#f8cecc,stroke:#b85450
+    style db stroke-width:2px,color:#333
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	got, err := mermaid.Parse("checkout.mmd", []byte(checkout))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "user", Label: "Customer", Type: model.ComponentTypeUser},
+			{Name: "web", Label: "Web Shop", Type: model.ComponentTypeService, Swimlane: "Backend services"},
+			{Name: "api", Label: "API Gateway", Type: model.ComponentTypeGateway, Swimlane: "Backend services"},
+			{Name: "orders", Label: "Orders Service", Type: model.ComponentTypeTerminal, Swimlane: "Backend services",
+				Style: "fillColor=#f8cecc;strokeColor=#b85450"},
+			{Name: "db", Label: "Orders DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend services",
+				Style: "strokeWidth=2;fontColor=#333"},
+			{Name: "events", Label: "Order Events", Type: model.ComponentTypeQueue},
+			{Name: "mailer", Partial: true},
+			{Name: "audit", Label: "Audit log", Type: model.ComponentTypeStorage},
+			{Name: "smtp", Label: `SMTP "relay" #1`, Type: model.ComponentTypeQueue},
+		},
+		Connections: []model.Connection{
+			{Source: "user", Target: "web", Direction: model.ConnectionDirectionUnidirectional, Label: "places orders"},
+			{Source: "web", Target: "api", Direction: model.ConnectionDirectionUnidirectional},
+			{Source: "api", Target: "orders", Direction: model.ConnectionDirectionUnidirectional, Label: "routes"},
+			{Source: "orders", Target: "db", Direction: model.ConnectionDirectionBidirectional},
+			{Source: "orders", Target: "events", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1"},
+			{Source: "events", Target: "mailer", Direction: model.ConnectionDirectionUnidirectional, Style: "strokeWidth=3"},
+			{Source: "events", Target: "audit", Direction: model.ConnectionDirectionUnidirectional, Style: "strokeWidth=3"},
+			{Source: "mailer", Target: "smtp", Direction: model.ConnectionDirectionUnidirectional, EndArrow: "none"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseGenerated(t *testing.T) {
+	t.Parallel()
+	source := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Swimlane: "Backend"},
+			{Name: "DB", Label: "Orders \"main\" DB", Type: model.ComponentTypeDatabase, Swimlane: "Backend"},
+			{Name: "Sessions", Type: model.ComponentTypeCache, Style: "fillColor=#ffffff;strokeWidth=2"},
+			{Name: "Payments", Type: model.ComponentTypeAPI},
+			{Name: "Customer", Type: model.ComponentTypeUser},
+			{Name: "Start", Type: model.ComponentTypeTerminal},
+		},
+		Connections: []model.Connection{
+			{Source: "Customer", Target: "API", Direction: model.ConnectionDirectionUnidirectional, Label: "calls | retries"},
+			{Source: "API", Target: "DB", Direction: model.ConnectionDirectionBidirectional, Style: "dashed=1"},
+			{Source: "API", Target: "Sessions", Direction: model.ConnectionDirectionUnidirectional, EndArrow: generator.ArrowNone, Style: "strokeWidth=3"},
+			{Source: "API", Target: "Payments", Direction: model.ConnectionDirectionUnidirectional, EndArrow: "oval"},
+			{Source: "Start", Target: "Customer", Direction: model.ConnectionDirectionUnidirectional},
+		},
+	}
+	data, err := generator.NewMermaidGenerator().Generate(source)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	got, err := mermaid.Parse("arch.mmd", data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, source) {
+		t.Errorf("Parse = %+v\nwant %+v\n%s", got, source, data)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "empty", src: "%% nothing\n", want: "expected a flowchart or graph header, found nothing"},
+		{name: "sequence", src: "sequenceDiagram\nA->>B: hi\n", want: `line 1: expected a flowchart or graph header, found "sequenceDiagram"`},
+		{name: "label", src: "graph TD\nA[Open --> B\n", want: `line 2: node label "[Open --> B" is not closed by "]"`},
+		{name: "link", src: "graph TD\nA B\n", want: `line 2: expected a link, found "B"`},
+		{name: "end", src: "graph TD\nA --> B\nend\n", want: "line 3: end without subgraph"},
+		{name: "subgraph", src: "graph TD\nsubgraph one\nA\n", want: `subgraph "one" is not closed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := mermaid.Parse("a.mmd", []byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid Mermaid diagram a.mmd: ") {
+				t.Errorf("Parse error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
+
+func TestIsFlowchart(t *testing.T) {
+	t.Parallel()
+	tests := map[string]bool{
+		"flowchart TD\nA-->B":     true,
+		"%% c\n\ngraph LR; A-->B": true,
+		"graph":                   true,
+		"sequenceDiagram\nA->>B:": false,
+		"":                        false,
+	}
+	for src, want := range tests {
+		if got := mermaid.IsFlowchart([]byte(src)); got != want {
+			t.Errorf("IsFlowchart(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
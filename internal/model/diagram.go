@@ -11,6 +11,8 @@ const (
 	DiagramTypeFlowchart DiagramType = "flowchart"
 	// DiagramTypeNetwork is a network diagram.
 	DiagramTypeNetwork DiagramType = "network"
+	// DiagramTypeImports is a Go package import graph.
+	DiagramTypeImports DiagramType = "imports"
 )
 
 // ComponentType defines the type of component.
@@ -35,6 +37,12 @@ const (
 	ComponentTypeStorage ComponentType = "storage"
 	// ComponentTypeGateway is a gateway component.
 	ComponentTypeGateway ComponentType = "gateway"
+	// ComponentTypeProcess is a flowchart process step.
+	ComponentTypeProcess ComponentType = "process"
+	// ComponentTypeDecision is a flowchart decision.
+	ComponentTypeDecision ComponentType = "decision"
+	// ComponentTypeTerminal is a flowchart start or end point.
+	ComponentTypeTerminal ComponentType = "terminal"
 	// ComponentTypeUnknown is an unknown component type.
 	ComponentTypeUnknown ComponentType = "unknown"
 )
@@ -61,6 +69,8 @@ const (
 	ShapeTypeIsoCube ShapeType = "iso:cube"
 	// ShapeTypeIsoContainer is an isometric container shape.
 	ShapeTypeIsoContainer ShapeType = "iso:container"
+	// ShapeTypeIsoNetwork is an isometric network device shape.
+	ShapeTypeIsoNetwork ShapeType = "iso:network"
 )
 
 // ConnectionDirection defines the direction of a connection.
@@ -74,17 +84,30 @@ const (
 )
 
 // Component represents a node in the diagram.
+// Label, when set, is displayed instead of the unique Name.
+// Zone, Subnet and CIDR place the component in a network diagram; Port and
+// Protocol describe what it listens on. Module is the path of the Go module
+// declaring the component, when known. Partial marks a declaration that only
+// adds to a component declared elsewhere; see Diagram.Reconcile.
 type Component struct {
 	Type        ComponentType       `json:"type"`
 	Name        string              `json:"name"`
+	Label       string              `json:"label,omitempty"`
 	Description string              `json:"description,omitempty"`
 	Direction   ConnectionDirection `json:"direction,omitempty"`
 	Shape       ShapeType           `json:"shape,omitempty"`
 	Page        string              `json:"page,omitempty"`
 	Swimlane    string              `json:"swimlane,omitempty"`
 	Style       string              `json:"style,omitempty"`
+	Zone        string              `json:"zone,omitempty"`
+	Subnet      string              `json:"subnet,omitempty"`
+	CIDR        string              `json:"cidr,omitempty"`
+	Port        string              `json:"port,omitempty"`
+	Protocol    string              `json:"protocol,omitempty"`
+	Module      string              `json:"module,omitempty"`
 	X           int                 `json:"x,omitempty"`
 	Y           int                 `json:"y,omitempty"`
+	Partial     bool                `json:"partial,omitempty"`
 }
 
 // Connection represents an edge between two components.
@@ -97,6 +120,11 @@ type Connection struct {
 	EdgeStyle  string              `json:"edgeStyle,omitempty"`
 	StartArrow string              `json:"startArrow,omitempty"`
 	EndArrow   string              `json:"endArrow,omitempty"`
+	Port       string              `json:"port,omitempty"`
+	Protocol   string              `json:"protocol,omitempty"`
+	Style      string              `json:"style,omitempty"`
+	// Inferred is set for connections derived from code structure rather than declared.
+	Inferred bool `json:"inferred,omitempty"`
 }
 
 // Diagram represents a complete diagram with components and connections.
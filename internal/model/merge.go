@@ -0,0 +1,176 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeIssue describes a problem found while merging the declarations of one
+// component. Index is the position in Components, before merging, of the
+// declaration the issue is about.
+type MergeIssue struct {
+	Component string
+	Index     int
+	Message   string
+}
+
+// Reconcile merges the components declared more than once into one and
+// removes repeated connections. The merged component takes the place of the
+// first declaration of its name.
+//
+// Fields come from the full declaration, the first one without Partial set.
+// Partial declarations fill in the fields it leaves empty; a field set to two
+// different values keeps the value merged first and is reported. A second full
+// declaration is merged the same way and also reported. Components that only
+// have partial declarations are kept and reported. Connections need no
+// merging: those declared on every part are kept, once each.
+func (d *Diagram) Reconcile() []MergeIssue {
+	var issues []MergeIssue
+	var order []string
+	declarations := make(map[string][]int)
+	for i, comp := range d.Components {
+		if _, seen := declarations[comp.Name]; !seen {
+			order = append(order, comp.Name)
+		}
+		declarations[comp.Name] = append(declarations[comp.Name], i)
+	}
+
+	components := make([]Component, 0, len(order))
+	for _, name := range order {
+		indexes := declarations[name]
+
+		primary := -1
+		for _, i := range indexes {
+			if d.Components[i].Partial {
+				continue
+			}
+			if primary < 0 {
+				primary = i
+				continue
+			}
+			issues = append(issues, MergeIssue{Component: name, Index: i,
+				Message: fmt.Sprintf("duplicate declaration of %q merged; mark it partial=true or use extends=%s", name, name)})
+		}
+		if primary < 0 {
+			primary = indexes[0]
+			issues = append(issues, MergeIssue{Component: name, Index: primary,
+				Message: fmt.Sprintf("%q only has partial declarations", name)})
+		}
+
+		merged := d.Components[primary]
+		merged.Partial = false
+		for _, i := range indexes {
+			if i != primary {
+				issues = merged.merge(d.Components[i], i, issues)
+			}
+		}
+		if merged.Type == "" {
+			merged.Type = ComponentTypeService
+		}
+		components = append(components, merged)
+	}
+	d.Components = components
+
+	seen := make(map[Connection]bool, len(d.Connections))
+	connections := d.Connections[:0]
+	for _, conn := range d.Connections {
+		if !seen[conn] {
+			seen[conn] = true
+			connections = append(connections, conn)
+		}
+	}
+	d.Connections = connections
+
+	return issues
+}
+
+// merge fills the empty fields of c from another declaration, the one at
+// index, and reports the fields both set to different values.
+func (c *Component) merge(other Component, index int, issues []MergeIssue) []MergeIssue {
+	fields := []struct {
+		name string
+		dst  *string
+		src  string
+	}{
+		{"type", (*string)(&c.Type), string(other.Type)},
+		{"label", &c.Label, other.Label},
+		{"description", &c.Description, other.Description},
+		{"direction", (*string)(&c.Direction), string(other.Direction)},
+		{"shape", (*string)(&c.Shape), string(other.Shape)},
+		{"page", &c.Page, other.Page},
+		{"swimlane", &c.Swimlane, other.Swimlane},
+		{"zone", &c.Zone, other.Zone},
+		{"subnet", &c.Subnet, other.Subnet},
+		{"cidr", &c.CIDR, other.CIDR},
+		{"port", &c.Port, other.Port},
+		{"protocol", &c.Protocol, other.Protocol},
+		{"module", &c.Module, other.Module},
+	}
+	for _, f := range fields {
+		issues = c.mergeField(f.name, f.dst, f.src, index, issues)
+	}
+
+	// The position is one value: a declaration drawn at x=0,y=0 has none.
+	switch {
+	case other.X == 0 && other.Y == 0 || other.X == c.X && other.Y == c.Y:
+	case c.X == 0 && c.Y == 0:
+		c.X, c.Y = other.X, other.Y
+	default:
+		issues = append(issues, MergeIssue{Component: c.Name, Index: index,
+			Message: fmt.Sprintf("conflicting position for %q: keeping %d,%d, ignoring %d,%d", c.Name, c.X, c.Y, other.X, other.Y)})
+	}
+
+	// Style keys merge one by one, so a partial can add a fillColor to a
+	// component that already sets a strokeColor.
+	for _, entry := range strings.Split(other.Style, ";") {
+		key, value, _ := strings.Cut(entry, "=")
+		if key == "" {
+			continue
+		}
+		current, found := styleValue(c.Style, key)
+		if !found {
+			if c.Style != "" {
+				c.Style += ";"
+			}
+			c.Style += entry
+			continue
+		}
+		issues = c.mergeField(key, &current, value, index, issues)
+		c.Style = setStyleValue(c.Style, key, current)
+	}
+	return issues
+}
+
+// mergeField sets an empty field to value, or reports a conflict when both are set.
+func (c *Component) mergeField(name string, field *string, value string, index int, issues []MergeIssue) []MergeIssue {
+	switch {
+	case value == "" || value == *field:
+	case *field == "":
+		*field = value
+	default:
+		issues = append(issues, MergeIssue{Component: c.Name, Index: index,
+			Message: fmt.Sprintf("conflicting %s for %q: keeping %q, ignoring %q", name, c.Name, *field, value)})
+	}
+	return issues
+}
+
+// setStyleValue sets the value of a key already in a style string.
+func setStyleValue(style, key, value string) string {
+	entries := strings.Split(style, ";")
+	for i, entry := range entries {
+		if k, _, _ := strings.Cut(entry, "="); k == key {
+			entries[i] = key + "=" + value
+		}
+	}
+	return strings.Join(entries, ";")
+}
+
+// styleValue looks up a key in a key=value;key=value style string.
+func styleValue(style, key string) (string, bool) {
+	for _, entry := range strings.Split(style, ";") {
+		if k, v, _ := strings.Cut(entry, "="); k == key {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,137 @@
+package model_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/model"
+)
+
+func TestDiagramReconcile(t *testing.T) {
+	t.Parallel()
+	d := &model.Diagram{
+		Components: []model.Component{
+			{Name: "Orders", Partial: true, Style: "fillColor=#fff", Swimlane: "Core"},
+			{Name: "Queue", Type: model.ComponentTypeQueue},
+			{Name: "Orders", Type: model.ComponentTypeService, Description: "Order API", Style: "strokeColor=#000"},
+			{Name: "Orders", Partial: true, Description: "Orders", Style: "fillColor=#000", Page: "Backend"},
+		},
+		Connections: []model.Connection{
+			{Source: "Orders", Target: "Queue"},
+			{Source: "Orders", Target: "Queue"},
+			{Source: "Orders", Target: "Queue", Label: "publishes"},
+		},
+	}
+
+	issues := d.Reconcile()
+
+	want := []model.Component{
+		{
+			Name:        "Orders",
+			Type:        model.ComponentTypeService,
+			Description: "Order API",
+			Style:       "strokeColor=#000;fillColor=#fff",
+			Swimlane:    "Core",
+			Page:        "Backend",
+		},
+		{Name: "Queue", Type: model.ComponentTypeQueue},
+	}
+	if !reflect.DeepEqual(d.Components, want) {
+		t.Errorf("components = %+v\nwant %+v", d.Components, want)
+	}
+	if len(d.Connections) != 2 {
+		t.Errorf("expected repeated connections to be removed, got %+v", d.Connections)
+	}
+
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 issues, got %+v", issues)
+	}
+	for i, field := range []string{"description", "fillColor"} {
+		if issues[i].Index != 3 || !strings.Contains(issues[i].Message, "conflicting "+field) {
+			t.Errorf("issue %d = %+v, want a %s conflict at index 3", i, issues[i], field)
+		}
+	}
+}
+
+func TestDiagramReconcileFills(t *testing.T) {
+	t.Parallel()
+	d := &model.Diagram{
+		Components: []model.Component{
+			{Name: "Orders", Type: model.ComponentTypeService, Style: "fillColor=;strokeColor=#000"},
+			{Name: "Orders", Partial: true, Style: "fillColor=#fff", Module: "example.com/shop", X: 40, Y: 80},
+			{Name: "Orders", Partial: true, X: 10, Y: 20},
+		},
+	}
+
+	issues := d.Reconcile()
+
+	want := []model.Component{{
+		Name:   "Orders",
+		Type:   model.ComponentTypeService,
+		Style:  "fillColor=#fff;strokeColor=#000",
+		Module: "example.com/shop",
+		X:      40,
+		Y:      80,
+	}}
+	if !reflect.DeepEqual(d.Components, want) {
+		t.Errorf("components = %+v\nwant %+v", d.Components, want)
+	}
+	if len(issues) != 1 || issues[0].Index != 2 || !strings.Contains(issues[0].Message, "conflicting position") {
+		t.Errorf("issues = %+v, want a position conflict at index 2", issues)
+	}
+}
+
+func TestDiagramReconcileIssues(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		components []model.Component
+		wantType   model.ComponentType
+		wantIssue  string
+		wantIndex  int
+	}{
+		{
+			name: "two full declarations",
+			components: []model.Component{
+				{Name: "A", Type: model.ComponentTypeDatabase},
+				{Name: "A", Type: model.ComponentTypeService},
+			},
+			wantType:  model.ComponentTypeDatabase,
+			wantIssue: `duplicate declaration of "A"`,
+			wantIndex: 1,
+		},
+		{
+			name: "only partial declarations",
+			components: []model.Component{
+				{Name: "A", Partial: true},
+				{Name: "A", Partial: true, Type: model.ComponentTypeCache},
+			},
+			wantType:  model.ComponentTypeCache,
+			wantIssue: `"A" only has partial declarations`,
+			wantIndex: 0,
+		},
+		{
+			name:       "partial without a type",
+			components: []model.Component{{Name: "A", Partial: true}},
+			wantType:   model.ComponentTypeService,
+			wantIssue:  `"A" only has partial declarations`,
+			wantIndex:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d := &model.Diagram{Components: tt.components}
+			issues := d.Reconcile()
+
+			if len(d.Components) != 1 || d.Components[0].Type != tt.wantType || d.Components[0].Partial {
+				t.Errorf("components = %+v, want one full %s", d.Components, tt.wantType)
+			}
+			if len(issues) == 0 || !strings.Contains(issues[0].Message, tt.wantIssue) || issues[0].Index != tt.wantIndex {
+				t.Errorf("issues = %+v, want %q at index %d", issues, tt.wantIssue, tt.wantIndex)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package modelfile reads diagram model fragments written in JSON or YAML.
+package modelfile
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+
+	"diagram-gen/internal/model"
+)
+
+// SchemaID identifies the JSON Schema of model files.
+const SchemaID = "urn:diagram-gen:model:1"
+
+//go:embed diagram.schema.json
+var schemaJSON []byte
+
+var schema = jsonschema.MustCompileString(SchemaID, string(schemaJSON))
+
+// Schema returns the JSON Schema model files are validated against.
+func Schema() []byte {
+	return bytes.Clone(schemaJSON)
+}
+
+// IsModelFile reports whether a path names a JSON or YAML file.
+func IsModelFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// IsDiscoverable reports whether a file found while walking directories is a
+// model file. Only names such as saas.diagram.yaml are picked up, so other
+// JSON and YAML files in a repository are left alone.
+func IsDiscoverable(name string) bool {
+	ext := filepath.Ext(name)
+	return IsModelFile(name) && strings.HasSuffix(strings.TrimSuffix(name, ext), ".diagram")
+}
+
+// Problem is a schema violation at a JSON pointer into the model file.
+type Problem struct {
+	Pointer string
+	Message string
+}
+
+// String formats the problem as pointer: message.
+func (p Problem) String() string {
+	pointer := p.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return pointer + ": " + p.Message
+}
+
+// ValidationError lists the schema violations of a model file.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		messages[i] = p.String()
+	}
+	return "model file does not match the schema: " + strings.Join(messages, "; ")
+}
+
+// Load decodes and validates a model file. JSON is read from files ending
+// in .json and YAML from the others. Schema violations are returned as
+// *ValidationError.
+func Load(path string, data []byte) (*model.Diagram, error) {
+	doc, err := decode(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Validate(doc); err != nil {
+		return nil, err
+	}
+
+	normalizePorts(doc)
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	diagram := &model.Diagram{}
+	if err := json.Unmarshal(normalized, diagram); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return diagram, nil
+}
+
+// Marshal encodes the components and connections of a diagram as a model
+// file, in JSON for paths ending in .json and YAML for the others.
+func Marshal(path string, diagram *model.Diagram) ([]byte, error) {
+	doc := struct {
+		Components  []model.Component  `json:"components,omitempty"`
+		Connections []model.Connection `json:"connections,omitempty"`
+	}{diagram.Components, diagram.Connections}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return buf.Bytes(), nil
+	}
+
+	// JSON is YAML: decoding it keeps the key order, and clearing the
+	// styles writes it back in block style.
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &node); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	blockStyle(&node)
+	buf.Reset()
+	yenc := yaml.NewEncoder(&buf)
+	yenc.SetIndent(2)
+	if err := yenc.Encode(&node); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := yenc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Validate checks a decoded JSON document against the model file schema.
+func Validate(doc any) error {
+	err := schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	result := &ValidationError{}
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			result.Problems = append(result.Problems, Problem{Pointer: e.InstanceLocation, Message: e.Message})
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(verr)
+	return result
+}
+
+// decode reads a model file into the generic form produced by encoding/json.
+func decode(path string, data []byte) (any, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML in %s: %w", path, err)
+		}
+		if doc == nil {
+			doc = map[string]any{}
+		}
+		// Round-trip through JSON so YAML values take the same shapes as JSON ones.
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML in %s: %w", path, err)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	return doc, nil
+}
+
+// normalizePorts turns numeric ports, which the schema allows for
+// convenience, into the strings of the model.
+func normalizePorts(doc any) {
+	root, _ := doc.(map[string]any)
+	for _, key := range []string{"components", "connections"} {
+		items, _ := root[key].([]any)
+		for _, item := range items {
+			obj, _ := item.(map[string]any)
+			if port, ok := obj["port"].(json.Number); ok {
+				obj["port"] = port.String()
+			}
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package modelfile_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/modelfile"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	want := &model.Diagram{
+		Components: []model.Component{
+			{Name: "Stripe", Type: model.ComponentTypeExternal, Label: "Stripe API", Port: "443", Protocol: "https"},
+			{Name: "Orders", Partial: true},
+		},
+		Connections: []model.Connection{
+			{Source: "Orders", Target: "Stripe", Label: "charges", Port: "8000-8080"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		data string
+	}{
+		{
+			name: "json",
+			path: "saas.diagram.json",
+			data: `{
+  "components": [
+    {"name": "Stripe", "type": "external", "label": "Stripe API", "port": 443, "protocol": "https"},
+    {"name": "Orders", "partial": true}
+  ],
+  "connections": [
+    {"source": "Orders", "target": "Stripe", "label": "charges", "port": "8000-8080"}
+  ]
+}`,
+		},
+		{
+			name: "yaml",
+			path: "saas.diagram.yaml",
+			data: `components:
+  - name: Stripe
+    type: external
+    label: Stripe API
+    port: 443
+    protocol: https
+  - name: Orders
+    partial: true
+connections:
+  - source: Orders
+    target: Stripe
+    label: charges
+    port: 8000-8080
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := modelfile.Load(tt.path, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	t.Parallel()
+	got, err := modelfile.Load("empty.diagram.yml", nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(got.Components) != 0 || len(got.Connections) != 0 {
+		t.Errorf("Load = %+v, want an empty diagram", got)
+	}
+}
+
+func TestLoadSchemaProblems(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		data    string
+		pointer string
+		message string
+	}{
+		{
+			name:    "unknown type",
+			data:    `{"components": [{"name": "A", "type": "mainframe"}]}`,
+			pointer: "/components/0/type",
+			message: "value must be one of",
+		},
+		{
+			name:    "missing name",
+			data:    `{"components": [{"type": "service"}]}`,
+			pointer: "/components/0",
+			message: "missing properties: 'name'",
+		},
+		{
+			name:    "unknown property",
+			data:    `{"components": [{"name": "A", "colour": "red"}]}`,
+			pointer: "/components/0",
+			message: "additionalProperties 'colour' not allowed",
+		},
+		{
+			name:    "port out of range",
+			data:    `{"connections": [{"source": "A", "target": "B", "port": 70000}]}`,
+			pointer: "/connections/0/port",
+		},
+		{
+			name:    "unknown top-level key",
+			data:    `{"nodes": []}`,
+			pointer: "",
+			message: "additionalProperties 'nodes' not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := modelfile.Load("model.json", []byte(tt.data))
+			var invalid *modelfile.ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Load error = %v, want a *ValidationError", err)
+			}
+			for _, problem := range invalid.Problems {
+				if problem.Pointer == tt.pointer && strings.Contains(problem.Message, tt.message) {
+					return
+				}
+			}
+			t.Errorf("problems %v do not include %q at %q", invalid.Problems, tt.message, tt.pointer)
+		})
+	}
+}
+
+func TestLoadSyntaxErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{path: "model.json", data: `{"components": [`, want: "invalid JSON in model.json"},
+		{path: "model.yaml", data: "components: [\n", want: "invalid YAML in model.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			_, err := modelfile.Load(tt.path, []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+			var invalid *modelfile.ValidationError
+			if errors.As(err, &invalid) {
+				t.Errorf("syntax error reported as schema violation: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		model        bool
+		discoverable bool
+	}{
+		{"saas.diagram.yaml", true, true},
+		{"saas.diagram.yml", true, true},
+		{"SAAS.diagram.JSON", true, true},
+		{"config.yaml", true, false},
+		{"package.json", true, false},
+		{"diagram.json", true, false},
+		{"main.go", false, false},
+		{"notes.diagram.txt", false, false},
+	}
+	for _, tt := range tests {
+		if got := modelfile.IsModelFile(tt.name); got != tt.model {
+			t.Errorf("IsModelFile(%q) = %v, want %v", tt.name, got, tt.model)
+		}
+		if got := modelfile.IsDiscoverable(tt.name); got != tt.discoverable {
+			t.Errorf("IsDiscoverable(%q) = %v, want %v", tt.name, got, tt.discoverable)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+	var schema struct {
+		ID string `json:"$id"`
+	}
+	if err := json.Unmarshal(modelfile.Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	if schema.ID != modelfile.SchemaID {
+		t.Errorf("schema $id = %q, want %q", schema.ID, modelfile.SchemaID)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+	diagram := &model.Diagram{
+		Components: []model.Component{
+			{Name: "API", Type: model.ComponentTypeService, Style: "fillColor=#dae8fc", Port: "443", X: 120, Y: 80},
+			{Name: "true", Type: model.ComponentTypeDatabase, Label: "Orders & Users", Page: "Data"},
+		},
+		Connections: []model.Connection{
+			{Source: "API", Target: "true", Label: "reads: all", Direction: model.ConnectionDirectionBidirectional},
+			// Written by generate --infer; the schema must accept it back.
+			{Source: "true", Target: "API", Inferred: true},
+		},
+	}
+
+	for _, path := range []string{"out.json", "out.yaml"} {
+		data, err := modelfile.Marshal(path, diagram)
+		if err != nil {
+			t.Fatalf("Marshal(%s) failed: %v", path, err)
+		}
+		got, err := modelfile.Load(path, data)
+		if err != nil {
+			t.Fatalf("Load(%s) of marshalled data failed: %v\n%s", path, err, data)
+		}
+		if !reflect.DeepEqual(got, diagram) {
+			t.Errorf("%s round trip = %+v\nwant %+v", path, got, diagram)
+		}
+	}
+
+	data, err := modelfile.Marshal("out.yml", diagram)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "components:\n  - type: service\n    name: API\n") {
+		t.Errorf("YAML is not in block style:\n%s", data)
+	}
+}
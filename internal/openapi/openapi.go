@@ -0,0 +1,230 @@
+// Package openapi imports OpenAPI 3 documents as API components.
+package openapi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"diagram-gen/internal/model"
+)
+
+// BackendExtension names the components that implement an API, a path or an
+// operation. Its value is a component name or a list of them.
+const BackendExtension = "x-backend"
+
+// methods lists the operations of a path item in the order they are read.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Options controls how documents are imported.
+type Options struct {
+	// Tags adds a component per tag, holding the operations tagged with it.
+	Tags bool
+}
+
+// Result is an imported document. Connections name their targets
+// explicitly; ServerConnections lead to the hosts of the servers, which are
+// only components when something else declares them.
+type Result struct {
+	Components        []model.Component
+	Connections       []model.Connection
+	ServerConnections []model.Connection
+}
+
+// IsDocument reports whether data holds an OpenAPI document, in JSON or YAML,
+// of any version.
+func IsDocument(data []byte) bool {
+	var header struct {
+		OpenAPI string `yaml:"openapi"`
+	}
+	return yaml.Unmarshal(data, &header) == nil && header.OpenAPI != ""
+}
+
+type document struct {
+	OpenAPI string `yaml:"openapi"`
+	Info    struct {
+		Title       string `yaml:"title"`
+		Description string `yaml:"description"`
+	} `yaml:"info"`
+	Servers []struct {
+		URL     string `yaml:"url"`
+		Backend names  `yaml:"x-backend"`
+	} `yaml:"servers"`
+	Tags []struct {
+		Name        string `yaml:"name"`
+		Description string `yaml:"description"`
+	} `yaml:"tags"`
+	Paths   yaml.Node `yaml:"paths"`
+	Backend names     `yaml:"x-backend"`
+}
+
+type operation struct {
+	Tags    []string `yaml:"tags"`
+	Backend names    `yaml:"x-backend"`
+}
+
+// names reads an extension holding one name or a list of them.
+type names []string
+
+func (n *names) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value != "" {
+			*n = names{node.Value}
+		}
+		return nil
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*n = list
+		return nil
+	}
+	return fmt.Errorf("line %d: %s must be a name or a list of names", node.Line, BackendExtension)
+}
+
+// Load imports an OpenAPI 3 document. The document becomes an API component
+// named by its title and described by its description. x-backend on the
+// document, a path or an operation connects the API, or with Options.Tags
+// the component of the operation's first tag, to the named components;
+// connections from paths and operations are labelled with them.
+func Load(path string, data []byte, opts Options) (*Result, error) {
+	var doc document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: %w", path, err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q in %s: only 3.x is supported", doc.OpenAPI, path)
+	}
+	title := doc.Info.Title
+	if title == "" {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: info.title is required", path)
+	}
+
+	api := model.Component{
+		Name:        title,
+		Type:        model.ComponentTypeAPI,
+		Description: strings.TrimSpace(doc.Info.Description),
+	}
+	result := &Result{}
+	b := &builder{result: result}
+	if opts.Tags {
+		api.Swimlane = title
+	}
+	result.Components = append(result.Components, api)
+
+	// tagOwner maps tags to their components, in declaration order.
+	tagOwner := make(map[string]string)
+	addTag := func(name, description string) string {
+		if owner, ok := tagOwner[name]; ok {
+			return owner
+		}
+		owner := title + "/" + name
+		tagOwner[name] = owner
+		result.Components = append(result.Components, model.Component{
+			Name:        owner,
+			Type:        model.ComponentTypeAPI,
+			Label:       name,
+			Description: strings.TrimSpace(description),
+			Swimlane:    title,
+		})
+		b.connect(title, owner, "")
+		return owner
+	}
+	if opts.Tags {
+		for _, tag := range doc.Tags {
+			addTag(tag.Name, tag.Description)
+		}
+	}
+
+	b.backends(title, doc.Backend, "")
+	for _, server := range doc.Servers {
+		b.backends(title, server.Backend, "")
+		if host := serverHost(server.URL); host != "" && host != title {
+			result.ServerConnections = append(result.ServerConnections, model.Connection{Source: title, Target: host})
+		}
+	}
+
+	if doc.Paths.Kind != 0 && doc.Paths.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid OpenAPI document %s: line %d: paths must be a mapping", path, doc.Paths.Line)
+	}
+	for i := 0; i+1 < len(doc.Paths.Content); i += 2 {
+		route, item := doc.Paths.Content[i].Value, doc.Paths.Content[i+1]
+
+		var pathItem struct {
+			Backend names `yaml:"x-backend"`
+		}
+		if err := item.Decode(&pathItem); err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI document %s: path %s: %w", path, route, err)
+		}
+		b.backends(title, pathItem.Backend, route)
+
+		for _, method := range methods {
+			node := mappingValue(item, method)
+			if node == nil {
+				continue
+			}
+			var op operation
+			if err := node.Decode(&op); err != nil {
+				return nil, fmt.Errorf("invalid OpenAPI document %s: %s %s: %w", path, strings.ToUpper(method), route, err)
+			}
+
+			owner := title
+			if opts.Tags && len(op.Tags) > 0 {
+				owner = addTag(op.Tags[0], "")
+			}
+			b.backends(owner, op.Backend, strings.ToUpper(method)+" "+route)
+		}
+	}
+	return result, nil
+}
+
+// builder collects the connections of a document, once each.
+type builder struct {
+	result *Result
+	seen   map[model.Connection]bool
+}
+
+func (b *builder) connect(source, target, label string) {
+	conn := model.Connection{Source: source, Target: target, Label: label}
+	if b.seen == nil {
+		b.seen = make(map[model.Connection]bool)
+	}
+	if b.seen[conn] {
+		return
+	}
+	b.seen[conn] = true
+	b.result.Connections = append(b.result.Connections, conn)
+}
+
+func (b *builder) backends(source string, targets names, label string) {
+	for _, target := range targets {
+		b.connect(source, target, label)
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// serverHost returns the host name of a server URL without its port, or ""
+// for relative URLs and URLs with variables in the host.
+func serverHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || strings.Contains(u.Host, "{") {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,166 @@
+package openapi_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/openapi"
+)
+
+const orders = `openapi: 3.1.0
+info:
+  title: Orders API
+  description: |
+    Places and tracks orders.
+  version: 1.0.0
+servers:
+  - url: https://orders.example.com/v1
+  - url: http://orders-svc:8080
+  - url: /v1
+  - url: https://{region}.example.com
+x-backend: Gateway
+tags:
+  - name: orders
+    description: Order lifecycle
+  - name: admin
+paths:
+  /orders:
+    x-backend: OrderService
+    get:
+      tags: [orders]
+      x-backend: [OrderService, OrderDB]
+    post:
+      tags: [orders, admin]
+      x-backend: OrderService
+  /refunds:
+    post:
+      tags: [refunds]
+      x-backend: Payments
+  /health:
+    get:
+      x-backend: OrderService
+`
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	got, err := openapi.Load("orders.yaml", []byte(orders), openapi.Options{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := &openapi.Result{
+		Components: []model.Component{
+			{Name: "Orders API", Type: model.ComponentTypeAPI, Description: "Places and tracks orders."},
+		},
+		Connections: []model.Connection{
+			{Source: "Orders API", Target: "Gateway"},
+			{Source: "Orders API", Target: "OrderService", Label: "/orders"},
+			{Source: "Orders API", Target: "OrderService", Label: "GET /orders"},
+			{Source: "Orders API", Target: "OrderDB", Label: "GET /orders"},
+			{Source: "Orders API", Target: "OrderService", Label: "POST /orders"},
+			{Source: "Orders API", Target: "Payments", Label: "POST /refunds"},
+			{Source: "Orders API", Target: "OrderService", Label: "GET /health"},
+		},
+		ServerConnections: []model.Connection{
+			{Source: "Orders API", Target: "orders.example.com"},
+			{Source: "Orders API", Target: "orders-svc"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestLoadTags(t *testing.T) {
+	t.Parallel()
+	got, err := openapi.Load("orders.yaml", []byte(orders), openapi.Options{Tags: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	wantComponents := []model.Component{
+		{Name: "Orders API", Type: model.ComponentTypeAPI, Description: "Places and tracks orders.", Swimlane: "Orders API"},
+		{Name: "Orders API/orders", Type: model.ComponentTypeAPI, Label: "orders", Description: "Order lifecycle", Swimlane: "Orders API"},
+		{Name: "Orders API/admin", Type: model.ComponentTypeAPI, Label: "admin", Swimlane: "Orders API"},
+		{Name: "Orders API/refunds", Type: model.ComponentTypeAPI, Label: "refunds", Swimlane: "Orders API"},
+	}
+	if !reflect.DeepEqual(got.Components, wantComponents) {
+		t.Errorf("components = %+v\nwant %+v", got.Components, wantComponents)
+	}
+
+	// Operations belong to their first tag; untagged ones stay on the API.
+	wantConnections := []model.Connection{
+		{Source: "Orders API", Target: "Orders API/orders"},
+		{Source: "Orders API", Target: "Orders API/admin"},
+		{Source: "Orders API", Target: "Gateway"},
+		{Source: "Orders API", Target: "OrderService", Label: "/orders"},
+		{Source: "Orders API/orders", Target: "OrderService", Label: "GET /orders"},
+		{Source: "Orders API/orders", Target: "OrderDB", Label: "GET /orders"},
+		{Source: "Orders API/orders", Target: "OrderService", Label: "POST /orders"},
+		{Source: "Orders API", Target: "Orders API/refunds"},
+		{Source: "Orders API/refunds", Target: "Payments", Label: "POST /refunds"},
+		{Source: "Orders API", Target: "OrderService", Label: "GET /health"},
+	}
+	if !reflect.DeepEqual(got.Connections, wantConnections) {
+		t.Errorf("connections = %+v\nwant %+v", got.Connections, wantConnections)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	t.Parallel()
+	data := "{\n\t\"openapi\": \"3.0.3\",\n\t\"info\": {\"title\": \"Users\", \"version\": \"1\"},\n" +
+		"\t\"paths\": {\"/users\": {\"get\": {\"x-backend\": \"UserService\"}}}\n}\n"
+	got, err := openapi.Load("users.json", []byte(data), openapi.Options{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := []model.Connection{{Source: "Users", Target: "UserService", Label: "GET /users"}}
+	if !reflect.DeepEqual(got.Connections, want) {
+		t.Errorf("connections = %+v, want %+v", got.Connections, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "syntax", data: "openapi: [\n", want: "invalid OpenAPI document"},
+		{name: "version", data: "openapi: 2.0\ninfo:\n  title: Old\n", want: `unsupported OpenAPI version "2.0"`},
+		{name: "title", data: "openapi: 3.0.0\ninfo:\n  version: 1\n", want: "info.title is required"},
+		{name: "paths", data: "openapi: 3.0.0\ninfo:\n  title: A\npaths: [/a]\n", want: "paths must be a mapping"},
+		{name: "backend", data: "openapi: 3.0.0\ninfo:\n  title: A\nx-backend: {name: B}\n", want: "x-backend must be a name or a list of names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := openapi.Load("api.yaml", []byte(tt.data), openapi.Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDocument(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"openapi: 3.0.0\n", true},
+		{`{"openapi": "3.1.0"}`, true},
+		{"swagger: \"2.0\"\n", false},
+		{"components: []\n", false},
+		{"not: [valid\n", false},
+	}
+	for _, tt := range tests {
+		if got := openapi.IsDocument([]byte(tt.data)); got != tt.want {
+			t.Errorf("IsDocument(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package plantuml reads PlantUML component diagrams.
+package plantuml
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/validator"
+)
+
+// IsPlantUMLFile reports whether a path names a PlantUML file.
+func IsPlantUMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".puml", ".plantuml", ".pu", ".iuml", ".wsd":
+		return true
+	}
+	return false
+}
+
+// elementTypes maps element keywords to component types.
+var elementTypes = map[string]model.ComponentType{
+	"component":   model.ComponentTypeService,
+	"agent":       model.ComponentTypeService,
+	"card":        model.ComponentTypeService,
+	"node":        model.ComponentTypeService,
+	"rectangle":   model.ComponentTypeService,
+	"database":    model.ComponentTypeDatabase,
+	"queue":       model.ComponentTypeQueue,
+	"collections": model.ComponentTypeQueue,
+	"actor":       model.ComponentTypeUser,
+	"person":      model.ComponentTypeUser,
+	"cloud":       model.ComponentTypeExternal,
+	"interface":   model.ComponentTypeAPI,
+	"hexagon":     model.ComponentTypeGateway,
+	"storage":     model.ComponentTypeStorage,
+	"file":        model.ComponentTypeStorage,
+	"artifact":    model.ComponentTypeStorage,
+	"folder":      model.ComponentTypeStorage,
+	"frame":       model.ComponentTypeService,
+	"package":     model.ComponentTypeService,
+}
+
+// ignored lists the statements that do not describe components.
+var ignored = []string{
+	"title", "header", "footer", "caption", "scale", "skinparam", "hide",
+	"show", "left to right direction", "top to bottom direction",
+	"allowmixing", "allow_mixing", "!", "sprite", "remove",
+}
+
+// Parse reads a component diagram. Elements such as [Name], component,
+// database, queue and actor become components named by their alias or name
+// and typed after their keyword or a <<stereotype>> naming a component type.
+// Relations become connections and elements with a body, such as
+// package "Backend" { ... }, swimlanes. The @startuml and @enduml lines are
+// optional. Names only used in relations, other than [Name] and () Name,
+// are references: they are partial and have no type, so they merge with a
+// declaration found elsewhere.
+func Parse(path string, data []byte) (*model.Diagram, error) {
+	p := &parser{
+		diagram: model.Diagram{Type: model.DiagramTypeArchitecture},
+		byName:  make(map[string]int),
+	}
+	if err := p.parse(string(data)); err != nil {
+		return nil, fmt.Errorf("invalid PlantUML diagram %s: %w", path, err)
+	}
+	return &p.diagram, nil
+}
+
+type parser struct {
+	diagram model.Diagram
+	byName  map[string]int
+	// lanes holds the names of the open containers, innermost last; the
+	// name of an anonymous block, such as together, is empty.
+	lanes []string
+}
+
+var (
+	// element matches a declaration such as
+	// database "Orders DB" as db <<cache>> #f8cecc {
+	element = regexp.MustCompile(`^([a-z]+)\s+("[^"]*"|\[[^\]]*\]|[\w.]+)(?:\s+as\s+("[^"]*"|[\w.]+))?\s*(<<\s*([\w ]+?)\s*>>)?\s*(#\w+)?\s*(\{)?$`)
+	// shortElement matches the [Name] and () Name forms.
+	shortElement = regexp.MustCompile(`^(\[[^\]]+\]|\(\)\s*(?:"[^"]*"|[\w.]+))(?:\s+as\s+("[^"]*"|[\w.]+))?\s*(<<\s*([\w ]+?)\s*>>)?\s*(#\w+)?$`)
+	// relation matches a relation such as orders -[#red]-> db : reads
+	relation = regexp.MustCompile(`^(\[[^\]]+\]|\(\)\s*"[^"]*"|"[^"]*"|[\w.]+)\s*` +
+		`(<\|?|[*o#x}+^])?(-+|\.+)(\[[^\]]*\])?(?:(left|right|up|down|le|ri|do|u|d|l|r)?(-+|\.+))?(\|?>|[*#{+^]|[ox]\s)?` +
+		`\s*(\[[^\]]+\]|\(\)\s*"[^"]*"|"[^"]*"|[\w.]+)\s*(?::\s*(.*))?$`)
+)
+
+func (p *parser) parse(src string) error {
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		number := i + 1
+
+		// Multi-line comments, notes and legends are skipped whole.
+		var end string
+		switch {
+		case strings.HasPrefix(text, "/'") && !strings.Contains(text[2:], "'/"):
+			end = "'/"
+		case strings.HasPrefix(text, "note ") && !strings.Contains(text, ":") && !strings.Contains(text, `"`):
+			end = "end note"
+		case text == "legend" || strings.HasPrefix(text, "legend "):
+			end = "endlegend"
+		case strings.HasPrefix(text, "skinparam") && strings.HasSuffix(text, "{"):
+			end = "}"
+		}
+		if end != "" {
+			for i++; i < len(lines) && !strings.Contains(lines[i], end); i++ {
+			}
+			if i == len(lines) {
+				return fmt.Errorf("line %d: %q is not closed by %q", number, text, end)
+			}
+			continue
+		}
+
+		if err := p.statement(text); err != nil {
+			return fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+	if len(p.lanes) > 0 {
+		return fmt.Errorf("%q is not closed", p.lanes[len(p.lanes)-1])
+	}
+	return nil
+}
+
+func (p *parser) statement(text string) error {
+	switch {
+	case text == "" || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "/'"):
+		return nil
+	case strings.HasPrefix(text, "@startuml") || strings.HasPrefix(text, "@enduml"):
+		return nil
+	case strings.HasPrefix(text, "note "):
+		return nil
+	case text == "}":
+		if len(p.lanes) == 0 {
+			return fmt.Errorf("unexpected }")
+		}
+		p.lanes = p.lanes[:len(p.lanes)-1]
+		return nil
+	case text == "together {":
+		p.lanes = append(p.lanes, p.lane())
+		return nil
+	}
+	for _, prefix := range ignored {
+		if strings.HasPrefix(text, prefix) {
+			return nil
+		}
+	}
+
+	if m := relation.FindStringSubmatch(text); m != nil {
+		p.relation(m)
+		return nil
+	}
+	if m := shortElement.FindStringSubmatch(text); m != nil {
+		name, label := nameAndLabel(m[1], m[2])
+		typ := model.ComponentTypeService
+		if strings.HasPrefix(m[1], "(") {
+			typ = model.ComponentTypeAPI
+		}
+		p.declare(name, label, stereotype(typ, m[4]), m[5])
+		return nil
+	}
+	if m := element.FindStringSubmatch(text); m != nil {
+		typ, ok := elementTypes[m[1]]
+		if !ok {
+			return fmt.Errorf("unsupported element %q", m[1])
+		}
+		name, label := nameAndLabel(m[2], m[3])
+		if m[7] != "" {
+			p.lanes = append(p.lanes, unquote(m[2]))
+			return nil
+		}
+		p.declare(name, label, stereotype(typ, m[5]), m[6])
+		return nil
+	}
+	return fmt.Errorf("unsupported statement %q", text)
+}
+
+// lane returns the innermost named container.
+func (p *parser) lane() string {
+	for i := len(p.lanes) - 1; i >= 0; i-- {
+		if p.lanes[i] != "" {
+			return p.lanes[i]
+		}
+	}
+	return ""
+}
+
+// declare adds a component, or completes one a relation created.
+func (p *parser) declare(name, label string, typ model.ComponentType, color string) {
+	comp := p.component(name)
+	comp.Label, comp.Type, comp.Partial = label, typ, false
+	if color != "" {
+		comp.Style = "fillColor=" + color
+	}
+}
+
+// component returns the component with a name, creating a reference in the
+// innermost container.
+func (p *parser) component(name string) *model.Component {
+	if i, ok := p.byName[name]; ok {
+		return &p.diagram.Components[i]
+	}
+	p.byName[name] = len(p.diagram.Components)
+	p.diagram.AddComponent(model.Component{Name: name, Swimlane: p.lane(), Partial: true})
+	return &p.diagram.Components[len(p.diagram.Components)-1]
+}
+
+// arrowHeads maps arrow heads to draw.io arrows; > is the default.
+var arrowHeads = map[string]string{
+	"|>": "block", "*": "diamond", "o": "oval", "#": "box",
+	"x": "cross", "+": "circlePlus", "^": "block", "{": "ERmany",
+	"<|": "block", "}": "ERmany",
+}
+
+// relation adds the connection matched by the relation expression.
+func (p *parser) relation(m []string) {
+	source, _ := nameAndLabel(m[1], "")
+	target, _ := nameAndLabel(m[8], "")
+	start, head := m[2], strings.TrimSpace(m[7])
+	// The [Name] and () Name forms declare the elements they name.
+	for _, end := range []struct{ expr, name string }{{m[1], source}, {m[8], target}} {
+		if _, ok := p.byName[end.name]; ok {
+			continue
+		}
+		comp := p.component(end.name)
+		switch {
+		case strings.HasPrefix(end.expr, "()"):
+			comp.Type, comp.Partial = model.ComponentTypeAPI, false
+		case strings.HasPrefix(end.expr, "["):
+			comp.Type, comp.Partial = model.ComponentTypeService, false
+		}
+	}
+
+	// Links are unidirectional unless both ends have arrows, as with
+	// connectsTo, so that a link repeating an annotation merges with it.
+	conn := model.Connection{Source: source, Target: target, Label: unquote(m[9]),
+		Direction: model.ConnectionDirectionUnidirectional}
+	switch {
+	case strings.HasPrefix(start, "<") && strings.HasSuffix(head, ">"):
+		conn.Direction = model.ConnectionDirectionBidirectional
+	case start == "<":
+		conn.Source, conn.Target = target, source
+	case start != "":
+		conn.Source, conn.Target = target, source
+		conn.EndArrow = arrowHeads[start]
+	case head == "":
+		conn.EndArrow = "none"
+	case head != ">":
+		conn.EndArrow = arrowHeads[head]
+	}
+
+	var attrs []string
+	if strings.Contains(m[3]+m[6], ".") {
+		attrs = append(attrs, "dashed=1")
+	}
+	for _, opt := range strings.Split(strings.Trim(m[4], "[]"), ",") {
+		switch opt = strings.TrimSpace(opt); {
+		case strings.HasPrefix(opt, "#"):
+			attrs = append(attrs, "strokeColor="+opt)
+		case opt == "dashed" || opt == "dotted":
+			attrs = append(attrs, "dashed=1")
+		case opt == "bold":
+			attrs = append(attrs, "strokeWidth=2")
+		}
+	}
+	conn.Style = strings.Join(dedupe(attrs), ";")
+	p.diagram.AddConnection(conn)
+}
+
+// stereotype returns the component type a stereotype such as <<database>>
+// names, or typ.
+func stereotype(typ model.ComponentType, name string) model.ComponentType {
+	candidate := model.ComponentType(strings.ToLower(name))
+	if candidate != model.ComponentTypeUnknown && validator.ValidateComponentType(candidate) {
+		return candidate
+	}
+	return typ
+}
+
+// nameAndLabel returns the name of an element declared with an optional
+// alias, and its label when the alias differs.
+func nameAndLabel(declared, alias string) (string, string) {
+	text := unquote(strings.TrimSpace(strings.TrimPrefix(declared, "()")))
+	if strings.HasPrefix(declared, "[") {
+		text = strings.TrimSuffix(strings.TrimPrefix(declared, "["), "]")
+	}
+	text = strings.Join(strings.Fields(strings.ReplaceAll(text, `\n`, " ")), " ")
+	if alias == "" {
+		return text, ""
+	}
+	// Either side of "as" may hold the quoted label.
+	name := unquote(alias)
+	if strings.HasPrefix(alias, `"`) {
+		name, text = text, unquote(alias)
+	}
+	if text == name {
+		return name, ""
+	}
+	return name, text
+}
+
+func unquote(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"`)
+}
+
+func dedupe(attrs []string) []string {
+	seen := make(map[string]bool)
+	out := attrs[:0]
+	for _, attr := range attrs {
+		if !seen[attr] {
+			seen[attr] = true
+			out = append(out, attr)
+		}
+	}
+	return out
+}
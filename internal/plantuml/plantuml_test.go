@@ -0,0 +1,107 @@
+package plantuml_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/plantuml"
+)
+
+const checkout = `@startuml
+title Checkout
+left to right direction
+skinparam component {
+  BackgroundColor LightBlue
+}
+' The order path
+actor Customer
+cloud "Payment Provider" as psp
+package "Backend services" {
+  [Web Shop] as web
+  component "Order Service" as orders <<service>> #d5e8d4
+  database orders_db as "Orders DB"
+  queue Events
+  database "Sessions" as sessions <<cache>>
+  together {
+    () "REST API" as rest
+  }
+}
+note right of orders
+  Owns the order lifecycle.
+end note
+Customer --> web : places orders
+web -[#red,bold]-> rest
+rest -down-> orders
+orders <--> orders_db : SQL
+orders ..> Events
+Events <-- [Mailer]
+orders -- psp
+[Mailer] --o sessions
+@enduml
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	got, err := plantuml.Parse("checkout.puml", []byte(checkout))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	lane := "Backend services"
+	want := &model.Diagram{
+		Type: model.DiagramTypeArchitecture,
+		Components: []model.Component{
+			{Name: "Customer", Type: model.ComponentTypeUser},
+			{Name: "psp", Label: "Payment Provider", Type: model.ComponentTypeExternal},
+			{Name: "web", Label: "Web Shop", Type: model.ComponentTypeService, Swimlane: lane},
+			{Name: "orders", Label: "Order Service", Type: model.ComponentTypeService, Swimlane: lane, Style: "fillColor=#d5e8d4"},
+			{Name: "orders_db", Label: "Orders DB", Type: model.ComponentTypeDatabase, Swimlane: lane},
+			{Name: "Events", Type: model.ComponentTypeQueue, Swimlane: lane},
+			{Name: "sessions", Label: "Sessions", Type: model.ComponentTypeCache, Swimlane: lane},
+			{Name: "rest", Label: "REST API", Type: model.ComponentTypeAPI, Swimlane: lane},
+			{Name: "Mailer", Type: model.ComponentTypeService},
+		},
+		Connections: []model.Connection{
+			{Source: "Customer", Target: "web", Direction: model.ConnectionDirectionUnidirectional, Label: "places orders"},
+			{Source: "web", Target: "rest", Direction: model.ConnectionDirectionUnidirectional, Style: "strokeColor=#red;strokeWidth=2"},
+			{Source: "rest", Target: "orders", Direction: model.ConnectionDirectionUnidirectional},
+			{Source: "orders", Target: "orders_db", Label: "SQL", Direction: model.ConnectionDirectionBidirectional},
+			{Source: "orders", Target: "Events", Direction: model.ConnectionDirectionUnidirectional, Style: "dashed=1"},
+			{Source: "Mailer", Target: "Events", Direction: model.ConnectionDirectionUnidirectional},
+			{Source: "orders", Target: "psp", Direction: model.ConnectionDirectionUnidirectional, EndArrow: "none"},
+			{Source: "Mailer", Target: "sessions", Direction: model.ConnectionDirectionUnidirectional, EndArrow: "oval"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "element", src: "@startuml\nparticipant Alice\n@enduml", want: `line 2: unsupported element "participant"`},
+		{name: "relation", src: "A => B\n", want: `line 1: unsupported statement "A => B"`},
+		{name: "brace", src: "[A]\n}\n", want: "line 2: unexpected }"},
+		{name: "container", src: `package "Backend" {` + "\n[A]\n", want: `"Backend" is not closed`},
+		{name: "note", src: "note left of A\ntext\n", want: `line 1: "note left of A" is not closed by "end note"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := plantuml.Parse("a.puml", []byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid PlantUML diagram a.puml: ") {
+				t.Errorf("Parse error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Package protobuf reads the gRPC services declared in .proto files.
+package protobuf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"diagram-gen/internal/model"
+)
+
+// Protocol is the protocol of gRPC services and the connections to them.
+const Protocol = "grpc"
+
+// IsProtoFile reports whether a path names a .proto file.
+func IsProtoFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".proto")
+}
+
+// Service is a gRPC service declared in a .proto file.
+type Service struct {
+	Name    string `json:"name"`
+	Package string `json:"package,omitempty"`
+	// Doc is the comment above the service declaration.
+	Doc  string `json:"doc,omitempty"`
+	Line int    `json:"line,omitempty"`
+	RPCs []RPC  `json:"rpcs,omitempty"`
+}
+
+// RPC is a method of a service.
+type RPC struct {
+	Name            string `json:"name"`
+	Request         string `json:"request"`
+	Response        string `json:"response"`
+	ClientStreaming bool   `json:"clientStreaming,omitempty"`
+	ServerStreaming bool   `json:"serverStreaming,omitempty"`
+}
+
+// FullName returns the package-qualified name of the service.
+func (s Service) FullName() string {
+	if s.Package == "" {
+		return s.Name
+	}
+	return s.Package + "." + s.Name
+}
+
+// GoName returns the name protoc-gen-go-grpc gives the service in Go code,
+// as in New<GoName>Client.
+func (s Service) GoName() string {
+	var b strings.Builder
+	upper := true
+	for i, r := range s.Name {
+		if r == '_' && i+1 < len(s.Name) && unicode.IsLower(rune(s.Name[i+1])) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Component returns the component of the service. Its description is the
+// service's comment, or else its package-qualified name.
+func (s Service) Component() model.Component {
+	description := s.Doc
+	if description == "" {
+		description = s.FullName()
+	}
+	return model.Component{
+		Name:        s.Name,
+		Type:        model.ComponentTypeService,
+		Description: description,
+		Protocol:    Protocol,
+	}
+}
+
+// Connections returns the connections from a client of the service, one per
+// RPC labelled with its name. RPCs streaming both ways are bidirectional.
+func (s Service) Connections(client string) []model.Connection {
+	conns := make([]model.Connection, 0, len(s.RPCs))
+	for _, rpc := range s.RPCs {
+		conn := model.Connection{
+			Source:   client,
+			Target:   s.Name,
+			Label:    rpc.Name,
+			Protocol: Protocol,
+		}
+		if rpc.ClientStreaming && rpc.ServerStreaming {
+			conn.Direction = model.ConnectionDirectionBidirectional
+		}
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Parse reads the services of a .proto file. Everything besides the package
+// and the services, such as messages and options, is skipped.
+func Parse(path string, data []byte) ([]Service, error) {
+	tokens, err := tokenize(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto file %s: %w", path, err)
+	}
+
+	p := &parser{tokens: tokens}
+	services, err := p.file()
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto file %s: %w", path, err)
+	}
+	return services, nil
+}
+
+// parser walks the tokens of a .proto file.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+// expect consumes a token with the given text.
+func (p *parser) expect(text string) error {
+	t := p.next()
+	if t.kind == tokenEOF {
+		return fmt.Errorf("line %d: expected %q, found end of file", t.line, text)
+	}
+	if t.text != text || t.kind == tokenString {
+		return fmt.Errorf("line %d: expected %q, found %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+// ident consumes an identifier, which may be dotted.
+func (p *parser) ident() (token, error) {
+	t := p.next()
+	if t.kind != tokenIdent {
+		if t.kind == tokenEOF {
+			return t, fmt.Errorf("line %d: expected a name, found end of file", t.line)
+		}
+		return t, fmt.Errorf("line %d: expected a name, found %q", t.line, t.text)
+	}
+	return t, nil
+}
+
+func (p *parser) file() ([]Service, error) {
+	var pkg string
+	var services []Service
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			for i := range services {
+				services[i].Package = pkg
+			}
+			return services, nil
+		case t.kind == tokenIdent && t.text == "package":
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			pkg = name.text
+		case t.kind == tokenIdent && t.text == "service":
+			svc, err := p.service(t)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, svc)
+		case t.kind == tokenSymbol && t.text == "{":
+			if err := p.skipBlock(t); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// service reads a service declaration after the service keyword.
+func (p *parser) service(keyword token) (Service, error) {
+	name, err := p.ident()
+	if err != nil {
+		return Service{}, err
+	}
+	svc := Service{Name: name.text, Doc: keyword.doc, Line: keyword.line}
+	if err := p.expect("{"); err != nil {
+		return Service{}, err
+	}
+
+	for {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return Service{}, fmt.Errorf("line %d: service %s is not closed", keyword.line, svc.Name)
+		case t.kind == tokenSymbol && t.text == "}":
+			return svc, nil
+		case t.kind == tokenIdent && t.text == "rpc":
+			rpc, err := p.rpc()
+			if err != nil {
+				return Service{}, err
+			}
+			svc.RPCs = append(svc.RPCs, rpc)
+		case t.kind == tokenSymbol && t.text == ";":
+		default:
+			// Options and other statements end with a semicolon.
+			if err := p.skipStatement(t); err != nil {
+				return Service{}, err
+			}
+		}
+	}
+}
+
+// rpc reads an rpc declaration after the rpc keyword.
+func (p *parser) rpc() (RPC, error) {
+	name, err := p.ident()
+	if err != nil {
+		return RPC{}, err
+	}
+	rpc := RPC{Name: name.text}
+
+	if rpc.Request, rpc.ClientStreaming, err = p.messageType(); err != nil {
+		return RPC{}, err
+	}
+	if err := p.expect("returns"); err != nil {
+		return RPC{}, err
+	}
+	if rpc.Response, rpc.ServerStreaming, err = p.messageType(); err != nil {
+		return RPC{}, err
+	}
+
+	t := p.next()
+	switch {
+	case t.kind == tokenSymbol && t.text == ";":
+		return rpc, nil
+	case t.kind == tokenSymbol && t.text == "{":
+		return rpc, p.skipBlock(t)
+	case t.kind == tokenEOF:
+		return RPC{}, fmt.Errorf("line %d: rpc %s is not terminated", name.line, rpc.Name)
+	}
+	return RPC{}, fmt.Errorf("line %d: expected \";\" or \"{\" after rpc %s, found %q", t.line, rpc.Name, t.text)
+}
+
+// messageType reads a parenthesized, possibly streamed, message type.
+func (p *parser) messageType() (string, bool, error) {
+	if err := p.expect("("); err != nil {
+		return "", false, err
+	}
+	typ, err := p.ident()
+	if err != nil {
+		return "", false, err
+	}
+	stream := false
+	if typ.text == "stream" && p.peek().kind == tokenIdent {
+		stream = true
+		if typ, err = p.ident(); err != nil {
+			return "", false, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return "", false, err
+	}
+	return typ.text, stream, nil
+}
+
+// skipBlock skips to the brace closing the one already read.
+func (p *parser) skipBlock(open token) error {
+	depth := 1
+	for depth > 0 {
+		t := p.next()
+		switch {
+		case t.kind == tokenEOF:
+			return fmt.Errorf("line %d: unbalanced braces", open.line)
+		case t.kind == tokenSymbol && t.text == "{":
+			depth++
+		case t.kind == tokenSymbol && t.text == "}":
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipStatement skips to the semicolon ending the statement started by
+// first, skipping any blocks, such as aggregate option values, on the way.
+func (p *parser) skipStatement(first token) error {
+	for t := first; ; t = p.next() {
+		switch {
+		case t.kind == tokenEOF:
+			return fmt.Errorf("line %d: statement is not terminated", first.line)
+		case t.kind == tokenSymbol && t.text == ";":
+			return nil
+		case t.kind == tokenSymbol && t.text == "{":
+			if err := p.skipBlock(t); err != nil {
+				return err
+			}
+		case t.kind == tokenSymbol && t.text == "}":
+			return fmt.Errorf("line %d: unexpected \"}\"", t.line)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package protobuf_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"diagram-gen/internal/model"
+	"diagram-gen/internal/protobuf"
+)
+
+const orders = `syntax = "proto3";
+
+package shop.orders.v1;
+
+import "google/protobuf/empty.proto";
+
+option go_package = "example.com/shop/gen/orders/v1;ordersv1";
+
+// Places and tracks orders.
+// Owned by the checkout team.
+service OrderService {
+  option (google.api.default_host) = "orders.example.com";
+
+  rpc PlaceOrder(PlaceOrderRequest) returns (Order); // not a doc comment
+  rpc Watch(WatchRequest) returns (stream Order) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+  /* Bulk import. */
+  rpc Sync(stream Order) returns (stream Order);
+  rpc Ping(.google.protobuf.Empty) returns (.google.protobuf.Empty) {}
+}
+
+message Order {
+  string id = 1;
+  message Item {
+    string sku = 1 [json_name = "SKU"];
+  }
+  repeated Item items = 2;
+  map<string, string> labels = 3;
+}
+
+// Detached from the service below.
+
+service payment_gateway {}
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	got, err := protobuf.Parse("orders.proto", []byte(orders))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := []protobuf.Service{
+		{
+			Name:    "OrderService",
+			Package: "shop.orders.v1",
+			Doc:     "Places and tracks orders. Owned by the checkout team.",
+			Line:    11,
+			RPCs: []protobuf.RPC{
+				{Name: "PlaceOrder", Request: "PlaceOrderRequest", Response: "Order"},
+				{Name: "Watch", Request: "WatchRequest", Response: "Order", ServerStreaming: true},
+				{Name: "Sync", Request: "Order", Response: "Order", ClientStreaming: true, ServerStreaming: true},
+				{Name: "Ping", Request: ".google.protobuf.Empty", Response: ".google.protobuf.Empty"},
+			},
+		},
+		{Name: "payment_gateway", Package: "shop.orders.v1", Line: 34},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "comment", data: "service A {}\n/* open", want: "line 2: comment is not terminated"},
+		{name: "string", data: "syntax = \"proto3;\n", want: "line 1: string is not terminated"},
+		{name: "unclosed service", data: "service A {\n  rpc B(C) returns (D);\n", want: "line 1: service A is not closed"},
+		{name: "missing returns", data: "service A {\n  rpc B(C) (D);\n}\n", want: `line 2: expected "returns", found "("`},
+		{name: "missing name", data: "service {}\n", want: `line 1: expected a name, found "{"`},
+		{name: "braces", data: "message M {\n  string a = 1;\n", want: "line 1: unbalanced braces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := protobuf.Parse("a.proto", []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid proto file a.proto: ") {
+				t.Errorf("Parse error = %v, want it to name the file", err)
+			}
+		})
+	}
+}
+
+func TestGoName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"OrderService", "OrderService"},
+		{"orderService", "OrderService"},
+		{"payment_gateway", "PaymentGateway"},
+		{"Legacy_API", "Legacy_API"},
+	}
+	for _, tt := range tests {
+		if got := (protobuf.Service{Name: tt.name}).GoName(); got != tt.want {
+			t.Errorf("GoName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConnections(t *testing.T) {
+	t.Parallel()
+	svc := protobuf.Service{
+		Name: "Chat",
+		RPCs: []protobuf.RPC{
+			{Name: "Send", Request: "Message", Response: "Ack"},
+			{Name: "Stream", Request: "Message", Response: "Message", ClientStreaming: true, ServerStreaming: true},
+		},
+	}
+	want := []model.Connection{
+		{Source: "Web", Target: "Chat", Label: "Send", Protocol: "grpc"},
+		{Source: "Web", Target: "Chat", Label: "Stream", Protocol: "grpc", Direction: model.ConnectionDirectionBidirectional},
+	}
+	if got := svc.Connections("Web"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Connections = %+v\nwant %+v", got, want)
+	}
+}
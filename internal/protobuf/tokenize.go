@@ -0,0 +1,117 @@
+package protobuf
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	// tokenIdent is a name, a dotted name or a number.
+	tokenIdent
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	// doc holds the comment lines directly above the token.
+	doc string
+}
+
+// tokenize splits a .proto file into tokens, keeping the comments that
+// document the next token.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	var doc []string
+	docEnd := 0
+	line := 1
+
+	comment := func(text string, start, end int) {
+		if start > docEnd+1 {
+			doc = doc[:0]
+		}
+		// A comment trailing a token on its line does not document the next one.
+		if len(tokens) > 0 && tokens[len(tokens)-1].line == start {
+			return
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			doc = append(doc, text)
+		}
+		docEnd = end
+	}
+	emit := func(kind tokenKind, text string) {
+		t := token{kind: kind, text: text, line: line}
+		if len(doc) > 0 && docEnd == line-1 {
+			t.doc = strings.Join(doc, " ")
+		}
+		doc = doc[:0]
+		tokens = append(tokens, t)
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			comment(src[i+2:i+end], line, line)
+			i += end
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: comment is not terminated", line)
+			}
+			text := src[i+2 : i+2+end]
+			start := line
+			line += strings.Count(text, "\n")
+			for _, l := range strings.Split(text, "\n") {
+				comment(strings.TrimLeft(strings.TrimSpace(l), "*"), start, line)
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+				if j < len(src) && src[j] == '\n' {
+					return nil, fmt.Errorf("line %d: string is not terminated", line)
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: string is not terminated", line)
+			}
+			emit(tokenString, src[i+1:j])
+			i = j + 1
+		case isIdentByte(c):
+			j := i
+			for j < len(src) && isIdentByte(src[j]) {
+				j++
+			}
+			emit(tokenIdent, src[i:j])
+			i = j
+		default:
+			emit(tokenSymbol, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// isIdentByte reports whether c continues a name, a dotted name such as
+// .google.protobuf.Empty, or a number.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -17,6 +17,9 @@ var validComponentTypes = map[model.ComponentType]bool{
 	model.ComponentTypeExternal: true,
 	model.ComponentTypeStorage:  true,
 	model.ComponentTypeGateway:  true,
+	model.ComponentTypeProcess:  true,
+	model.ComponentTypeDecision: true,
+	model.ComponentTypeTerminal: true,
 }
 
 // ValidateDiagram validates a diagram model.
@@ -40,7 +43,7 @@ func ValidateDiagram(diagram *model.Diagram) error {
 		componentNames[comp.Name] = true
 
 		if !validComponentTypes[comp.Type] {
-			return fmt.Errorf("unknown component type: %s (valid types: service, database, queue, cache, api, user, external, storage, gateway)", comp.Type)
+			return fmt.Errorf("unknown component type: %s (valid types: service, database, queue, cache, api, user, external, storage, gateway, process, decision, terminal)", comp.Type)
 		}
 	}
 
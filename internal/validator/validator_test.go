@@ -131,6 +131,9 @@ func TestValidateComponentType(t *testing.T) {
 		{model.ComponentTypeExternal, true},
 		{model.ComponentTypeStorage, true},
 		{model.ComponentTypeGateway, true},
+		{model.ComponentTypeProcess, true},
+		{model.ComponentTypeDecision, true},
+		{model.ComponentTypeTerminal, true},
 		{model.ComponentType("unknown"), false},
 		{model.ComponentType(""), false},
 	}